@@ -40,11 +40,68 @@ type OperatorConfig struct {
 	// keepalive configures the interval between pings to endpoints. If set to 0, pings won't be performed.
 	Keepalive int `json:"keepalive,omitempty"`
 
+	// requiredEndpoints configures the names of the endpoints which must be reachable for the Operator to report ready.
+	// If empty, the Operator reports ready as long as at least one endpoint is reachable.
+	RequiredEndpoints []string `json:"requiredEndpoints,omitempty"`
+
+	// dsnRefreshInterval configures the interval in seconds between resolutions of the endpoint DSNs from their sources.
+	// Endpoints whose DSN changed are reconnected. If set to 0, DSNs are only resolved at startup.
+	DsnRefreshInterval int `json:"dsnRefreshInterval,omitempty"`
+
+	// clusterId is the stable identifier of the cluster available to templates as .ClusterID. If empty, the UID of the
+	// kube-system namespace is used.
+	ClusterID string `json:"clusterId,omitempty"`
+
+	// resyncInterval configures the interval in seconds between checks of the existence of database instances. Checks
+	// are performed only for DatabaseClasses specifying an exists operation. The same interval applies to usage
+	// reporting and orphan detection, performed only for DatabaseClasses specifying a usage or list operation
+	// respectively. If set to 0, checks won't be performed.
+	ResyncInterval int `json:"resyncInterval,omitempty"`
+
+	// maxConcurrentReconciles configures the maximum number of Database resources reconciled concurrently. Defaults to 1.
+	MaxConcurrentReconciles int `json:"maxConcurrentReconciles,omitempty"`
+
+	// maxConcurrentReconcilesPerEndpoint configures the maximum number of Database resources bound to the same endpoint
+	// reconciled concurrently, so that a slow endpoint cannot starve the others. If set to 0, there is no limit.
+	MaxConcurrentReconcilesPerEndpoint int `json:"maxConcurrentReconcilesPerEndpoint,omitempty"`
+
+	// retryBaseDelay configures the delay in seconds before the first retry of a Database resource whose reconciliation
+	// failed with a transient error. The delay doubles at each failure. Defaults to 1.
+	RetryBaseDelay int `json:"retryBaseDelay,omitempty"`
+
+	// retryMaxDelay configures the maximum delay in seconds between retries of a Database resource whose reconciliation
+	// failed with a transient error. Defaults to 300.
+	RetryMaxDelay int `json:"retryMaxDelay,omitempty"`
+
+	// journalKeySecret configures the name of the Secret in the namespace of the Operator holding the key used to encrypt
+	// the operation journal. The Secret is created if it doesn't exist.
+	JournalKeySecret string `json:"journalKeySecret,omitempty"`
+
+	// tracing configures the export of OpenTelemetry traces. Tracing is disabled by default.
+	Tracing TracingConfig `json:"tracing,omitempty"`
+
 	// +kubebuilder:kubebuilder:validation:MinItems=1
 	// DbmsList returns the configuration for the database endpoints.
 	DbmsList database.DbmsList `json:"dbms"`
 }
 
+// TracingConfig configures the export of OpenTelemetry traces through OTLP.
+type TracingConfig struct {
+	// enabled enables tracing. Defaults to false.
+	Enabled bool `json:"enabled,omitempty"`
+
+	// endpoint is the address of the OTLP gRPC receiver traces are exported to, e.g. "otel-collector:4317".
+	Endpoint string `json:"endpoint,omitempty"`
+
+	// insecure disables TLS when connecting to endpoint.
+	Insecure bool `json:"insecure,omitempty"`
+
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=100
+	// samplingPercentage is the percentage of traces which are sampled. Defaults to 100.
+	SamplingPercentage int `json:"samplingPercentage,omitempty"`
+}
+
 // +kubebuilder:object:root=true
 func (c OperatorConfig) Complete() (cfg.ControllerManagerConfigurationSpec, error) {
 	return c.ControllerManagerConfigurationSpec, nil
@@ -31,6 +31,11 @@ func (in *OperatorConfig) DeepCopyInto(out *OperatorConfig) {
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.ControllerManagerConfigurationSpec.DeepCopyInto(&out.ControllerManagerConfigurationSpec)
+	if in.RequiredEndpoints != nil {
+		in, out := &in.RequiredEndpoints, &out.RequiredEndpoints
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 	if in.DbmsList != nil {
 		in, out := &in.DbmsList, &out.DbmsList
 		*out = make(database.DbmsList, len(*in))
@@ -38,6 +43,7 @@ func (in *OperatorConfig) DeepCopyInto(out *OperatorConfig) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	out.Tracing = in.Tracing
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OperatorConfig.
@@ -57,3 +63,18 @@ func (in *OperatorConfig) DeepCopyObject() runtime.Object {
 	}
 	return nil
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TracingConfig) DeepCopyInto(out *TracingConfig) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TracingConfig.
+func (in *TracingConfig) DeepCopy() *TracingConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(TracingConfig)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -17,6 +17,8 @@ limitations under the License.
 package v1
 
 import (
+	"github.com/bedag/kubernetes-dbaas/pkg/database"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -26,12 +28,77 @@ type DatabaseSpec struct {
 	Endpoint string `json:"endpoint,omitempty"`
 	// Params is a map containing parameters to be mapped to the database instance
 	Params map[string]string `json:"params,omitempty"`
+	// +kubebuilder:validation:Optional
+	// ParamsFrom maps parameters to values read from Secrets or ConfigMaps in the namespace of the Database resource,
+	// e.g. an initial password chosen by the owner of the resource. They take precedence over Params. Values read from
+	// Secrets are masked in events and logs. A change of any value rotates the credentials of the database instance.
+	ParamsFrom map[string]ParamSource `json:"paramsFrom,omitempty"`
+	// +kubebuilder:validation:Optional
+	// SecretTemplate overrides the SecretTemplate of the DatabaseClass. Labels and annotations are merged with the ones
+	// of the DatabaseClass.
+	SecretTemplate *database.SecretTemplate `json:"secretTemplate,omitempty"`
 }
 
 // DatabaseStatus defines the observed state of Database.
 type DatabaseStatus struct {
 	// Conditions represent the latest available observations of an object's state
 	Conditions []metav1.Condition `json:"conditions"`
+	// SecretHash is the hash of the content of the Secret as it was last rendered by the Operator. It is used to detect
+	// manual changes to the Secret.
+	SecretHash string `json:"secretHash,omitempty"`
+	// SecretSink is the type of the sink the credentials were last written to, see the SecretSink of the DatabaseClass.
+	SecretSink string `json:"secretSink,omitempty"`
+	// SecretLocation is where the credentials were last written to within SecretSink, e.g. the namespace and name of the
+	// Secret or the path of the Vault secret.
+	SecretLocation string `json:"secretLocation,omitempty"`
+	// SecretVersion is the version of the last immutable Secret written by the Operator, see the SecretTemplate.
+	SecretVersion int `json:"secretVersion,omitempty"`
+	// StalledClassGeneration is the generation of the DatabaseClass when the Stalled condition was set. A stalled
+	// resource is reconciled again once its spec or its DatabaseClass changes.
+	StalledClassGeneration int64 `json:"stalledClassGeneration,omitempty"`
+	// Binding references the Secret holding the credentials, so that the Database resource can be used as a
+	// Provisioned Service by the Service Binding specification. It is only set if the credentials are written to a
+	// Secret.
+	Binding *ServiceBinding `json:"binding,omitempty"`
+	// ParamsHash is the hash of the values of ParamsFrom the credentials were last created or rotated with. It is used
+	// to detect changes to the referenced Secrets and ConfigMaps.
+	ParamsHash string `json:"paramsHash,omitempty"`
+	// InstanceName is the name of the database instance, computed from the cluster, the namespace and the name of the
+	// Database resource when it is first created. It is available to the templates of the operations as .InstanceName.
+	InstanceName string `json:"instanceName,omitempty"`
+	// Outputs contains the values returned by the create and rotate operations whose keys are declared as
+	// non-sensitive by the DatabaseClass. Values which are not returned again by rotate are kept. They are available to
+	// the templates of later operations as .Outputs.
+	Outputs map[string]string `json:"outputs,omitempty"`
+	// Usage contains the usage metrics of the database instance as returned by the last usage operation
+	Usage *DatabaseUsage `json:"usage,omitempty"`
+}
+
+// ParamSource is the source of the value of a parameter. Exactly one of its fields must be set.
+type ParamSource struct {
+	// SecretKeyRef selects a key of a Secret in the namespace of the Database resource
+	SecretKeyRef *corev1.SecretKeySelector `json:"secretKeyRef,omitempty"`
+	// ConfigMapKeyRef selects a key of a ConfigMap in the namespace of the Database resource
+	ConfigMapKeyRef *corev1.ConfigMapKeySelector `json:"configMapKeyRef,omitempty"`
+}
+
+// ServiceBinding references the Secret of a Provisioned Service, see https://github.com/servicebinding/spec.
+type ServiceBinding struct {
+	// Name is the name of the Secret in the namespace of the Database resource
+	Name string `json:"name"`
+}
+
+// DatabaseUsage contains the usage metrics of a database instance. Metrics which are not returned by the usage
+// operation are left empty.
+type DatabaseUsage struct {
+	// SizeBytes is the size of the database instance in bytes
+	SizeBytes *int64 `json:"sizeBytes,omitempty"`
+	// TableCount is the number of tables of the database instance
+	TableCount *int64 `json:"tableCount,omitempty"`
+	// ActiveConnections is the number of connections to the database instance
+	ActiveConnections *int64 `json:"activeConnections,omitempty"`
+	// LastUpdateTime is the time of the last usage operation
+	LastUpdateTime metav1.Time `json:"lastUpdateTime,omitempty"`
 }
 
 // +kubebuilder:object:root=true
@@ -17,20 +17,49 @@ limitations under the License.
 package v1
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	admissionv1 "k8s.io/api/admission/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
-	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/util/validation/field"
+	"net/http"
 	"reflect"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
 )
 
 // log is for logging in this package.
 var databaselog = logf.Log.WithName("database-resource-webhook")
 
-func (r *Database) SetupWebhookWithManager(mgr ctrl.Manager) error {
+// InstanceNamer returns the name of the database instance of a Database resource, see DatabaseStatus.InstanceName.
+type InstanceNamer func(obj *Database) string
+
+const (
+	// EndpointIndexKey is the key of the field index of Database resources by endpoint, see SetupWebhookWithManager.
+	EndpointIndexKey = ".spec.endpoint"
+	// validatingWebhookPath is the path of the validating webhook of Database resources, see databaseValidator.
+	validatingWebhookPath = "/validate-database-dbaas-bedag-ch-v1-database"
+)
+
+// SetupWebhookWithManager registers the webhooks of Database resources in mgr. New Database resources are rejected if
+// the name of their database instance, as returned by namer, is already used on the same endpoint. The Database
+// resources of an endpoint are read from the cache of mgr through the field index EndpointIndexKey.
+func (r *Database) SetupWebhookWithManager(mgr ctrl.Manager, namer InstanceNamer) error {
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(), &Database{}, EndpointIndexKey,
+		func(obj client.Object) []string {
+			return []string{obj.(*Database).Spec.Endpoint}
+		}); err != nil {
+		return err
+	}
+	mgr.GetWebhookServer().Register(validatingWebhookPath, &webhook.Admission{Handler: &databaseValidator{
+		client: mgr.GetClient(),
+		namer:  namer,
+	}})
 	return ctrl.NewWebhookManagedBy(mgr).
 		For(r).
 		Complete()
@@ -50,21 +79,86 @@ func (r *Database) Default() {
 // TODO(user): change verbs to "verbs=create;update;delete" if you want to enable deletion validation.
 //+kubebuilder:webhook:path=/validate-database-dbaas-bedag-ch-v1-database,mutating=false,failurePolicy=fail,sideEffects=None,groups=database.dbaas.bedag.ch,resources=databases,verbs=create;update,versions=v1,name=vdatabase.kb.io,admissionReviewVersions={v1,v1beta1}
 
-var _ webhook.Validator = &Database{}
+// databaseValidator validates Database resources. Unlike webhook.Validator, it carries the dependencies needed to
+// compare a new Database resource with the existing ones.
+type databaseValidator struct {
+	// client lists the Database resources whose instance names are compared by validateCreate
+	client client.Reader
+	// namer names the database instances compared by validateCreate, their uniqueness is not checked if nil
+	namer   InstanceNamer
+	decoder *admission.Decoder
+}
+
+var _ admission.DecoderInjector = &databaseValidator{}
 
-// ValidateCreate implements webhook.Validator so a webhook will be registered for the type
-func (r *Database) ValidateCreate() error {
+// InjectDecoder implements admission.DecoderInjector.
+func (v *databaseValidator) InjectDecoder(d *admission.Decoder) error {
+	v.decoder = d
 	return nil
 }
 
-// ValidateUpdate disables any update to the 'spec' field of Database resources.
-func (r *Database) ValidateUpdate(old runtime.Object) error {
-	databaselog.Info("validate update", "name", r.Name)
-	var allErrs field.ErrorList
+// Handle implements admission.Handler. It validates creations and updates of Database resources, see validateCreate
+// and validateUpdate.
+func (v *databaseValidator) Handle(ctx context.Context, req admission.Request) admission.Response {
+	obj := &Database{}
+	if err := v.decoder.DecodeRaw(req.Object, obj); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+	var err error
+	switch req.Operation {
+	case admissionv1.Create:
+		databaselog.Info("validate create", "name", obj.Name)
+		err = v.validateCreate(ctx, obj)
+	case admissionv1.Update:
+		databaselog.Info("validate update", "name", obj.Name)
+		old := &Database{}
+		if decodeErr := v.decoder.DecodeRaw(req.OldObject, old); decodeErr != nil {
+			return admission.Errored(http.StatusBadRequest, decodeErr)
+		}
+		err = validateUpdate(obj, old)
+	}
+	if err != nil {
+		var apiStatus apierrors.APIStatus
+		if errors.As(err, &apiStatus) {
+			status := apiStatus.Status()
+			return admission.Response{AdmissionResponse: admissionv1.AdmissionResponse{Allowed: false, Result: &status}}
+		}
+		return admission.Denied(err.Error())
+	}
+	return admission.Allowed("")
+}
 
-	rOld := old.(*Database)
+// validateCreate rejects Database resources whose database instance name is already used on the same endpoint.
+func (v *databaseValidator) validateCreate(ctx context.Context, r *Database) error {
+	if v.namer == nil || v.client == nil {
+		return nil
+	}
+	instanceName := v.namer(r)
+	dbList := DatabaseList{}
+	if err := v.client.List(ctx, &dbList, client.MatchingFields{EndpointIndexKey: r.Spec.Endpoint}); err != nil {
+		return apierrors.NewInternalError(fmt.Errorf("unable to list database resources: %w", err))
+	}
+	for i := range dbList.Items {
+		db := &dbList.Items[i]
+		if db.Spec.Endpoint != r.Spec.Endpoint || (db.Namespace == r.Namespace && db.Name == r.Name) {
+			continue
+		}
+		if v.namer(db) == instanceName {
+			allErrs := field.ErrorList{field.Invalid(field.NewPath("metadata", "name"), r.Name, fmt.Sprintf(
+				"database instance name '%s' is already used by %s/%s on endpoint '%s'", instanceName, db.Namespace,
+				db.Name, r.Spec.Endpoint))}
+			return apierrors.NewInvalid(schema.GroupKind{Group: "database.dbaas.bedag.ch", Kind: "Database"},
+				r.Name, allErrs)
+		}
+	}
+	return nil
+}
 
-	if !reflect.DeepEqual(r.Spec, rOld.Spec) {
+// validateUpdate disables any update to the 'spec' field of Database resources.
+func validateUpdate(r *Database, old *Database) error {
+	var allErrs field.ErrorList
+
+	if !reflect.DeepEqual(r.Spec, old.Spec) {
 		allErrs = append(allErrs, field.Invalid(field.NewPath("spec"), r.Spec, "update operations not allowed, please explicitly "+
 			"delete the resource in order to recreate it."))
 
@@ -74,8 +168,3 @@ func (r *Database) ValidateUpdate(old runtime.Object) error {
 
 	return nil
 }
-
-// ValidateDelete implements webhook.Validator so a webhook will be registered for the type
-func (r *Database) ValidateDelete() error {
-	return nil
-}
@@ -0,0 +1,51 @@
+package v1
+
+import (
+	. "github.com/bedag/kubernetes-dbaas/pkg/test"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var _ = Describe(FormatTestDesc(Integration, "Database webhook"), func() {
+	It("should reject a Database resource whose instance name is already used on the same endpoint", func() {
+		otherNamespace := corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "webhook-other"}}
+		Expect(k8sClient.Create(ctx, &otherNamespace)).To(Succeed())
+		db := Database{
+			ObjectMeta: metav1.ObjectMeta{Name: "orders", Namespace: "default"},
+			Spec:       DatabaseSpec{Endpoint: "us-postgres-test"},
+		}
+		Expect(k8sClient.Create(ctx, &db)).To(Succeed())
+		// The webhook compares new resources with the ones in the cache of the manager
+		Eventually(func() error {
+			return cachedClient.Get(ctx, client.ObjectKeyFromObject(&db), &Database{})
+		}).Should(Succeed())
+
+		sameInstance := Database{
+			ObjectMeta: metav1.ObjectMeta{Name: "orders", Namespace: otherNamespace.Name},
+			Spec:       DatabaseSpec{Endpoint: "us-postgres-test"},
+		}
+		err := k8sClient.Create(ctx, &sameInstance)
+		Expect(apierrors.IsInvalid(err)).To(BeTrue(), "unexpected error: %v", err)
+		Expect(err.Error()).To(ContainSubstring("default/orders"))
+
+		otherEndpoint := Database{
+			ObjectMeta: metav1.ObjectMeta{Name: "orders", Namespace: otherNamespace.Name},
+			Spec:       DatabaseSpec{Endpoint: "us-sqlserver-test"},
+		}
+		Expect(k8sClient.Create(ctx, &otherEndpoint)).To(Succeed())
+	})
+	It("should reject updates of the spec", func() {
+		db := Database{
+			ObjectMeta: metav1.ObjectMeta{Name: "invoices", Namespace: "default"},
+			Spec:       DatabaseSpec{Endpoint: "us-postgres-test"},
+		}
+		Expect(k8sClient.Create(ctx, &db)).To(Succeed())
+		db.Spec.Endpoint = "us-sqlserver-test"
+		err := k8sClient.Update(ctx, &db)
+		Expect(apierrors.IsInvalid(err)).To(BeTrue(), "unexpected error: %v", err)
+	})
+})
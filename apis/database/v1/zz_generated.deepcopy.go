@@ -21,6 +21,8 @@ limitations under the License.
 package v1
 
 import (
+	"github.com/bedag/kubernetes-dbaas/pkg/database"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 )
@@ -94,6 +96,18 @@ func (in *DatabaseSpec) DeepCopyInto(out *DatabaseSpec) {
 			(*out)[key] = val
 		}
 	}
+	if in.ParamsFrom != nil {
+		in, out := &in.ParamsFrom, &out.ParamsFrom
+		*out = make(map[string]ParamSource, len(*in))
+		for key, val := range *in {
+			(*out)[key] = *val.DeepCopy()
+		}
+	}
+	if in.SecretTemplate != nil {
+		in, out := &in.SecretTemplate, &out.SecretTemplate
+		*out = new(database.SecretTemplate)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DatabaseSpec.
@@ -116,6 +130,23 @@ func (in *DatabaseStatus) DeepCopyInto(out *DatabaseStatus) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.Binding != nil {
+		in, out := &in.Binding, &out.Binding
+		*out = new(ServiceBinding)
+		**out = **in
+	}
+	if in.Outputs != nil {
+		in, out := &in.Outputs, &out.Outputs
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Usage != nil {
+		in, out := &in.Usage, &out.Usage
+		*out = new(DatabaseUsage)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DatabaseStatus.
@@ -127,3 +158,74 @@ func (in *DatabaseStatus) DeepCopy() *DatabaseStatus {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DatabaseUsage) DeepCopyInto(out *DatabaseUsage) {
+	*out = *in
+	if in.SizeBytes != nil {
+		in, out := &in.SizeBytes, &out.SizeBytes
+		*out = new(int64)
+		**out = **in
+	}
+	if in.TableCount != nil {
+		in, out := &in.TableCount, &out.TableCount
+		*out = new(int64)
+		**out = **in
+	}
+	if in.ActiveConnections != nil {
+		in, out := &in.ActiveConnections, &out.ActiveConnections
+		*out = new(int64)
+		**out = **in
+	}
+	in.LastUpdateTime.DeepCopyInto(&out.LastUpdateTime)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DatabaseUsage.
+func (in *DatabaseUsage) DeepCopy() *DatabaseUsage {
+	if in == nil {
+		return nil
+	}
+	out := new(DatabaseUsage)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ParamSource) DeepCopyInto(out *ParamSource) {
+	*out = *in
+	if in.SecretKeyRef != nil {
+		in, out := &in.SecretKeyRef, &out.SecretKeyRef
+		*out = new(corev1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ConfigMapKeyRef != nil {
+		in, out := &in.ConfigMapKeyRef, &out.ConfigMapKeyRef
+		*out = new(corev1.ConfigMapKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ParamSource.
+func (in *ParamSource) DeepCopy() *ParamSource {
+	if in == nil {
+		return nil
+	}
+	out := new(ParamSource)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ServiceBinding) DeepCopyInto(out *ServiceBinding) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ServiceBinding.
+func (in *ServiceBinding) DeepCopy() *ServiceBinding {
+	if in == nil {
+		return nil
+	}
+	out := new(ServiceBinding)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -26,6 +26,136 @@ type DatabaseClassSpec struct {
 	Driver       string                        `json:"driver,omitempty"`
 	Operations   map[string]database.Operation `json:"operations,omitempty"`
 	SecretFormat database.SecretFormat         `json:"secretFormat,omitempty"`
+	// +kubebuilder:validation:Optional
+	// CredentialVerification enables an additional check after the create and rotate operations. If set, the Operator
+	// connects to the endpoint with the credentials returned by the operation before writing them to the Secret.
+	CredentialVerification *CredentialVerification `json:"credentialVerification,omitempty"`
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum=Alert;Recreate
+	// +kubebuilder:default=Alert
+	// DriftPolicy specifies what happens when the exists operation reports that a database instance is missing on its
+	// endpoint. Alert only sets the Ready condition to false, Recreate calls the create operation again.
+	DriftPolicy DriftPolicy `json:"driftPolicy,omitempty"`
+	// +kubebuilder:validation:Optional
+	// NonSensitiveOutputs lists the keys of the values returned by the create and rotate operations which don't contain
+	// sensitive data, e.g. the name of the database instance. Their values are persisted in the status of Database
+	// resources. Deprecated: declare the keys as non-sensitive in the outputs of the operations instead. It can't be
+	// combined with outputs declaring whether they are sensitive.
+	NonSensitiveOutputs []string `json:"nonSensitiveOutputs,omitempty"`
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum=Report;Delete
+	// +kubebuilder:default=Report
+	// OrphanPolicy specifies what happens to database instances returned by the list operation which are not bound to
+	// any Database resource. Report only lists them in the DatabaseReport of the endpoint, Delete calls the delete
+	// operation on them.
+	OrphanPolicy OrphanPolicy `json:"orphanPolicy,omitempty"`
+	// +kubebuilder:validation:Optional
+	// PasswordPolicy constrains the passwords generated by the password template function in the create and rotate
+	// operations. Defaults to passwords of 16 to 128 characters taken from any charset.
+	PasswordPolicy *database.PasswordPolicy `json:"passwordPolicy,omitempty"`
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum=Rotate;Rerender
+	// +kubebuilder:default=Rotate
+	// SecretTamperPolicy specifies what happens when a Secret is modified by someone other than the Operator. Rotate
+	// rotates the credentials, Rerender renders the Secret again from the non-sensitive outputs persisted in the status
+	// of the Database resource and falls back to Rotate if SecretFormat requires any other value.
+	SecretTamperPolicy SecretTamperPolicy `json:"secretTamperPolicy,omitempty"`
+	// +kubebuilder:validation:Optional
+	// SecretSink specifies where the credentials of Database resources are written. Defaults to a Secret in the
+	// namespace of the Database resource.
+	SecretSink *SecretSink `json:"secretSink,omitempty"`
+	// +kubebuilder:validation:Optional
+	// SecretTemplate configures the Secret written for Database resources. It can be overridden by the SecretTemplate of
+	// each Database resource.
+	SecretTemplate *database.SecretTemplate `json:"secretTemplate,omitempty"`
+	// +kubebuilder:validation:Optional
+	// ServiceBinding adds the well-known entries of the Service Binding specification, e.g. host and port, to the
+	// credentials written for Database resources. Keys of SecretFormat take precedence.
+	ServiceBinding bool `json:"serviceBinding,omitempty"`
+}
+
+// SecretSinkType is the type of a SecretSink.
+type SecretSinkType string
+
+// DriftPolicy describes how a drift between a Database resource and its database instance is handled.
+type DriftPolicy string
+
+// OrphanPolicy describes how database instances which are not bound to any Database resource are handled.
+type OrphanPolicy string
+
+// SecretTamperPolicy describes how manual changes to the Secret of a Database resource are handled.
+type SecretTamperPolicy string
+
+const (
+	// OrphanPolicyReport lists orphaned database instances in the DatabaseReport of their endpoint.
+	OrphanPolicyReport OrphanPolicy = "Report"
+	// OrphanPolicyDelete deletes orphaned database instances by calling the delete operation.
+	OrphanPolicyDelete OrphanPolicy = "Delete"
+)
+
+const (
+	// SecretTamperPolicyRotate rotates the credentials and renders a new Secret.
+	SecretTamperPolicyRotate SecretTamperPolicy = "Rotate"
+	// SecretTamperPolicyRerender renders the Secret from the non-sensitive outputs of the last operation.
+	SecretTamperPolicyRerender SecretTamperPolicy = "Rerender"
+)
+
+const (
+	// SecretSinkKubernetes writes credentials to a Secret in the namespace of the Database resource.
+	SecretSinkKubernetes SecretSinkType = "Kubernetes"
+	// SecretSinkVault writes credentials to a Vault KV version 2 secrets engine.
+	SecretSinkVault SecretSinkType = "Vault"
+	// SecretSinkFile writes credentials to a JSON file.
+	SecretSinkFile SecretSinkType = "File"
+)
+
+const (
+	// DriftPolicyAlert reports the drift through the Ready condition of the Database resource and an event.
+	DriftPolicyAlert DriftPolicy = "Alert"
+	// DriftPolicyRecreate recreates the database instance and updates its Secret.
+	DriftPolicyRecreate DriftPolicy = "Recreate"
+)
+
+// CredentialVerification configures how the credentials returned by the create and rotate operations are verified.
+type CredentialVerification struct {
+	// Dsn is a Go template rendered with the output of the operation (see SecretFormat). The result must be a DSN
+	// which can be parsed by the driver of the DatabaseClass, e.g.
+	// "postgres://{{ .Result.username }}:{{ .Result.password }}@{{ .Result.fqdn }}:{{ .Result.port }}/{{ .Result.dbName }}"
+	Dsn string `json:"dsn"`
+}
+
+// SecretSink configures where the credentials of Database resources are written.
+type SecretSink struct {
+	// +kubebuilder:validation:Enum=Kubernetes;Vault;File
+	// +kubebuilder:default=Kubernetes
+	// Type is the type of the sink. Kubernetes writes a Secret in the namespace of the Database resource, Vault writes a
+	// secret to a Vault KV version 2 secrets engine and File writes a JSON file, e.g. for testing.
+	Type SecretSinkType `json:"type"`
+	// Vault configures the Vault sink. Required if Type is Vault.
+	Vault *VaultSink `json:"vault,omitempty"`
+	// File configures the File sink. Required if Type is File.
+	File *FileSink `json:"file,omitempty"`
+}
+
+// VaultSink configures a sink writing credentials to <path>/<namespace>/<secret name> in a Vault KV version 2
+// secrets engine.
+type VaultSink struct {
+	// Address is the address of the Vault server, e.g. "https://vault:8200". Defaults to the VAULT_ADDR environment
+	// variable of the Operator.
+	Address string `json:"address,omitempty"`
+	// Mount is the path the KV secrets engine is mounted at. Defaults to "secret".
+	Mount string `json:"mount,omitempty"`
+	// Path is the path under which credentials are written.
+	Path string `json:"path,omitempty"`
+	// TokenSecretKeyRef references the token used to authenticate to Vault, read from the namespace of the Operator
+	// unless specified otherwise. Defaults to the VAULT_TOKEN environment variable of the Operator.
+	TokenSecretKeyRef *database.SecretKeyRef `json:"tokenSecretKeyRef,omitempty"`
+}
+
+// FileSink configures a sink writing credentials to <directory>/<namespace>/<secret name>.json in the file system of
+// the Operator.
+type FileSink struct {
+	Directory string `json:"directory"`
 }
 
 // +kubebuilder:object:root=true
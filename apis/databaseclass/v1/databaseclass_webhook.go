@@ -0,0 +1,252 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"fmt"
+	"github.com/bedag/kubernetes-dbaas/pkg/database"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sort"
+)
+
+// dryRunName is the name and namespace of the synthetic Database resource the templates are test-rendered with.
+const dryRunName = "dry-run"
+
+// log is for logging in this package.
+var databaseclasslog = logf.Log.WithName("databaseclass-resource-webhook")
+
+// requiredOperations are the operations every DatabaseClass must specify.
+var requiredOperations = []string{database.CreateMapKey, database.DeleteMapKey}
+
+// generatingOperations are the operations whose inputs can generate values, see database.Generator.
+var generatingOperations = []string{database.CreateMapKey, database.RotateMapKey}
+
+func (r *DatabaseClass) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		Complete()
+}
+
+//+kubebuilder:webhook:path=/validate-databaseclass-dbaas-bedag-ch-v1-databaseclass,mutating=false,failurePolicy=fail,sideEffects=None,groups=databaseclass.dbaas.bedag.ch,resources=databaseclasses,verbs=create;update,versions=v1,name=vdatabaseclass.kb.io,admissionReviewVersions={v1,v1beta1}
+
+var _ webhook.Validator = &DatabaseClass{}
+
+// ValidateCreate rejects invalid DatabaseClass resources, see validate.
+func (r *DatabaseClass) ValidateCreate() error {
+	databaseclasslog.Info("validate create", "name", r.Name)
+	return r.validate()
+}
+
+// ValidateUpdate rejects invalid DatabaseClass resources, see validate.
+func (r *DatabaseClass) ValidateUpdate(old runtime.Object) error {
+	databaseclasslog.Info("validate update", "name", r.Name)
+	return r.validate()
+}
+
+// ValidateDelete implements webhook.Validator so a webhook will be registered for the type
+func (r *DatabaseClass) ValidateDelete() error {
+	return nil
+}
+
+// validate checks that the driver of r is supported and that its create and delete operations are specified. Every
+// template of r is test-rendered with synthetic values, see database.DryRunTemplate, so that errors are reported
+// before a Database resource fails to be rendered. The keys of the result referenced by the templates must be declared
+// by the outputs of the create and rotate operations, if any, see validateOutputs.
+func (r *DatabaseClass) validate() error {
+	var allErrs field.ErrorList
+	specPath := field.NewPath("spec")
+
+	if !database.IsDriverSupported(r.Spec.Driver) {
+		allErrs = append(allErrs, field.NotSupported(specPath.Child("driver"), r.Spec.Driver, database.Drivers()))
+	}
+	for _, operation := range requiredOperations {
+		if _, exists := r.Spec.Operations[operation]; !exists {
+			allErrs = append(allErrs, field.Required(specPath.Child("operations").Key(operation),
+				"operation must be specified"))
+		}
+	}
+
+	values := r.dryRunValues()
+	for _, operation := range r.operationNames() {
+		opValues := values
+		if containsString(generatingOperations, operation) {
+			opValues = values.WithGenerator(database.NewGenerator([]byte(dryRunName), r.passwordPolicy()))
+		}
+		if err := r.Spec.Operations[operation].DryRun(opValues); err != nil {
+			allErrs = append(allErrs, field.Invalid(specPath.Child("operations").Key(operation).Child("inputs"),
+				r.Spec.Operations[operation].Inputs, err.Error()))
+		}
+	}
+
+	allErrs = append(allErrs, r.validateOutputs(specPath)...)
+
+	secretValues := values.WithOutput(database.OpOutput{})
+	if err := r.Spec.SecretFormat.DryRun(secretValues); err != nil {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("secretFormat"), r.Spec.SecretFormat, err.Error()))
+	}
+	if r.Spec.CredentialVerification != nil {
+		if err := database.DryRunTemplate(r.Spec.CredentialVerification.Dsn, secretValues); err != nil {
+			allErrs = append(allErrs, field.Invalid(specPath.Child("credentialVerification", "dsn"),
+				r.Spec.CredentialVerification.Dsn, err.Error()))
+		}
+	}
+	if r.Spec.SecretTemplate != nil && r.Spec.SecretTemplate.Name != "" {
+		// The name of the Secret is rendered with the metadata and the literal parameters only
+		nameValues := database.OpValues{Version: values.Version, Metadata: values.Metadata, Parameters: values.Parameters}
+		if err := database.DryRunTemplate(r.Spec.SecretTemplate.Name, nameValues); err != nil {
+			allErrs = append(allErrs, field.Invalid(specPath.Child("secretTemplate", "name"),
+				r.Spec.SecretTemplate.Name, err.Error()))
+		}
+	}
+
+	if len(allErrs) > 0 {
+		return apierrors.NewInvalid(schema.GroupKind{Group: "databaseclass.dbaas.bedag.ch", Kind: "DatabaseClass"},
+			r.Name, allErrs)
+	}
+	return nil
+}
+
+// validateOutputs checks that only the operations in generatingOperations declare outputs, without duplicates, and
+// that the templates rendered with their result, i.e. the SecretFormat and the DSN of the CredentialVerification of r,
+// only reference declared keys. Operations which don't declare outputs aren't checked. Outputs declaring whether they
+// are sensitive can't be combined with the deprecated NonSensitiveOutputs.
+func (r *DatabaseClass) validateOutputs(specPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	for _, operation := range r.operationNames() {
+		op := r.Spec.Operations[operation]
+		outputsPath := specPath.Child("operations").Key(operation).Child("outputs")
+		if len(op.Outputs) > 0 && !containsString(generatingOperations, operation) {
+			allErrs = append(allErrs, field.Forbidden(outputsPath,
+				"outputs can only be declared by the create and rotate operations"))
+		}
+		declared := make(map[string]bool)
+		for i, key := range op.Outputs {
+			if declared[key.Name] {
+				allErrs = append(allErrs, field.Duplicate(outputsPath.Index(i).Child("name"), key.Name))
+			}
+			declared[key.Name] = true
+			if key.Sensitive != nil && len(r.Spec.NonSensitiveOutputs) > 0 {
+				allErrs = append(allErrs, field.Forbidden(outputsPath.Index(i).Child("sensitive"),
+					"sensitive can't be combined with the deprecated nonSensitiveOutputs, declare all non-sensitive "+
+						"keys in the outputs of the operations instead"))
+			}
+		}
+	}
+
+	type resultTemplate struct {
+		path *field.Path
+		text string
+	}
+	var templates []resultTemplate
+	secretFormatKeys := make([]string, 0, len(r.Spec.SecretFormat))
+	for k := range r.Spec.SecretFormat {
+		secretFormatKeys = append(secretFormatKeys, k)
+	}
+	sort.Strings(secretFormatKeys)
+	for _, k := range secretFormatKeys {
+		templates = append(templates, resultTemplate{specPath.Child("secretFormat").Key(k), r.Spec.SecretFormat[k]})
+	}
+	if r.Spec.CredentialVerification != nil {
+		templates = append(templates, resultTemplate{specPath.Child("credentialVerification", "dsn"),
+			r.Spec.CredentialVerification.Dsn})
+	}
+	for _, t := range templates {
+		// Templates which can't be parsed are already reported by their dry run
+		referenced, err := database.ResultKeys(t.text)
+		if err != nil {
+			continue
+		}
+		for _, operation := range generatingOperations {
+			op, exists := r.Spec.Operations[operation]
+			if !exists || len(op.Outputs) == 0 {
+				continue
+			}
+			for _, key := range referenced {
+				if !isDeclared(op.Outputs, key) {
+					allErrs = append(allErrs, field.Invalid(t.path, t.text,
+						fmt.Sprintf("key '%s' is not declared in the outputs of the %s operation", key, operation)))
+				}
+			}
+		}
+	}
+	return allErrs
+}
+
+// operationNames returns the sorted names of the operations of r.
+func (r *DatabaseClass) operationNames() []string {
+	operations := make([]string, 0, len(r.Spec.Operations))
+	for operation := range r.Spec.Operations {
+		operations = append(operations, operation)
+	}
+	sort.Strings(operations)
+	return operations
+}
+
+// dryRunValues returns the synthetic values the templates of r are test-rendered with, describing a Database resource
+// named dryRunName bound to r.
+func (r *DatabaseClass) dryRunValues() database.OpValues {
+	return database.OpValues{
+		Version: database.TemplateContextVersion,
+		Metadata: map[string]interface{}{
+			"name":        dryRunName,
+			"namespace":   dryRunName,
+			"labels":      map[string]interface{}{},
+			"annotations": map[string]interface{}{},
+		},
+		Parameters:    map[string]string{},
+		Namespace:     database.NamespaceValues{Name: dryRunName, Labels: map[string]string{}},
+		Endpoint:      database.EndpointValues{Name: dryRunName},
+		DatabaseClass: r.Name,
+		ClusterID:     dryRunName,
+		InstanceName:  database.InstanceName(r.Spec.Driver, dryRunName, dryRunName, dryRunName),
+		Outputs:       map[string]string{},
+	}
+}
+
+// passwordPolicy returns the PasswordPolicy of r, or the default one if r doesn't specify any.
+func (r *DatabaseClass) passwordPolicy() database.PasswordPolicy {
+	if r.Spec.PasswordPolicy == nil {
+		return database.PasswordPolicy{}
+	}
+	return *r.Spec.PasswordPolicy
+}
+
+// isDeclared returns true if the key name has been found in declared.
+func isDeclared(declared []database.OutputKey, name string) bool {
+	for _, key := range declared {
+		if key.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// containsString returns true if s has been found in list.
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,90 @@
+package v1
+
+import (
+	"github.com/bedag/kubernetes-dbaas/pkg/database"
+	. "github.com/bedag/kubernetes-dbaas/pkg/test"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"io/ioutil"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	"path/filepath"
+)
+
+var _ = Describe(FormatTestDesc(Unit, "DatabaseClass webhook"), func() {
+	var dbc DatabaseClass
+	BeforeEach(func() {
+		dat, err := ioutil.ReadFile(filepath.Join("..", "..", "..", "testdata", "resources", "dbclass-postgres.yaml"))
+		Expect(err).ToNot(HaveOccurred())
+		dbc = DatabaseClass{}
+		Expect(yaml.Unmarshal(dat, &dbc)).To(Succeed())
+	})
+	It("should accept a valid DatabaseClass", func() {
+		Expect(dbc.ValidateCreate()).To(Succeed())
+		Expect(dbc.ValidateUpdate(dbc.DeepCopy())).To(Succeed())
+	})
+	It("should reject an unknown driver", func() {
+		dbc.Spec.Driver = "oracle"
+		err := dbc.ValidateCreate()
+		Expect(apierrors.IsInvalid(err)).To(BeTrue())
+		Expect(err.Error()).To(ContainSubstring("spec.driver"))
+	})
+	It("should reject a DatabaseClass without create or delete operation", func() {
+		delete(dbc.Spec.Operations, "delete")
+		err := dbc.ValidateCreate()
+		Expect(apierrors.IsInvalid(err)).To(BeTrue())
+		Expect(err.Error()).To(ContainSubstring("spec.operations[delete]"))
+	})
+	It("should reject templates which can't be rendered", func() {
+		rotate := dbc.Spec.Operations["rotate"]
+		rotate.Inputs = map[string]string{"k8sName": "{{ .Metadata.name "}
+		dbc.Spec.Operations["rotate"] = rotate
+		dbc.Spec.SecretFormat["username"] = "{{ .Result.username | unknown }}"
+		err := dbc.ValidateUpdate(dbc.DeepCopy())
+		Expect(apierrors.IsInvalid(err)).To(BeTrue())
+		Expect(err.Error()).To(ContainSubstring("spec.operations[rotate].inputs"))
+		Expect(err.Error()).To(ContainSubstring("spec.secretFormat"))
+	})
+	It("should reject generated passwords violating the password policy", func() {
+		create := dbc.Spec.Operations["create"]
+		create.Inputs["password"] = `{{ password 64 "alnum" }}`
+		dbc.Spec.Operations["create"] = create
+		Expect(dbc.ValidateCreate()).To(Succeed())
+		dbc.Spec.PasswordPolicy = &database.PasswordPolicy{MaxLength: 32}
+		Expect(apierrors.IsInvalid(dbc.ValidateCreate())).To(BeTrue())
+	})
+	It("should reject templates referencing keys which aren't declared in the outputs", func() {
+		dbc.Spec.SecretFormat["host"] = `{{ index .Result "host" }}`
+		err := dbc.ValidateCreate()
+		Expect(apierrors.IsInvalid(err)).To(BeTrue())
+		Expect(err.Error()).To(ContainSubstring("spec.secretFormat[host]"))
+		Expect(err.Error()).To(ContainSubstring("key 'host' is not declared in the outputs of the create operation"))
+		Expect(err.Error()).To(ContainSubstring("key 'host' is not declared in the outputs of the rotate operation"))
+		// Operations which don't declare outputs aren't checked
+		for _, operation := range []string{"create", "rotate"} {
+			op := dbc.Spec.Operations[operation]
+			op.Outputs = nil
+			dbc.Spec.Operations[operation] = op
+		}
+		Expect(dbc.ValidateCreate()).To(Succeed())
+	})
+	It("should reject outputs declared twice or by other operations", func() {
+		create := dbc.Spec.Operations["create"]
+		create.Outputs = append(create.Outputs, database.OutputKey{Name: "username", Optional: true})
+		dbc.Spec.Operations["create"] = create
+		exists := dbc.Spec.Operations["exists"]
+		exists.Outputs = []database.OutputKey{{Name: "exists"}}
+		dbc.Spec.Operations["exists"] = exists
+		err := dbc.ValidateCreate()
+		Expect(apierrors.IsInvalid(err)).To(BeTrue())
+		Expect(err.Error()).To(ContainSubstring("spec.operations[create].outputs[6].name"))
+		Expect(err.Error()).To(ContainSubstring("spec.operations[exists].outputs"))
+	})
+	It("should reject outputs declaring whether they are sensitive along with the deprecated nonSensitiveOutputs", func() {
+		// dbName is declared with sensitive set to false
+		dbc.Spec.NonSensitiveOutputs = []string{"fqdn"}
+		err := dbc.ValidateCreate()
+		Expect(apierrors.IsInvalid(err)).To(BeTrue())
+		Expect(err.Error()).To(ContainSubstring("spec.operations[create].outputs[2].sensitive"))
+	})
+})
@@ -0,0 +1,12 @@
+package v1
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"testing"
+)
+
+func TestDatabaseClass(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "DatabaseClass API suite")
+}
@@ -25,6 +25,21 @@ import (
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CredentialVerification) DeepCopyInto(out *CredentialVerification) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CredentialVerification.
+func (in *CredentialVerification) DeepCopy() *CredentialVerification {
+	if in == nil {
+		return nil
+	}
+	out := new(CredentialVerification)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *DatabaseClass) DeepCopyInto(out *DatabaseClass) {
 	*out = *in
@@ -100,6 +115,31 @@ func (in *DatabaseClassSpec) DeepCopyInto(out *DatabaseClassSpec) {
 			(*out)[key] = val
 		}
 	}
+	if in.CredentialVerification != nil {
+		in, out := &in.CredentialVerification, &out.CredentialVerification
+		*out = new(CredentialVerification)
+		**out = **in
+	}
+	if in.NonSensitiveOutputs != nil {
+		in, out := &in.NonSensitiveOutputs, &out.NonSensitiveOutputs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.PasswordPolicy != nil {
+		in, out := &in.PasswordPolicy, &out.PasswordPolicy
+		*out = new(database.PasswordPolicy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.SecretSink != nil {
+		in, out := &in.SecretSink, &out.SecretSink
+		*out = new(SecretSink)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.SecretTemplate != nil {
+		in, out := &in.SecretTemplate, &out.SecretTemplate
+		*out = new(database.SecretTemplate)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DatabaseClassSpec.
@@ -111,3 +151,63 @@ func (in *DatabaseClassSpec) DeepCopy() *DatabaseClassSpec {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FileSink) DeepCopyInto(out *FileSink) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FileSink.
+func (in *FileSink) DeepCopy() *FileSink {
+	if in == nil {
+		return nil
+	}
+	out := new(FileSink)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SecretSink) DeepCopyInto(out *SecretSink) {
+	*out = *in
+	if in.Vault != nil {
+		in, out := &in.Vault, &out.Vault
+		*out = new(VaultSink)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.File != nil {
+		in, out := &in.File, &out.File
+		*out = new(FileSink)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SecretSink.
+func (in *SecretSink) DeepCopy() *SecretSink {
+	if in == nil {
+		return nil
+	}
+	out := new(SecretSink)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VaultSink) DeepCopyInto(out *VaultSink) {
+	*out = *in
+	if in.TokenSecretKeyRef != nil {
+		in, out := &in.TokenSecretKeyRef, &out.TokenSecretKeyRef
+		*out = new(database.SecretKeyRef)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VaultSink.
+func (in *VaultSink) DeepCopy() *VaultSink {
+	if in == nil {
+		return nil
+	}
+	out := new(VaultSink)
+	in.DeepCopyInto(out)
+	return out
+}
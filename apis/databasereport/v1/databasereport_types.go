@@ -0,0 +1,65 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// DatabaseReportStatus defines the observed state of the database instances of an endpoint.
+type DatabaseReportStatus struct {
+	// Endpoint is the name of the endpoint the report refers to
+	Endpoint string `json:"endpoint"`
+	// LastCheckTime is the time of the last list operation performed on the endpoint
+	LastCheckTime metav1.Time `json:"lastCheckTime,omitempty"`
+	// OrphanedDatabases lists the database instances returned by the list operation which are not bound to any
+	// Database resource
+	OrphanedDatabases []string `json:"orphanedDatabases,omitempty"`
+	// MissingDatabases lists the Database resources bound to the endpoint whose database instance was not returned by
+	// the list operation, in the format namespace/name
+	MissingDatabases []string `json:"missingDatabases,omitempty"`
+	// DeletedDatabases lists the orphaned database instances deleted during the last check, see the orphanPolicy of
+	// DatabaseClass
+	DeletedDatabases []string `json:"deletedDatabases,omitempty"`
+	// Error contains the error generated during the last check, if any
+	Error string `json:"error,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:path=databasereports,scope=Cluster,shortName=dbr
+// +kubebuilder:printcolumn:JSONPath=.status.endpoint,description="The endpoint the report refers to",name="Endpoint",type=string
+// +kubebuilder:printcolumn:JSONPath=.status.lastCheckTime,description="The time of the last check",name="Last Check",type=date
+// DatabaseReport is the Schema for the databasereports API. DatabaseReports are written by the Operator only, one for
+// each endpoint whose DatabaseClass defines a list operation.
+type DatabaseReport struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Status DatabaseReportStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// DatabaseReportList contains a list of DatabaseReport
+type DatabaseReportList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []DatabaseReport `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&DatabaseReport{}, &DatabaseReportList{})
+}
@@ -18,11 +18,14 @@ package cmd
 import (
 	"context"
 	"github.com/bedag/kubernetes-dbaas/internal/logging"
+	"github.com/bedag/kubernetes-dbaas/internal/metrics"
+	"github.com/bedag/kubernetes-dbaas/internal/tracing"
 	"github.com/bedag/kubernetes-dbaas/pkg/database"
+	"github.com/bedag/kubernetes-dbaas/pkg/dsnprovider"
+	"github.com/bedag/kubernetes-dbaas/pkg/journal"
 	"github.com/bedag/kubernetes-dbaas/pkg/pool"
 	"github.com/go-logr/logr"
 	"io/ioutil"
-	v1 "k8s.io/api/core/v1"
 	"strings"
 	"time"
 
@@ -31,11 +34,14 @@ import (
 	operatorconfigv1 "github.com/bedag/kubernetes-dbaas/apis/config/v1"
 	databasev1 "github.com/bedag/kubernetes-dbaas/apis/database/v1"
 	databaseclassv1 "github.com/bedag/kubernetes-dbaas/apis/databaseclass/v1"
+	databasereportv1 "github.com/bedag/kubernetes-dbaas/apis/databasereport/v1"
 	controllers "github.com/bedag/kubernetes-dbaas/controllers/database"
 
 	//"github.com/bedag/kubernetes-dbaas/pkg/pool"
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
@@ -43,6 +49,7 @@ import (
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
+	crmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
@@ -52,13 +59,22 @@ import (
 )
 
 const (
-	LoadConfigKey       = "load-config"
-	DebugKey            = "debug"
-	WebhookDisableKey   = "disable-webhooks"
-	ZapLogLevelKey      = "log-level"
-	StacktraceEnableKey = "enable-stacktrace"
-	RpsKey              = "rps"
-	KeepaliveKey        = "keepalive"
+	LoadConfigKey          = "load-config"
+	DebugKey               = "debug"
+	WebhookDisableKey      = "disable-webhooks"
+	ZapLogLevelKey         = "log-level"
+	StacktraceEnableKey    = "enable-stacktrace"
+	RpsKey                 = "rps"
+	KeepaliveKey           = "keepalive"
+	ResyncIntervalKey      = "resyncInterval"
+	ConcurrencyKey         = "maxConcurrentReconciles"
+	EndpointConcurrencyKey = "maxConcurrentReconcilesPerEndpoint"
+	JournalKeySecretKey    = "journalKeySecret"
+	RetryBaseDelayKey      = "retryBaseDelay"
+	RetryMaxDelayKey       = "retryMaxDelay"
+	RequiredEndpointsKey   = "requiredEndpoints"
+	DsnRefreshIntervalKey  = "dsnRefreshInterval"
+	ClusterIDKey           = "clusterId"
 
 	// Flag overrides for flags specified in OperatorConfig
 	MetricsBindAddressKey     = "metrics.bindAddress"
@@ -67,8 +83,16 @@ const (
 	LeaderElectResName        = "leaderElection.resourceName"
 	LeaderElectResNamespace   = "leaderElection.resourceNamespace"
 	WebhookPortKey            = "webhook.port"
+	TracingEnabledKey         = "tracing.enabled"
+	TracingEndpointKey        = "tracing.endpoint"
+	TracingInsecureKey        = "tracing.insecure"
+	TracingSamplingKey        = "tracing.samplingPercentage"
 )
 
+// defaultGracefulShutdownTimeout is the time given by the manager to its runnables to stop, unless configured
+// otherwise in gracefulShutDown.
+const defaultGracefulShutdownTimeout = 30 * time.Second
+
 var (
 	dbmsPool   pool.DbmsPool
 	kubeClient client.Client
@@ -91,12 +115,17 @@ var rootCmd = &cobra.Command{
 			os.Exit(1)
 		}
 		fmt.Println("namespace " + Namespace())
+		// DSNs are resolved once, the endpoints and the controllers share the result
+		dbmsList, dsnProviders, err := getDbmsList()
+		if err != nil {
+			fatalError(err, "error while reading dbms configuration")
+		}
 		setupLog.Info("registering endpoints...")
-		registerEndpoints()
+		registerEndpoints(dbmsList)
 		setupLog.Info("endpoints registered")
 
 		setupLog.Info("starting operator")
-		loadOperator()
+		loadOperator(dbmsList, dsnProviders)
 	},
 }
 
@@ -115,6 +144,7 @@ func init() {
 	utilruntime.Must(operatorconfigv1.AddToScheme(scheme))
 	utilruntime.Must(databasev1.AddToScheme(scheme))
 	utilruntime.Must(databaseclassv1.AddToScheme(scheme))
+	utilruntime.Must(databasereportv1.AddToScheme(scheme))
 	//+kubebuilder:scaffold:scheme
 }
 
@@ -128,10 +158,23 @@ func initFlags() {
 		"Enabling this will ensure there is only one active controller manager")
 	rootCmd.PersistentFlags().String(LeaderElectResName, "bfa62c96.dbaas.bedag.ch", "The resource name to lock during election cycles")
 	rootCmd.PersistentFlags().Int(WebhookPortKey, 9443, "The port the webhook server binds to")
+	rootCmd.PersistentFlags().Bool(TracingEnabledKey, false, "Enable the export of OpenTelemetry traces through OTLP")
+	rootCmd.PersistentFlags().String(TracingEndpointKey, "", "The address of the OTLP gRPC receiver traces are exported to")
+	rootCmd.PersistentFlags().Bool(TracingInsecureKey, false, "Disable TLS when connecting to the OTLP receiver")
+	rootCmd.PersistentFlags().Int(TracingSamplingKey, 100, "The percentage of traces which are sampled")
 	rootCmd.PersistentFlags().Int(ZapLogLevelKey, 0, "The verbosity of the logging output. Can be one out of: 0 info, 1 debug, 2 trace. If debug mode is on, defaults to 1")
 	rootCmd.PersistentFlags().Bool(StacktraceEnableKey, false, "Enable stacktrace printing in logger errors")
 	rootCmd.PersistentFlags().Int(RpsKey, 0, "The number of operation executed per second per endpoint. If set to 0, operations won't be rate-limited.")
 	rootCmd.PersistentFlags().Int(KeepaliveKey, 30, "The interval in seconds between connection checks for the endpoints")
+	rootCmd.PersistentFlags().Int(ResyncIntervalKey, 0, "The interval in seconds between existence checks of database instances, usage reports and orphan detection runs. If set to 0, checks won't be performed.")
+	rootCmd.PersistentFlags().Int(ConcurrencyKey, 1, "The maximum number of Database resources reconciled concurrently")
+	rootCmd.PersistentFlags().Int(EndpointConcurrencyKey, 0, "The maximum number of Database resources bound to the same endpoint reconciled concurrently. If set to 0, there is no limit.")
+	rootCmd.PersistentFlags().String(JournalKeySecretKey, "kubernetes-dbaas-journal-key", "The name of the Secret in the Operator's namespace holding the key used to encrypt the operation journal. It is created if it doesn't exist. If set to an empty string, operations won't be journaled.")
+	rootCmd.PersistentFlags().Int(RetryBaseDelayKey, 1, "The delay in seconds before the first retry of a Database resource whose reconciliation failed with a transient error. It doubles at each failure.")
+	rootCmd.PersistentFlags().Int(RetryMaxDelayKey, 300, "The maximum delay in seconds between retries of a Database resource whose reconciliation failed with a transient error")
+	rootCmd.PersistentFlags().StringSlice(RequiredEndpointsKey, nil, "The names of the endpoints which must be reachable for the Operator to report ready. If empty, the Operator reports ready as long as at least one endpoint is reachable.")
+	rootCmd.PersistentFlags().String(ClusterIDKey, "", "The stable identifier of the cluster available to templates as .ClusterID. If empty, the UID of the kube-system namespace is used.")
+	rootCmd.PersistentFlags().Int(DsnRefreshIntervalKey, 60, "The interval in seconds between resolutions of the endpoint DSNs from their sources. Endpoints whose DSN changed are reconnected. If set to 0, DSNs are only resolved at startup.")
 	currentNs := Namespace()
 	rootCmd.PersistentFlags().String(LeaderElectResNamespace, currentNs, "The namespace in which to create the leader election lock resource")
 	// Bind all flags to Viper
@@ -193,8 +236,9 @@ func initLogger() {
 	ctrl.SetLogger(logger)
 }
 
-// loadOperator registers all the Manager's controllers, webhooks and starts them.
-func loadOperator() {
+// loadOperator registers all the Manager's controllers, webhooks and starts them. dbmsList must contain the resolved
+// DSNs of the endpoints, which are refreshed from dsnProviders, see getDbmsList.
+func loadOperator(dbmsList database.DbmsList, dsnProviders map[string]dsnprovider.DsnProvider) {
 	// Load the Operator configuration
 	// Set CLI flags given by user or set by default
 	var err error
@@ -222,34 +266,114 @@ func loadOperator() {
 	// TODO: Check status of https://github.com/kubernetes-sigs/controller-runtime/issues/1463
 	options.LeaderElection = viper.GetBool(LeaderElectEnableKey)
 
+	// Initialize tracing, spans are discarded if tracing is disabled
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Config{
+		Enabled:       viper.GetBool(TracingEnabledKey),
+		Endpoint:      viper.GetString(TracingEndpointKey),
+		Insecure:      viper.GetBool(TracingInsecureKey),
+		SamplingRatio: float64(viper.GetInt(TracingSamplingKey)) / 100,
+	})
+	if err != nil {
+		fatalError(err, "unable to set up tracing")
+	}
+	defer func() {
+		if err := shutdownTracing(context.Background()); err != nil {
+			setupLog.Error(err, "unable to flush traces")
+		}
+	}()
+
 	// Initialize manager
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), options)
 	if err != nil {
 		fatalError(err, "unable to initialize manager")
 	}
 
+	// Expose the number of Database resources per phase, listed from the cache of the manager
+	if err := crmetrics.Registry.Register(metrics.NewDatabaseCollector(mgr.GetClient())); err != nil {
+		fatalError(err, "unable to register database metrics")
+	}
+
 	// Setup controllers
-	dbmsList, err := getDbmsList()
+	operationJournal, err := getJournal()
 	if err != nil {
-		fatalError(err, "unable to get dbms list")
+		fatalError(err, "unable to initialize operation journal")
+	}
+
+	clusterID, err := getClusterID()
+	if err != nil {
+		fatalError(err, "unable to determine cluster id")
 	}
 
 	if err = (&controllers.DatabaseReconciler{
-		Client:        mgr.GetClient(),
-		Log:           ctrl.Log.WithName("controllers").WithName("Database"),
-		Scheme:        mgr.GetScheme(),
-		EventRecorder: mgr.GetEventRecorderFor(controllers.DatabaseControllerName),
-		DbmsList:      dbmsList,
-		Pool:          dbmsPool,
+		Client:                             mgr.GetClient(),
+		Log:                                ctrl.Log.WithName("controllers").WithName("Database"),
+		Scheme:                             mgr.GetScheme(),
+		EventRecorder:                      mgr.GetEventRecorderFor(controllers.DatabaseControllerName),
+		DbmsList:                           dbmsList,
+		Pool:                               dbmsPool,
+		ResyncInterval:                     time.Duration(viper.GetInt(ResyncIntervalKey)) * time.Second,
+		MaxConcurrentReconciles:            viper.GetInt(ConcurrencyKey),
+		MaxConcurrentReconcilesPerEndpoint: viper.GetInt(EndpointConcurrencyKey),
+		Journal:                            operationJournal,
+		Namespace:                          Namespace(),
+		ClusterID:                          clusterID,
+		RetryBaseDelay:                     time.Duration(viper.GetInt(RetryBaseDelayKey)) * time.Second,
+		RetryMaxDelay:                      time.Duration(viper.GetInt(RetryMaxDelayKey)) * time.Second,
 	}).SetupWithManager(mgr); err != nil {
 		fatalError(err, "unable to create controller", "controller", "Database")
 	}
 
+	// Tie the connections to the endpoints to the lifecycle of the manager. In-flight operations are given the same time
+	// to complete as the other runnables of the manager.
+	drainTimeout := defaultGracefulShutdownTimeout
+	if options.GracefulShutdownTimeout != nil {
+		drainTimeout = *options.GracefulShutdownTimeout
+	}
+	if err = mgr.Add(&pool.Runnable{
+		Pool:              dbmsPool,
+		Log:               ctrl.Log.WithName("pool"),
+		KeepaliveInterval: time.Duration(viper.GetInt(KeepaliveKey)) * time.Second,
+		DrainTimeout:      drainTimeout,
+	}); err != nil {
+		fatalError(err, "unable to set up endpoint pool")
+	}
+
+	// Reconnect to the endpoints whose DSN changed
+	if refreshInterval := viper.GetInt(DsnRefreshIntervalKey); refreshInterval > 0 {
+		dsns := make(map[string]database.Dsn)
+		for _, dbms := range dbmsList {
+			for _, endpoint := range dbms.Endpoints {
+				dsns[endpoint.Name] = endpoint.Dsn
+			}
+		}
+		if err = mgr.Add(dsnprovider.NewWatcher(dsnProviders, dsns, time.Duration(refreshInterval)*time.Second,
+			dbmsPool.Update, ctrl.Log.WithName("dsnprovider"))); err != nil {
+			fatalError(err, "unable to set up dsn watcher")
+		}
+	}
+
+	// Setup orphan detection, performed at the same interval of the existence checks
+	if resyncInterval := viper.GetInt(ResyncIntervalKey); resyncInterval > 0 {
+		if err = mgr.Add(&controllers.OrphanDetector{
+			Client:    mgr.GetClient(),
+			Log:       ctrl.Log.WithName("controllers").WithName("OrphanDetector"),
+			DbmsList:  dbmsList,
+			Pool:      dbmsPool,
+			Interval:  time.Duration(resyncInterval) * time.Second,
+			ClusterID: clusterID,
+		}); err != nil {
+			fatalError(err, "unable to create orphan detector")
+		}
+	}
+
 	// Setup webhooks
 	if !viper.GetBool(WebhookDisableKey) {
-		if err = (&databasev1.Database{}).SetupWebhookWithManager(mgr); err != nil {
+		if err = (&databasev1.Database{}).SetupWebhookWithManager(mgr, controllers.NewInstanceNamer(dbmsPool, clusterID)); err != nil {
 			fatalError(err, "unable to create webhook", "webhook", "Database")
 		}
+		if err = (&databaseclassv1.DatabaseClass{}).SetupWebhookWithManager(mgr); err != nil {
+			fatalError(err, "unable to create webhook", "webhook", "DatabaseClass")
+		}
 	}
 
 	//+kubebuilder:scaffold:builder
@@ -261,6 +385,14 @@ func loadOperator() {
 	if err := mgr.AddReadyzCheck("readyz", healthz.Ping); err != nil {
 		fatalError(err, "unable to set up ready check")
 	}
+	if err := mgr.AddReadyzCheck("endpoints", dbmsPool.ReadyChecker(viper.GetStringSlice(RequiredEndpointsKey))); err != nil {
+		fatalError(err, "unable to set up endpoints ready check")
+	}
+
+	// Expose the status of the endpoints alongside the metrics
+	if err := mgr.AddMetricsExtraHandler("/endpoints", dbmsPool.StatusHandler()); err != nil {
+		fatalError(err, "unable to set up endpoints debug handler")
+	}
 
 	// Finally start controllers and webhooks
 	setupLog.Info("starting manager")
@@ -270,59 +402,108 @@ func loadOperator() {
 }
 
 // getDbmsList returns the dbms endpoint list of the operator as specified in the operator's configuration file stored
-// in Viper.
-func getDbmsList() (database.DbmsList, error) {
-	dbmsList := database.DbmsList{}
-	if err := viper.UnmarshalKey(database.DbmsConfigKey, &dbmsList); err != nil {
-		return nil, err
+// in Viper, along with the DsnProvider of each endpoint. The DSN of each endpoint is resolved from its source, see
+// getDsnProviders.
+func getDbmsList() (database.DbmsList, map[string]dsnprovider.DsnProvider, error) {
+	dbmsList, err := readDbmsList()
+	if err != nil {
+		return nil, nil, err
+	}
+	providers, err := getDsnProviders(dbmsList)
+	if err != nil {
+		return nil, nil, err
 	}
 	for i := 0; i < len(dbmsList); i++ {
 		dbms := &dbmsList[i]
 		for j := 0; j < len(dbms.Endpoints); j++ {
 			endpoint := &dbms.Endpoints[j]
-			if endpoint.Dsn == "" {
-				if endpoint.SecretKeyRef.Name == "" || endpoint.SecretKeyRef.Key == "" {
-					return nil, fmt.Errorf("unable to retrieve DSN for endpoint '%s'", endpoint.Name)
-				}
-				ns := Namespace()
-				secret := v1.Secret{}
-				err := kubeClient.Get(context.Background(), client.ObjectKey{Namespace: ns, Name: endpoint.SecretKeyRef.Name}, &secret)
-				if err != nil {
-					return nil, fmt.Errorf("unable to read key '%s' from secret '%s/%s' for "+
-						"endpoint '%s': %s", endpoint.SecretKeyRef.Key, ns, endpoint.SecretKeyRef.Name, endpoint.Name, err)
-				}
-				endpoint.Dsn = database.Dsn(secret.Data[endpoint.SecretKeyRef.Key])
+			dsn, err := providers[endpoint.Name].Dsn(context.Background())
+			if err != nil {
+				return nil, nil, fmt.Errorf("unable to retrieve DSN for endpoint '%s': %s", endpoint.Name, err)
 			}
+			endpoint.Dsn = dsn
 		}
 	}
 
+	return dbmsList, providers, nil
+}
+
+// readDbmsList returns the dbms endpoint list of the operator as specified in the operator's configuration file stored
+// in Viper, without resolving the DSNs of the endpoints.
+func readDbmsList() (database.DbmsList, error) {
+	dbmsList := database.DbmsList{}
+	if err := viper.UnmarshalKey(database.DbmsConfigKey, &dbmsList); err != nil {
+		return nil, err
+	}
 	return dbmsList, nil
 }
 
-// RegisterEndpoints attempts to register the endpoints specified in the operator configuration loaded from LoadConfig.
-//
-// See pool.Register for details.
-func registerEndpoints() {
-	dbmsList, err := getDbmsList()
+// getDsnProviders returns the DsnProvider of each endpoint of dbmsList, indexed by endpoint name. Secrets are read from
+// the namespace of the Operator unless specified otherwise.
+func getDsnProviders(dbmsList database.DbmsList) (map[string]dsnprovider.DsnProvider, error) {
+	providers := make(map[string]dsnprovider.DsnProvider)
+	for _, dbms := range dbmsList {
+		for _, endpoint := range dbms.Endpoints {
+			provider, err := dsnprovider.ForEndpoint(endpoint, kubeClient, Namespace())
+			if err != nil {
+				return nil, err
+			}
+			providers[endpoint.Name] = provider
+		}
+	}
+	return providers, nil
+}
+
+// readOperatorSecret returns the value referenced by ref, read from a Secret in the namespace of the Operator unless
+// specified otherwise. See Namespace.
+func readOperatorSecret(ref database.SecretKeyRef) ([]byte, error) {
+	return dsnprovider.ReadSecret(context.Background(), kubeClient, ref, Namespace())
+}
+
+// getJournal returns the operation journal of the Operator, whose encryption key is stored in the Secret named after
+// JournalKeySecretKey. It returns nil if no Secret name is set.
+func getJournal() (*journal.Journal, error) {
+	secretName := viper.GetString(JournalKeySecretKey)
+	if secretName == "" {
+		return nil, nil
+	}
+	key, err := journal.LoadOrCreateKey(context.Background(), kubeClient, client.ObjectKey{Namespace: Namespace(), Name: secretName})
 	if err != nil {
-		fatalError(err, "error while reading dbms configuration")
+		return nil, fmt.Errorf("unable to load journal key from secret '%s/%s': %s", Namespace(), secretName, err)
 	}
+	return journal.New(kubeClient, key)
+}
+
+// getClusterID returns the stable identifier of the cluster available to templates. It is the value of ClusterIDKey if
+// set, the UID of the kube-system namespace otherwise.
+func getClusterID() (string, error) {
+	if clusterID := viper.GetString(ClusterIDKey); clusterID != "" {
+		return clusterID, nil
+	}
+	namespace := corev1.Namespace{}
+	if err := kubeClient.Get(context.Background(), client.ObjectKey{Name: metav1.NamespaceSystem}, &namespace); err != nil {
+		return "", fmt.Errorf("unable to read namespace '%s': %s", metav1.NamespaceSystem, err)
+	}
+	return string(namespace.UID), nil
+}
+
+// RegisterEndpoints attempts to register the endpoints of dbmsList, whose DSNs must be resolved, see getDbmsList.
+//
+// See pool.Register for details.
+func registerEndpoints(dbmsList database.DbmsList) {
 	dbmsPool = pool.NewDbmsPool(viper.GetInt(RpsKey))
 	for _, dbms := range dbmsList {
 		dbClass := databaseclassv1.DatabaseClass{}
-		err = kubeClient.Get(context.Background(), client.ObjectKey{Namespace: "", Name: dbms.DatabaseClassName}, &dbClass)
+		err := kubeClient.Get(context.Background(), client.ObjectKey{Namespace: "", Name: dbms.DatabaseClassName}, &dbClass)
 		if err != nil {
 			fatalError(err, "problem getting databaseclass from api server", "databaseClassName",
 				dbms.DatabaseClassName)
 		}
 
-		if err := dbmsPool.RegisterDbms(dbms, dbClass.Spec.Driver); err != nil {
+		if err := dbmsPool.RegisterDbms(dbms, dbClass.Spec.Driver, readOperatorSecret); err != nil {
 			fatalError(err, "problem registering dbms endpoint", "databaseClassName", dbClass.Name)
 		}
 	}
-	if keepaliveInterval := viper.GetInt(KeepaliveKey); keepaliveInterval > 0 {
-		dbmsPool.Keepalive(time.Duration(keepaliveInterval)*time.Second, setupLog)
-	}
 }
 
 func fatalError(err error, msg string, values ...interface{}) {
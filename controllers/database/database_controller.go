@@ -19,12 +19,19 @@ package controllers
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/bedag/kubernetes-dbaas/internal/logging"
+	"github.com/bedag/kubernetes-dbaas/internal/metrics"
+	"github.com/bedag/kubernetes-dbaas/internal/tracing"
 	"github.com/bedag/kubernetes-dbaas/pkg/database"
+	"github.com/bedag/kubernetes-dbaas/pkg/journal"
 	"github.com/bedag/kubernetes-dbaas/pkg/pool"
+	"github.com/bedag/kubernetes-dbaas/pkg/secretsink"
 	. "github.com/bedag/kubernetes-dbaas/pkg/typeutil"
 	"github.com/go-logr/logr"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 	corev1 "k8s.io/api/core/v1"
 	k8sError "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/meta"
@@ -32,14 +39,21 @@ import (
 	"k8s.io/apimachinery/pkg/runtime"
 	genericregistry "k8s.io/apiserver/pkg/registry/generic/registry"
 	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/workqueue"
 	"reflect"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 	"strings"
+	"time"
 
 	databasev1 "github.com/bedag/kubernetes-dbaas/apis/database/v1"
 	databaseclassv1 "github.com/bedag/kubernetes-dbaas/apis/databaseclass/v1"
@@ -63,6 +77,23 @@ type ReconcileError struct {
 	Message        string
 	Err            error
 	AdditionalInfo []interface{}
+	// Terminal marks errors which can't be solved by retrying. See IsTerminal.
+	Terminal bool
+}
+
+// terminalReasons are the reasons of errors which can only be solved by changing the spec of the Database resource or
+// its DatabaseClass, e.g. a missing operation or a template which can't be rendered. Errors solved by changing anything
+// else, e.g. the configuration of the Operator or a conflicting Secret, are retried.
+var terminalReasons = map[string]bool{
+	RsnCredVerifyRenderFail: true,
+	RsnDbMetaParseFail:      true,
+	RsnDbSpecParseFail:      true,
+	RsnOpNotSupported:       true,
+	RsnOpOutputInvalid:      true,
+	RsnOpRenderFail:         true,
+	RsnSecretNameInvalid:    true,
+	RsnSecretRenderFail:     true,
+	RsnSecretSinkInvalid:    true,
 }
 
 // DatabaseReconciler reconciles a Database object
@@ -73,29 +104,77 @@ type DatabaseReconciler struct {
 	EventRecorder record.EventRecorder
 	DbmsList      database.DbmsList
 	Pool          pool.Pool
+	// ResyncInterval is the interval between existence checks of database instances. See resync.
+	ResyncInterval time.Duration
+	// MaxConcurrentReconciles is the maximum number of Database resources reconciled concurrently. Defaults to 1.
+	MaxConcurrentReconciles int
+	// MaxConcurrentReconcilesPerEndpoint is the maximum number of Database resources bound to the same endpoint
+	// reconciled concurrently. Database resources exceeding the limit are requeued, so that a slow endpoint cannot
+	// occupy all the workers. If set to 0, there is no limit.
+	MaxConcurrentReconcilesPerEndpoint int
+	// RetryBaseDelay and RetryMaxDelay configure the exponential backoff of Database resources whose reconciliation
+	// failed with a transient error. The delay starts at RetryBaseDelay and doubles at each failure up to RetryMaxDelay.
+	// If any of them is 0, the default backoff of controller-runtime is used.
+	RetryBaseDelay time.Duration
+	RetryMaxDelay  time.Duration
+	// Journal records the operations executed for Database resources, so that their outputs are not lost if the
+	// Operator crashes before writing them into the credentials Secret. If nil, operations are not recorded.
+	Journal *journal.Journal
+	// Namespace is the namespace of the Operator. Secrets referenced by DatabaseClasses without namespace, e.g. the
+	// token of a Vault SecretSink, are read from it.
+	Namespace string
+	// ClusterID is a stable identifier of the cluster, available to templates as .ClusterID.
+	ClusterID string
+
+	endpointLimiter *endpointLimiter
 }
 
-var logger logr.Logger
-
 // +kubebuilder:rbac:groups=database.dbaas.bedag.ch,resources=databases,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=database.dbaas.bedag.ch,resources=databases/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=database.dbaas.bedag.ch,resources=databases/finalizers,verbs=update
 // +kubebuilder:rbac:groups=databaseclass.dbaas.bedag.ch,resources=databaseclasses,verbs=get;list;watch
 // +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch;create;update;delete
+// +kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch
 // SetupWithManager creates the controller responsible for Database resources by means of a ctrl.Manager.
 func (r *DatabaseReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	r.endpointLimiter = newEndpointLimiter(r.MaxConcurrentReconcilesPerEndpoint)
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(), &databasev1.Database{}, paramsFromIndexKey,
+		paramsFromReferences); err != nil {
+		return err
+	}
+	options := controller.Options{MaxConcurrentReconciles: r.MaxConcurrentReconciles}
+	if r.RetryBaseDelay > 0 && r.RetryMaxDelay > 0 {
+		options.RateLimiter = workqueue.NewItemExponentialFailureRateLimiter(r.RetryBaseDelay, r.RetryMaxDelay)
+	}
 	return ctrl.NewControllerManagedBy(mgr).
 		Named(DatabaseControllerName).
 		For(&databasev1.Database{}).
 		Owns(&corev1.Secret{}).
+		// Secrets and ConfigMaps referenced by the ParamsFrom of Database resources, only when their data changes
+		Watches(&source.Kind{Type: &corev1.Secret{}}, handler.EnqueueRequestsFromMapFunc(r.referencingDatabases),
+			builder.WithPredicates(paramsSourceChanged())).
+		Watches(&source.Kind{Type: &corev1.ConfigMap{}}, handler.EnqueueRequestsFromMapFunc(r.referencingDatabases),
+			builder.WithPredicates(paramsSourceChanged())).
+		// DatabaseClasses whose changes may solve the terminal errors of stalled Database resources
+		Watches(&source.Kind{Type: &databaseclassv1.DatabaseClass{}}, handler.EnqueueRequestsFromMapFunc(r.stalledDatabases)).
 		WithEventFilter(r.triggerReconciler()).
+		WithOptions(options).
 		Complete(r)
 }
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
 func (r *DatabaseReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
-	logger = r.Log.WithValues("database", req.NamespacedName)
+	logger := r.Log.WithValues("database", req.NamespacedName)
+	ctx = log.IntoContext(ctx, logger)
+	// Values of parameters read from Secrets are masked in events and logs
+	ctx = withMasker(ctx)
+	ctx, span := tracing.Tracer().Start(ctx, "DatabaseReconciler.Reconcile", trace.WithAttributes(
+		tracing.NamespaceKey.String(req.Namespace),
+		tracing.DatabaseKey.String(req.Name),
+	))
+	defer span.End()
 	logger.V(TraceLevel).Info("Reconcile called")
 
 	obj := &databasev1.Database{}
@@ -109,26 +188,36 @@ func (r *DatabaseReconciler) Reconcile(ctx context.Context, req ctrl.Request) (c
 			return reconcile.Result{}, nil
 		}
 		// Error reading the object - requeue the request.
-		r.handleReconcileError(obj, ReconcileError{
+		return r.handleReconcileError(ctx, obj, ReconcileError{
 			Reason:  RsnDbGetFail,
 			Message: MsgDbGetFail,
 			Err:     err,
-		})
-		return reconcile.Result{Requeue: true}, nil
+		}), nil
 	}
 
+	span.SetAttributes(
+		tracing.EndpointKey.String(obj.Spec.Endpoint),
+		tracing.DatabaseClassKey.String(r.DbmsList.GetDatabaseClassNameByEndpointName(obj.Spec.Endpoint)),
+	)
+
+	// Limit the number of workers busy with the same endpoint
+	if !r.endpointLimiter.tryAcquire(obj.Spec.Endpoint) {
+		logger.V(DebugLevel).Info("Too many resources of the same endpoint being reconciled, requeueing",
+			EndpointName, obj.Spec.Endpoint)
+		return ctrl.Result{RequeueAfter: endpointBusyRequeueAfter}, nil
+	}
+	defer r.endpointLimiter.release(obj.Spec.Endpoint)
+
 	// Set reason to unknown to indicate the resource was correctly received by a controller but no action was resolved yet
 	// Update condition field
 	if meta.FindStatusCondition(obj.Status.Conditions, TypeReady) == nil {
 		logger.V(TraceLevel).Info("Updating ConditionStatus")
-		if err = r.updateReadyCondition(obj, metav1.ConditionUnknown, RsnDbOpQueueSucc, MsgDbOpQueueSucc); err != nil {
-			r.handleReconcileError(obj, ReconcileError{
+		if err = r.updateReadyCondition(ctx, obj, metav1.ConditionUnknown, RsnDbOpQueueSucc, MsgDbOpQueueSucc); err != nil {
+			return r.handleReconcileError(ctx, obj, ReconcileError{
 				Reason:  RsnReadyCondUpdateFail,
 				Message: MsgReadyCondUpdateFail,
 				Err:     err,
-			})
-
-			return ctrl.Result{Requeue: true}, nil
+			}), nil
 		}
 	}
 
@@ -140,10 +229,10 @@ func (r *DatabaseReconciler) Reconcile(ctx context.Context, req ctrl.Request) (c
 			// finalization logic fails, don't remove the finalizer so
 			// that we can retry during the next reconciliation.
 			logger.V(TraceLevel).Info("Finalizing database resource")
-			if err := r.deleteDb(obj); err.IsNotEmpty() {
-				r.handleReconcileError(obj, err)
-				return reconcile.Result{Requeue: true}, nil
+			if err := r.deleteDb(ctx, obj); err.IsNotEmpty() {
+				return r.handleReconcileError(ctx, obj, err), nil
 			}
+			metrics.DeleteDatabaseUsage(obj.Namespace, obj.Name, obj.Spec.Endpoint)
 
 			// Remove databaseFinalizer. Once all finalizers have been
 			// removed, the object will be deleted.
@@ -151,7 +240,7 @@ func (r *DatabaseReconciler) Reconcile(ctx context.Context, req ctrl.Request) (c
 			controllerutil.RemoveFinalizer(obj, databaseFinalizer)
 			if err := r.Update(ctx, obj); err != nil {
 				if !shouldIgnoreUpdateErr(err) {
-					r.handleReconcileError(obj, ReconcileError{
+					r.handleReconcileError(ctx, obj, ReconcileError{
 						Reason:         RsnDbUpdateFail,
 						Message:        MsgDbUpdateFail,
 						Err:            err,
@@ -165,46 +254,63 @@ func (r *DatabaseReconciler) Reconcile(ctx context.Context, req ctrl.Request) (c
 		return reconcile.Result{}, nil
 	}
 
-	// If Database is ready
-	if meta.IsStatusConditionTrue(obj.Status.Conditions, TypeReady) {
+	// Terminal errors are not retried until the spec of the resource or of its DatabaseClass changes
+	if r.isStalled(ctx, obj) {
+		logger.V(DebugLevel).Info("Database resource is stalled by a terminal error, waiting for its spec or its DatabaseClass to change")
+		return ctrl.Result{}, nil
+	}
+
+	// An operation left in the journal was interrupted before its output was handed over, e.g. by a crash of the
+	// Operator. The conditions of obj don't reflect its outcome, resume it before choosing between create and rotate
+	pendingOp, reconcileErr := r.pendingOperation(ctx, obj)
+	if reconcileErr.IsNotEmpty() {
+		return r.handleReconcileError(ctx, obj, reconcileErr), nil
+	}
+	if pendingOp != "" {
+		logger.V(DebugLevel).Info("Resuming operation recorded in the journal", "operation", pendingOp)
+	}
+
+	if pendingOp == database.RotateMapKey {
+		if result, done := r.rotateCredentials(ctx, obj); done {
+			return result, nil
+		}
+	} else if pendingOp != database.CreateMapKey && meta.IsStatusConditionTrue(obj.Status.Conditions, TypeReady) {
+		// If Database is ready
 		logger.V(TraceLevel).Info("Database resource is in Ready state")
 		// Check if Database credentials should be rotated
-		shouldRotate, err := r.shouldRotate(obj)
+		shouldRotate, err := r.shouldRotate(ctx, obj)
 		if err.IsNotEmpty() {
-			r.handleReconcileError(obj, err)
-			return ctrl.Result{Requeue: true}, nil
+			return r.handleReconcileError(ctx, obj, err), nil
 		}
-		if shouldRotate {
-			// Update Ready condition to false, Database credentials must be rotated
-			if err := r.updateReadyCondition(obj, metav1.ConditionFalse, RsnDbRotateInProg, MsgDbRotateInProg); err != nil {
-				r.handleReadyConditionError(obj, err)
-				return ctrl.Result{Requeue: true}, nil
-			}
-			if err := r.rotate(obj); err.IsNotEmpty() {
-				r.handleReconcileError(obj, err)
-				return ctrl.Result{Requeue: true}, nil
+		if !shouldRotate {
+			// Check if the Secret was modified manually and restore it if needed
+			if shouldRotate, err = r.restoreTamperedSecret(ctx, obj); err.IsNotEmpty() {
+				return r.handleReconcileError(ctx, obj, err), nil
 			}
-			// Update Ready condition to true
-			if err := r.updateReadyCondition(obj, metav1.ConditionTrue, RsnDbRotateSucc, MsgDbRotateSucc); err != nil {
-				r.handleReadyConditionError(obj, err)
-				return ctrl.Result{Requeue: true}, nil
+		}
+		if shouldRotate {
+			if result, done := r.rotateCredentials(ctx, obj); done {
+				return result, nil
 			}
-			r.logInfoEvent(obj, RsnDbRotateSucc, MsgDbRotateSucc)
 		} else {
-			// Database is ready and credentials shouldn't be rotated, nothing else to do
-			logger.V(TraceLevel).Info("Credentials should not be rotated, nothing left to do")
-			return ctrl.Result{}, nil
+			// Database is ready and credentials shouldn't be rotated, only perform periodic checks
+			logger.V(TraceLevel).Info("Credentials should not be rotated, resyncing")
+			return r.resync(ctx, obj), nil
 		}
+	} else if pendingOp != database.CreateMapKey && isDriftDetected(obj) && r.ResyncInterval > 0 {
+		// The database instance went missing and the DatabaseClass doesn't allow it to be recreated, keep checking
+		// until it is back
+		logger.V(TraceLevel).Info("Drift detected previously, checking for drift")
+		return r.checkDrift(ctx, obj), nil
 	} else {
 		// Create
-		if err := r.createDb(obj); err.IsNotEmpty() {
-			r.handleReconcileError(obj, err)
-			return ctrl.Result{Requeue: true}, nil
+		if err := r.createDb(ctx, obj); err.IsNotEmpty() {
+			return r.handleReconcileError(ctx, obj, err), nil
 		}
 
 		logger.V(TraceLevel).Info("Updating ConditionStatus")
-		if err := r.updateReadyCondition(obj, metav1.ConditionTrue, RsnDbCreateSucc, MsgDbCreateSucc); err != nil {
-			r.handleReadyConditionError(obj, err)
+		if err := r.updateReadyCondition(ctx, obj, metav1.ConditionTrue, RsnDbCreateSucc, MsgDbCreateSucc); err != nil {
+			r.handleReadyConditionError(ctx, obj, err)
 			return ctrl.Result{Requeue: true}, nil
 		}
 	}
@@ -212,14 +318,13 @@ func (r *DatabaseReconciler) Reconcile(ctx context.Context, req ctrl.Request) (c
 	// If finalizer is not present, add finalizer to resource
 	if !contains(obj.GetFinalizers(), databaseFinalizer) {
 		logger.V(TraceLevel).Info("Adding finalizer")
-		if err := r.addFinalizer(obj); err != nil {
-			r.handleReconcileError(obj, ReconcileError{
+		if err := r.addFinalizer(ctx, obj); err != nil {
+			return r.handleReconcileError(ctx, obj, ReconcileError{
 				Reason:         RsnDbUpdateFail,
 				Message:        MsgDbUpdateFail,
 				Err:            err,
 				AdditionalInfo: StringsToInterfaceSlice("finalizer", databaseFinalizer),
-			})
-			return ctrl.Result{Requeue: true}, nil
+			}), nil
 		}
 	}
 
@@ -227,17 +332,38 @@ func (r *DatabaseReconciler) Reconcile(ctx context.Context, req ctrl.Request) (c
 	return ctrl.Result{}, nil
 }
 
+// rotateCredentials rotates the credentials of obj and updates its Ready condition accordingly. If Reconcile must
+// return, it returns true along with the ctrl.Result to be returned.
+func (r *DatabaseReconciler) rotateCredentials(ctx context.Context, obj *databasev1.Database) (ctrl.Result, bool) {
+	// Update Ready condition to false, Database credentials must be rotated
+	if err := r.updateReadyCondition(ctx, obj, metav1.ConditionFalse, RsnDbRotateInProg, MsgDbRotateInProg); err != nil {
+		r.handleReadyConditionError(ctx, obj, err)
+		return ctrl.Result{Requeue: true}, true
+	}
+	if err := r.rotate(ctx, obj); err.IsNotEmpty() {
+		return r.handleReconcileError(ctx, obj, err), true
+	}
+	// Update Ready condition to true
+	if err := r.updateReadyCondition(ctx, obj, metav1.ConditionTrue, RsnDbRotateSucc, MsgDbRotateSucc); err != nil {
+		r.handleReadyConditionError(ctx, obj, err)
+		return ctrl.Result{Requeue: true}, true
+	}
+	r.logInfoEvent(ctx, obj, RsnDbRotateSucc, MsgDbRotateSucc)
+	return ctrl.Result{}, false
+}
+
 // addFinalizer adds a finalizer to a Database resource.
-func (r *DatabaseReconciler) addFinalizer(obj *databasev1.Database) error {
+func (r *DatabaseReconciler) addFinalizer(ctx context.Context, obj *databasev1.Database) error {
 	controllerutil.AddFinalizer(obj, databaseFinalizer)
-	return r.Update(context.Background(), obj)
+	return r.Update(ctx, obj)
 }
 
 // createDb creates a new Database instance on the external provisioner based on the Database data.
-func (r *DatabaseReconciler) createDb(obj *databasev1.Database) ReconcileError {
-	r.logInfoEvent(obj, RsnDbCreateInProg, MsgDbCreateInProg)
+func (r *DatabaseReconciler) createDb(ctx context.Context, obj *databasev1.Database) ReconcileError {
+	logger := log.FromContext(ctx)
+	r.logInfoEvent(ctx, obj, RsnDbCreateInProg, MsgDbCreateInProg)
 
-	dbClass, err := r.getDbmsClassFromDb(obj)
+	dbClass, err := r.getDbmsClassFromDb(ctx, obj)
 	if err.IsNotEmpty() {
 		return err
 	}
@@ -253,11 +379,16 @@ func (r *DatabaseReconciler) createDb(obj *databasev1.Database) ReconcileError {
 			AdditionalInfo: loggingKv,
 		}
 	}
-	opValues, err := newOpValuesFromResource(obj)
+	opValues, err := r.newOpValuesFromResource(ctx, obj)
+	if err.IsNotEmpty() {
+		return err.With(loggingKv)
+	}
+	seed, err := r.journalSeed(ctx, obj, database.CreateMapKey)
 	if err.IsNotEmpty() {
 		return err.With(loggingKv)
 	}
-	createOp, simpleErr := createOpTemplate.RenderOperation(opValues)
+	generator := newGenerator(seed, dbClass)
+	createOp, simpleErr := createOpTemplate.RenderOperation(ctx, opValues.WithGenerator(generator))
 	if simpleErr != nil {
 		return ReconcileError{
 			Reason:         RsnOpRenderFail,
@@ -271,10 +402,16 @@ func (r *DatabaseReconciler) createDb(obj *databasev1.Database) ReconcileError {
 	// Execute operation on DBMS
 	// Check preconditions
 	var conn database.Driver
-	if conn, err = r.getDbmsConnectionByEndpointName(obj.Spec.Endpoint); err.IsNotEmpty() {
+	if conn, err = r.getDbmsConnectionByEndpointName(ctx, obj.Spec.Endpoint); err.IsNotEmpty() {
 		return err.With(loggingKv)
 	}
-	output := conn.CreateDb(createOp)
+	output, err := r.executeJournaled(ctx, obj, database.CreateMapKey, seed, func() database.OpOutput {
+		return conn.CreateDb(ctx, createOp)
+	})
+	if err.IsNotEmpty() {
+		return err.With(loggingKv)
+	}
+	output.Generated = generator.Generated()
 	if output.Err != nil {
 		return ReconcileError{
 			Reason:         RsnDbCreateFail,
@@ -283,25 +420,74 @@ func (r *DatabaseReconciler) createDb(obj *databasev1.Database) ReconcileError {
 			AdditionalInfo: loggingKv,
 		}
 	}
+	if err = validateOutput(createOpTemplate, output); err.IsNotEmpty() {
+		return r.rejectCreatedInstance(ctx, obj, dbClass, opValues, conn, output, seed, err).With(loggingKv)
+	}
 
 	// Log success
-	r.logInfoEvent(obj, RsnDbCreateSucc, MsgDbCreateSucc)
-	logger.V(TraceLevel).Info(fmt.Sprint(dbClass.Spec.SecretFormat))
-	logger.V(TraceLevel).Info(fmt.Sprint(output))
+	r.logInfoEvent(ctx, obj, RsnDbCreateSucc, MsgDbCreateSucc)
+	// Only the keys are logged, the values of the output are credentials
+	logger.V(TraceLevel).Info("Operation output received", "resultKeys", SortedKeys(output.Result),
+		"generatedKeys", SortedKeys(output.Generated))
+	// Verify credentials before handing them over
+	err = r.verifyCredentials(ctx, obj, dbClass, opValues, output)
+	if err.IsNotEmpty() {
+		return r.rejectCreatedInstance(ctx, obj, dbClass, opValues, conn, output, seed, err).With(loggingKv)
+	}
+	obj.Status.InstanceName = opValues.InstanceName
+	obj.Status.Outputs = persistedOutputs(obj, dbClass, output)
+	obj.Status.ParamsHash = paramsFromHash(obj, opValues.Parameters)
 	// Create Secret
-	err = r.createSecret(obj, dbClass.Spec.SecretFormat, output)
+	err = r.createSecret(ctx, obj, dbClass, opValues, output)
 	if err.IsNotEmpty() {
 		return err.With(loggingKv)
 	}
+	if err = r.completeJournal(ctx, obj); err.IsNotEmpty() {
+		return err.With(loggingKv)
+	}
 
 	return ReconcileError{}
 }
 
+// rejectCreatedInstance handles err, returned when output of the create operation of dbClass is rejected, e.g. by
+// validateOutput or verifyCredentials. Since the database instance was created nonetheless, it is deleted instead of
+// being left behind without a Secret and err is returned once it is, the journal entry of obj being marked as failed so
+// that the next attempt creates it again, see failJournal. If it can't be deleted, the journal entry is kept so that the
+// next attempt resumes the same output and retries the deletion instead of creating another instance.
+func (r *DatabaseReconciler) rejectCreatedInstance(ctx context.Context, obj *databasev1.Database, dbClass databaseclassv1.DatabaseClass, opValues database.OpValues, conn database.Driver, output database.OpOutput, seed []byte, err ReconcileError) ReconcileError {
+	if deleteErr := r.deleteRejectedInstance(ctx, obj, dbClass, opValues, conn, output); deleteErr != nil {
+		return ReconcileError{
+			Reason:  RsnDbDeleteFail,
+			Message: MsgDbDeleteFail,
+			Err:     fmt.Errorf("%v, unable to delete the database instance created: %w", err.Err, deleteErr),
+		}
+	}
+	log.FromContext(ctx).Info("Database instance created with a rejected output was deleted", "reason", err.Reason)
+	r.failJournal(ctx, obj, database.CreateMapKey, seed)
+	return err
+}
+
+// deleteRejectedInstance deletes the database instance created by the create operation of dbClass whose output was
+// rejected, see rejectCreatedInstance. The delete operation is rendered with opValues and the non-sensitive values of output,
+// see persistedOutputs, as it would be once the instance is bound to obj.
+func (r *DatabaseReconciler) deleteRejectedInstance(ctx context.Context, obj *databasev1.Database, dbClass databaseclassv1.DatabaseClass, opValues database.OpValues, conn database.Driver, output database.OpOutput) error {
+	deleteOpTemplate, exists := dbClass.Spec.Operations[database.DeleteMapKey]
+	if !exists {
+		return errors.New(MsgOpNotSupported)
+	}
+	opValues.Outputs = persistedOutputs(obj, dbClass, output)
+	deleteOp, err := deleteOpTemplate.RenderOperation(ctx, opValues)
+	if err != nil {
+		return err
+	}
+	return conn.DeleteDb(ctx, deleteOp).Err
+}
+
 // deleteDb deletes the database instance on the external provisioner.
-func (r *DatabaseReconciler) deleteDb(obj *databasev1.Database) ReconcileError {
-	r.logInfoEvent(obj, RsnDbDeleteInProg, MsgDbDeleteInProg)
+func (r *DatabaseReconciler) deleteDb(ctx context.Context, obj *databasev1.Database) ReconcileError {
+	r.logInfoEvent(ctx, obj, RsnDbDeleteInProg, MsgDbDeleteInProg)
 
-	dbClass, reconcileErr := r.getDbmsClassFromDb(obj)
+	dbClass, reconcileErr := r.getDbmsClassFromDb(ctx, obj)
 	if reconcileErr.IsNotEmpty() {
 		return reconcileErr
 	}
@@ -317,11 +503,11 @@ func (r *DatabaseReconciler) deleteDb(obj *databasev1.Database) ReconcileError {
 			AdditionalInfo: loggingKv,
 		}
 	}
-	opValues, reconcileErr := newOpValuesFromResource(obj)
+	opValues, reconcileErr := r.newOpValuesFromResource(ctx, obj)
 	if reconcileErr.IsNotEmpty() {
 		return reconcileErr.With(loggingKv)
 	}
-	deleteOp, err := deleteOpTemplate.RenderOperation(opValues)
+	deleteOp, err := deleteOpTemplate.RenderOperation(ctx, opValues)
 	if err != nil {
 		return ReconcileError{
 			Reason:         RsnOpRenderFail,
@@ -335,7 +521,7 @@ func (r *DatabaseReconciler) deleteDb(obj *databasev1.Database) ReconcileError {
 	// Execute operation on DBMS
 	// Check preconditions
 	var conn database.Driver
-	if conn, reconcileErr = r.getDbmsConnectionByEndpointName(obj.Spec.Endpoint); reconcileErr.IsNotEmpty() {
+	if conn, reconcileErr = r.getDbmsConnectionByEndpointName(ctx, obj.Spec.Endpoint); reconcileErr.IsNotEmpty() {
 		return reconcileErr.With(loggingKv)
 	}
 	conn = r.Pool.Get(obj.Spec.Endpoint)
@@ -347,7 +533,7 @@ func (r *DatabaseReconciler) deleteDb(obj *databasev1.Database) ReconcileError {
 			AdditionalInfo: loggingKv,
 		}
 	}
-	output := conn.DeleteDb(deleteOp)
+	output := conn.DeleteDb(ctx, deleteOp)
 	if output.Err != nil {
 		return ReconcileError{
 			Reason:         RsnDbDeleteFail,
@@ -356,14 +542,19 @@ func (r *DatabaseReconciler) deleteDb(obj *databasev1.Database) ReconcileError {
 			AdditionalInfo: loggingKv,
 		}
 	}
+	// Secrets would be garbage collected together with obj, credentials written to other sinks would not
+	if err := r.deleteSecret(ctx, obj, dbClass); err.IsNotEmpty() {
+		return err.With(loggingKv)
+	}
 	return ReconcileError{}
 }
 
 // rotate rotates the database credentials on the external provisioner.
-func (r *DatabaseReconciler) rotate(obj *databasev1.Database) ReconcileError {
-	r.logInfoEvent(obj, RsnDbRotateInProg, MsgDbRotateInProg)
+func (r *DatabaseReconciler) rotate(ctx context.Context, obj *databasev1.Database) ReconcileError {
+	logger := log.FromContext(ctx)
+	r.logInfoEvent(ctx, obj, RsnDbRotateInProg, MsgDbRotateInProg)
 
-	dbClass, reconcileErr := r.getDbmsClassFromDb(obj)
+	dbClass, reconcileErr := r.getDbmsClassFromDb(ctx, obj)
 	if reconcileErr.IsNotEmpty() {
 		return reconcileErr
 	}
@@ -377,11 +568,16 @@ func (r *DatabaseReconciler) rotate(obj *databasev1.Database) ReconcileError {
 			AdditionalInfo: loggingKv,
 		}
 	}
-	opValues, reconcileErr := newOpValuesFromResource(obj)
+	opValues, reconcileErr := r.newOpValuesFromResource(ctx, obj)
 	if reconcileErr.IsNotEmpty() {
 		return reconcileErr.With(loggingKv)
 	}
-	rotateOp, err := rotateOpTemplate.RenderOperation(opValues)
+	seed, reconcileErr := r.journalSeed(ctx, obj, database.RotateMapKey)
+	if reconcileErr.IsNotEmpty() {
+		return reconcileErr.With(loggingKv)
+	}
+	generator := newGenerator(seed, dbClass)
+	rotateOp, err := rotateOpTemplate.RenderOperation(ctx, opValues.WithGenerator(generator))
 	if err != nil {
 		return ReconcileError{
 			Reason:         RsnOpRenderFail,
@@ -395,7 +591,7 @@ func (r *DatabaseReconciler) rotate(obj *databasev1.Database) ReconcileError {
 	// Execute operation on DBMS
 	// Check preconditions
 	var conn database.Driver
-	if conn, reconcileErr = r.getDbmsConnectionByEndpointName(obj.Spec.Endpoint); reconcileErr.IsNotEmpty() {
+	if conn, reconcileErr = r.getDbmsConnectionByEndpointName(ctx, obj.Spec.Endpoint); reconcileErr.IsNotEmpty() {
 		return reconcileErr.With(loggingKv)
 	}
 	conn = r.Pool.Get(obj.Spec.Endpoint)
@@ -407,7 +603,13 @@ func (r *DatabaseReconciler) rotate(obj *databasev1.Database) ReconcileError {
 			AdditionalInfo: loggingKv,
 		}
 	}
-	output := conn.Rotate(rotateOp)
+	output, reconcileErr := r.executeJournaled(ctx, obj, database.RotateMapKey, seed, func() database.OpOutput {
+		return conn.Rotate(ctx, rotateOp)
+	})
+	if reconcileErr.IsNotEmpty() {
+		return reconcileErr.With(loggingKv)
+	}
+	output.Generated = generator.Generated()
 	if output.Err != nil {
 		return ReconcileError{
 			Reason:         RsnDbRotateFail,
@@ -416,29 +618,36 @@ func (r *DatabaseReconciler) rotate(obj *databasev1.Database) ReconcileError {
 			AdditionalInfo: loggingKv,
 		}
 	}
+	if reconcileErr = validateOutput(rotateOpTemplate, output); reconcileErr.IsNotEmpty() {
+		r.failJournal(ctx, obj, database.RotateMapKey, seed)
+		return reconcileErr.With(loggingKv)
+	}
 
-	if isSecretPresent, err := r.isSecretPresent(obj); isSecretPresent {
-		if err.IsNotEmpty() {
-			return err.With(loggingKv)
-		}
-		// Secret is already present, update it
-		err = r.updateSecret(obj, dbClass.Spec.SecretFormat, output)
-		if err.IsNotEmpty() {
-			return err.With(loggingKv)
-		}
-	} else {
-		// Secret is not present, create it
-		err = r.createSecret(obj, dbClass.Spec.SecretFormat, output)
-		if err.IsNotEmpty() {
-			return err.With(loggingKv)
-		}
+	// Verify credentials before handing them over, the old Secret is kept untouched if the verification fails
+	if err := r.verifyCredentials(ctx, obj, dbClass, opValues, output); err.IsNotEmpty() {
+		r.failJournal(ctx, obj, database.RotateMapKey, seed)
+		return err.With(loggingKv)
+	}
+	obj.Status.Outputs = persistedOutputs(obj, dbClass, output)
+	obj.Status.ParamsHash = paramsFromHash(obj, opValues.Parameters)
+
+	// Update the Secret, or create it if it is not present
+	if err := r.createSecret(ctx, obj, dbClass, opValues, output); err.IsNotEmpty() {
+		return err.With(loggingKv)
+	}
+	if err := r.completeJournal(ctx, obj); err.IsNotEmpty() {
+		return err.With(loggingKv)
 	}
 
 	// Remove annotation if present
 	if isRotateAnnotationTrue(obj) {
 		logger.V(TraceLevel).Info("Removing rotate annotation")
 		delete(obj.GetAnnotations(), rotateAnnotationKey)
-		err := r.Client.Update(context.Background(), obj)
+		// Update overwrites the status of obj with the one stored in the API server, keep the one set by this rotation
+		// so that it can be persisted afterwards
+		status := obj.Status.DeepCopy()
+		err := r.Client.Update(ctx, obj)
+		status.DeepCopyInto(&obj.Status)
 		if err != nil {
 			return ReconcileError{
 				Reason:         RsnDbUpdateFail,
@@ -452,7 +661,380 @@ func (r *DatabaseReconciler) rotate(obj *databasev1.Database) ReconcileError {
 	return ReconcileError{}
 }
 
-func (r *DatabaseReconciler) getDbmsClassFromDb(obj *databasev1.Database) (databaseclassv1.DatabaseClass, ReconcileError) {
+// exists calls the exists operation of dbClass for obj and returns whether the database instance of obj exists on its
+// endpoint.
+func (r *DatabaseReconciler) exists(ctx context.Context, obj *databasev1.Database, dbClass databaseclassv1.DatabaseClass) (bool, ReconcileError) {
+	loggingKv := StringsToInterfaceSlice(DatabaseClass, dbClass.Name, database.OperationsConfigKey, database.ExistsMapKey)
+	existsOpTemplate, exists := dbClass.Spec.Operations[database.ExistsMapKey]
+	if !exists {
+		return false, ReconcileError{
+			Reason:         RsnOpNotSupported,
+			Message:        MsgOpNotSupported,
+			Err:            nil,
+			AdditionalInfo: loggingKv,
+		}
+	}
+	opValues, reconcileErr := r.newOpValuesFromResource(ctx, obj)
+	if reconcileErr.IsNotEmpty() {
+		return false, reconcileErr.With(loggingKv)
+	}
+	existsOp, err := existsOpTemplate.RenderOperation(ctx, opValues)
+	if err != nil {
+		return false, ReconcileError{
+			Reason:         RsnOpRenderFail,
+			Message:        MsgOpRenderFail,
+			Err:            err,
+			AdditionalInfo: loggingKv,
+		}
+	}
+	loggingKv = append(loggingKv, EndpointName, obj.Spec.Endpoint)
+
+	// Execute operation on DBMS
+	// Check preconditions
+	var conn database.Driver
+	if conn, reconcileErr = r.getDbmsConnectionByEndpointName(ctx, obj.Spec.Endpoint); reconcileErr.IsNotEmpty() {
+		return false, reconcileErr.With(loggingKv)
+	}
+	output := conn.Exists(ctx, existsOp)
+	if output.Err != nil {
+		return false, ReconcileError{
+			Reason:         RsnDbExistsFail,
+			Message:        MsgDbExistsFail,
+			Err:            output.Err,
+			AdditionalInfo: loggingKv,
+		}
+	}
+	isExisting, err := output.IsExisting()
+	if err != nil {
+		return false, ReconcileError{
+			Reason:         RsnDbExistsFail,
+			Message:        MsgDbExistsFail,
+			Err:            err,
+			AdditionalInfo: loggingKv,
+		}
+	}
+	return isExisting, ReconcileError{}
+}
+
+// resync performs the periodic checks of a ready Database resource, i.e. usage reporting and drift detection. Checks
+// are performed only if r.ResyncInterval is set. Errors of the checks, e.g. usage reporting errors, are recorded as
+// events but don't affect the Ready condition of obj. It returns the ctrl.Result to be returned by Reconcile, which
+// schedules the next check.
+func (r *DatabaseReconciler) resync(ctx context.Context, obj *databasev1.Database) ctrl.Result {
+	logger := log.FromContext(ctx)
+	if r.ResyncInterval <= 0 {
+		return ctrl.Result{}
+	}
+	dbClass, err := r.getDbmsClassFromDb(ctx, obj)
+	if err.IsNotEmpty() {
+		r.logWarningEvent(ctx, obj, err)
+		return ctrl.Result{RequeueAfter: r.ResyncInterval}
+	}
+	_, isUsageSupported := dbClass.Spec.Operations[database.UsageMapKey]
+	if isUsageSupported {
+		if err := r.updateUsage(ctx, obj, dbClass); err.IsNotEmpty() {
+			r.logWarningEvent(ctx, obj, err)
+		}
+	}
+	result := r.checkDrift(ctx, obj)
+	if result.IsZero() && isUsageSupported {
+		result.RequeueAfter = r.ResyncInterval
+	}
+	return result
+}
+
+// updateUsage calls the usage operation of dbClass for obj and merges the returned metrics into the status of obj.
+// Metrics are exported as gauges as well. The operation is skipped if the last one was performed less than
+// r.ResyncInterval ago.
+func (r *DatabaseReconciler) updateUsage(ctx context.Context, obj *databasev1.Database, dbClass databaseclassv1.DatabaseClass) ReconcileError {
+	if obj.Status.Usage != nil && time.Since(obj.Status.Usage.LastUpdateTime.Time) < r.ResyncInterval {
+		return ReconcileError{}
+	}
+	loggingKv := StringsToInterfaceSlice(DatabaseClass, dbClass.Name, database.OperationsConfigKey, database.UsageMapKey)
+	usageOpTemplate := dbClass.Spec.Operations[database.UsageMapKey]
+	opValues, reconcileErr := r.newOpValuesFromResource(ctx, obj)
+	if reconcileErr.IsNotEmpty() {
+		return reconcileErr.With(loggingKv)
+	}
+	usageOp, err := usageOpTemplate.RenderOperation(ctx, opValues)
+	if err != nil {
+		return ReconcileError{
+			Reason:         RsnOpRenderFail,
+			Message:        MsgOpRenderFail,
+			Err:            err,
+			AdditionalInfo: loggingKv,
+		}
+	}
+	loggingKv = append(loggingKv, EndpointName, obj.Spec.Endpoint)
+
+	// Execute operation on DBMS
+	// Check preconditions
+	var conn database.Driver
+	if conn, reconcileErr = r.getDbmsConnectionByEndpointName(ctx, obj.Spec.Endpoint); reconcileErr.IsNotEmpty() {
+		return reconcileErr.With(loggingKv)
+	}
+	output := conn.Usage(ctx, usageOp)
+	if output.Err != nil {
+		return ReconcileError{
+			Reason:         RsnDbUsageFail,
+			Message:        MsgDbUsageFail,
+			Err:            output.Err,
+			AdditionalInfo: loggingKv,
+		}
+	}
+	values, err := output.UsageMetrics()
+	if err != nil {
+		return ReconcileError{
+			Reason:         RsnDbUsageFail,
+			Message:        MsgDbUsageFail,
+			Err:            err,
+			AdditionalInfo: loggingKv,
+		}
+	}
+
+	// Merge the returned metrics with the ones already present
+	usage := &databasev1.DatabaseUsage{}
+	if obj.Status.Usage != nil {
+		usage = obj.Status.Usage.DeepCopy()
+	}
+	for key, value := range values {
+		value := value
+		switch key {
+		case database.UsageSizeBytesKey:
+			usage.SizeBytes = &value
+		case database.UsageTableCountKey:
+			usage.TableCount = &value
+		case database.UsageActiveConnectionsKey:
+			usage.ActiveConnections = &value
+		}
+	}
+	usage.LastUpdateTime = metav1.Now()
+	obj.Status.Usage = usage
+	if err := r.Client.Status().Update(ctx, obj); err != nil {
+		return ReconcileError{
+			Reason:         RsnDbUpdateFail,
+			Message:        MsgDbUpdateFail,
+			Err:            err,
+			AdditionalInfo: loggingKv,
+		}
+	}
+	metrics.SetDatabaseUsage(obj.Namespace, obj.Name, obj.Spec.Endpoint, usage.SizeBytes, usage.TableCount,
+		usage.ActiveConnections)
+	return ReconcileError{}
+}
+
+// checkDrift checks whether the database instance of obj still exists on its endpoint, in order to detect database
+// instances removed without the Operator knowing it. The check is performed only if r.ResyncInterval is set and the
+// DatabaseClass of obj supports the exists operation. If the database instance is missing, the Ready condition is set
+// to false and, if the DriftPolicy of the DatabaseClass allows it, the database instance is recreated. Errors of the
+// check itself, e.g. an unreachable endpoint, are recorded as events but don't affect the Ready condition of obj, so
+// that they are never mistaken for a missing database instance.
+// It returns the ctrl.Result to be returned by Reconcile, which schedules the next check.
+func (r *DatabaseReconciler) checkDrift(ctx context.Context, obj *databasev1.Database) ctrl.Result {
+	logger := log.FromContext(ctx)
+	if r.ResyncInterval <= 0 {
+		return ctrl.Result{}
+	}
+	nextCheck := ctrl.Result{RequeueAfter: r.ResyncInterval}
+	dbClass, err := r.getDbmsClassFromDb(ctx, obj)
+	if err.IsNotEmpty() {
+		r.logWarningEvent(ctx, obj, err)
+		return nextCheck
+	}
+	if _, supported := dbClass.Spec.Operations[database.ExistsMapKey]; !supported {
+		logger.V(TraceLevel).Info("Exists operation not supported, nothing left to do")
+		return ctrl.Result{}
+	}
+	isExisting, err := r.exists(ctx, obj, dbClass)
+	if err.IsNotEmpty() {
+		r.logWarningEvent(ctx, obj, err)
+		return nextCheck
+	}
+	if isExisting {
+		if isDriftDetected(obj) {
+			// The database instance is back, e.g. because it was restored manually
+			if err := r.updateReadyCondition(ctx, obj, metav1.ConditionTrue, RsnDbDriftResolved, MsgDbDriftResolved); err != nil {
+				r.handleReadyConditionError(ctx, obj, err)
+				return ctrl.Result{Requeue: true}
+			}
+			r.logInfoEvent(ctx, obj, RsnDbDriftResolved, MsgDbDriftResolved)
+		}
+		return nextCheck
+	}
+
+	driftErr := ReconcileError{
+		Reason:         RsnDbDriftDetected,
+		Message:        MsgDbDriftDetected,
+		AdditionalInfo: StringsToInterfaceSlice(DatabaseClass, dbClass.Name, EndpointName, obj.Spec.Endpoint),
+	}
+	r.handleReconcileError(ctx, obj, driftErr)
+	if dbClass.Spec.DriftPolicy != databaseclassv1.DriftPolicyRecreate {
+		return nextCheck
+	}
+	if err := r.createDb(ctx, obj); err.IsNotEmpty() {
+		return r.handleReconcileError(ctx, obj, err)
+	}
+	if err := r.updateReadyCondition(ctx, obj, metav1.ConditionTrue, RsnDbCreateSucc, MsgDbCreateSucc); err != nil {
+		r.handleReadyConditionError(ctx, obj, err)
+		return ctrl.Result{Requeue: true}
+	}
+	return nextCheck
+}
+
+// verifyCredentials connects to the endpoint of obj using the credentials contained in output, if credential
+// verification is enabled for dbClass. See database.VerifyCredentials.
+func (r *DatabaseReconciler) verifyCredentials(ctx context.Context, obj *databasev1.Database, dbClass databaseclassv1.DatabaseClass, opValues database.OpValues, output database.OpOutput) ReconcileError {
+	logger := log.FromContext(ctx)
+	verification := dbClass.Spec.CredentialVerification
+	if verification == nil {
+		return ReconcileError{}
+	}
+	logger.V(DebugLevel).Info("Verifying credentials returned by the dbms endpoint")
+
+	dsn, err := database.RenderGoTemplate(verification.Dsn, opValues.WithOutput(output), database.ErrorOnMissingKeyOption)
+	if err != nil {
+		return ReconcileError{
+			Reason:  RsnCredVerifyRenderFail,
+			Message: MsgCredVerifyRenderFail,
+			Err:     err,
+		}
+	}
+	if err := database.VerifyCredentials(ctx, dbClass.Spec.Driver, database.Dsn(dsn)); err != nil {
+		return ReconcileError{
+			Reason:  RsnCredVerifyFail,
+			Message: MsgCredVerifyFail,
+			Err:     err,
+		}
+	}
+	r.logInfoEvent(ctx, obj, RsnCredVerifySucc, MsgCredVerifySucc)
+	return ReconcileError{}
+}
+
+// journalSeed returns the seed of the values generated for operation of obj. The seed is recorded in the journal of obj
+// by executeJournaled, so that retries of operation generate the same values, see journal.Entry.SeedFor. If journaling is
+// disabled, a new random seed is returned on each call, i.e. retries generate new values.
+func (r *DatabaseReconciler) journalSeed(ctx context.Context, obj *databasev1.Database, operation string) ([]byte, ReconcileError) {
+	entry, err := r.Journal.Get(ctx, obj)
+	if err == nil {
+		var seed []byte
+		if seed, err = entry.SeedFor(operation); err == nil {
+			return seed, ReconcileError{}
+		}
+	}
+	return nil, ReconcileError{
+		Reason:         RsnJournalFail,
+		Message:        MsgJournalFail,
+		Err:            err,
+		AdditionalInfo: StringsToInterfaceSlice("journal", journal.SecretName(obj.Name)),
+	}
+}
+
+// executeJournaled calls execute, which must execute operation for obj, and records its intent and outcome in the
+// journal of obj along with seed, see journalSeed. If the journal shows that operation has already been executed, e.g.
+// because the Operator crashed or the Secret write failed before its output was handed over, execute is not called and
+// the recorded output is returned instead. Errors of execute are returned in the output, as if the operation was
+// executed directly.
+func (r *DatabaseReconciler) executeJournaled(ctx context.Context, obj *databasev1.Database, operation string, seed []byte, execute func() database.OpOutput) (database.OpOutput, ReconcileError) {
+	logger := log.FromContext(ctx)
+	loggingKv := StringsToInterfaceSlice("journal", journal.SecretName(obj.Name))
+	entry, err := r.Journal.Get(ctx, obj)
+	if err != nil {
+		return database.OpOutput{}, ReconcileError{
+			Reason:         RsnJournalFail,
+			Message:        MsgJournalFail,
+			Err:            err,
+			AdditionalInfo: loggingKv,
+		}
+	}
+	if entry.IsExecuted(operation) {
+		r.logInfoEvent(ctx, obj, RsnJournalResume, MsgJournalResume, loggingKv...)
+		return database.OpOutput{Result: entry.Output}, ReconcileError{}
+	}
+
+	ownerRef := journalOwnerRef(obj)
+	if err := r.Journal.Record(ctx, obj, ownerRef, journal.Entry{Operation: operation, Phase: journal.PhaseIntent, Seed: seed}); err != nil {
+		return database.OpOutput{}, ReconcileError{
+			Reason:         RsnJournalFail,
+			Message:        MsgJournalFail,
+			Err:            err,
+			AdditionalInfo: loggingKv,
+		}
+	}
+	output := execute()
+	if output.Err != nil {
+		return output, ReconcileError{}
+	}
+	err = r.Journal.Record(ctx, obj, ownerRef, journal.Entry{
+		Operation: operation,
+		Phase:     journal.PhaseExecuted,
+		Output:    output.Result,
+		Seed:      seed,
+	})
+	if err != nil {
+		// The output is still available, try to hand it over anyway
+		logger.Error(err, MsgJournalFail, loggingKv...)
+	}
+	return output, ReconcileError{}
+}
+
+// failJournal marks the journal entry of operation of obj as failed once its output has been rejected, so that the next
+// attempt executes operation again with the same seed instead of resuming it with the rejected output. Errors are only
+// logged, the rejection of the output is the error reported to the caller.
+func (r *DatabaseReconciler) failJournal(ctx context.Context, obj *databasev1.Database, operation string, seed []byte) {
+	logger := log.FromContext(ctx)
+	err := r.Journal.Record(ctx, obj, journalOwnerRef(obj), journal.Entry{
+		Operation: operation,
+		Phase:     journal.PhaseFailed,
+		Seed:      seed,
+	})
+	if err != nil {
+		logger.Error(err, MsgJournalFail, "journal", journal.SecretName(obj.Name))
+	}
+}
+
+// pendingOperation returns the operation recorded in the journal of obj, "" if there is none. Entries are removed once
+// the output of their operation has been handed over, see completeJournal, any entry left belongs to an operation which
+// must be resumed or executed again.
+func (r *DatabaseReconciler) pendingOperation(ctx context.Context, obj *databasev1.Database) (string, ReconcileError) {
+	entry, err := r.Journal.Get(ctx, obj)
+	if err != nil {
+		return "", ReconcileError{
+			Reason:         RsnJournalFail,
+			Message:        MsgJournalFail,
+			Err:            err,
+			AdditionalInfo: StringsToInterfaceSlice("journal", journal.SecretName(obj.Name)),
+		}
+	}
+	if entry == nil {
+		return "", ReconcileError{}
+	}
+	return entry.Operation, ReconcileError{}
+}
+
+// journalOwnerRef returns the owner reference of the journal Secret of obj.
+func journalOwnerRef(obj *databasev1.Database) metav1.OwnerReference {
+	return metav1.OwnerReference{
+		APIVersion: obj.APIVersion,
+		Kind:       obj.Kind,
+		Name:       obj.Name,
+		UID:        obj.UID,
+	}
+}
+
+// completeJournal removes the journal entry of obj once the output of its operation has been handed over.
+func (r *DatabaseReconciler) completeJournal(ctx context.Context, obj *databasev1.Database) ReconcileError {
+	if err := r.Journal.Complete(ctx, obj); err != nil {
+		return ReconcileError{
+			Reason:         RsnJournalFail,
+			Message:        MsgJournalFail,
+			Err:            err,
+			AdditionalInfo: StringsToInterfaceSlice("journal", journal.SecretName(obj.Name)),
+		}
+	}
+	return ReconcileError{}
+}
+
+func (r *DatabaseReconciler) getDbmsClassFromDb(ctx context.Context, obj *databasev1.Database) (databaseclassv1.DatabaseClass, ReconcileError) {
 	// Get DatabaseClass resource from api server
 	dbClassName := r.DbmsList.GetDatabaseClassNameByEndpointName(obj.Spec.Endpoint)
 	if dbClassName == "" {
@@ -465,7 +1047,7 @@ func (r *DatabaseReconciler) getDbmsClassFromDb(obj *databasev1.Database) (datab
 	}
 
 	dbClass := databaseclassv1.DatabaseClass{}
-	err := r.Client.Get(context.Background(), client.ObjectKey{Namespace: "", Name: dbClassName}, &dbClass)
+	err := r.Client.Get(ctx, client.ObjectKey{Namespace: "", Name: dbClassName}, &dbClass)
 	if err != nil {
 		return databaseclassv1.DatabaseClass{}, ReconcileError{
 			Reason:         RsnDbcGetFail,
@@ -477,7 +1059,7 @@ func (r *DatabaseReconciler) getDbmsClassFromDb(obj *databasev1.Database) (datab
 	return dbClass, ReconcileError{}
 }
 
-func (r *DatabaseReconciler) getDbmsConnectionByEndpointName(endpointName string) (database.Driver, ReconcileError) {
+func (r *DatabaseReconciler) getDbmsConnectionByEndpointName(ctx context.Context, endpointName string) (database.Driver, ReconcileError) {
 	// Check if the endpoint is currently stored in the connection pool
 	conn := r.Pool.Get(endpointName)
 	if conn == nil {
@@ -488,7 +1070,7 @@ func (r *DatabaseReconciler) getDbmsConnectionByEndpointName(endpointName string
 		}
 	}
 	// Make a first check to acknowledge whether the connection looks alive
-	if simpleErr := conn.Ping(); simpleErr != nil {
+	if simpleErr := conn.Ping(ctx); simpleErr != nil {
 		return nil, ReconcileError{
 			Reason:  RsnDbmsConnFail,
 			Message: MsgDbmsConnFail,
@@ -500,12 +1082,37 @@ func (r *DatabaseReconciler) getDbmsConnectionByEndpointName(endpointName string
 
 // handleReconcileError sets the obj Conditions type Ready to false and sets the relative fields error and message,
 // it records a Warning event with reason and message for the given obj and logs err (if present) and message to the
-// global logger.
+// logger of ctx.
+// If err is terminal, the Stalled condition of obj is set to true for the current generation of obj and of its
+// DatabaseClass, see isStalled. It returns the
+// ctrl.Result to be returned by Reconcile: terminal errors are not retried, the others are requeued with an exponential
+// backoff, see SetupWithManager.
 // It ignores optimistic locking error, see shouldIgnoreUpdateErr.
-func (r *DatabaseReconciler) handleReconcileError(obj *databasev1.Database, err ReconcileError) {
+func (r *DatabaseReconciler) handleReconcileError(ctx context.Context, obj *databasev1.Database, err ReconcileError) ctrl.Result {
+	logger := log.FromContext(ctx)
+	err.Err = maskerFrom(ctx).maskErr(err.Err)
+	err.AdditionalInfo = maskerFrom(ctx).maskAll(err.AdditionalInfo)
 	if shouldIgnoreUpdateErr(err.Err) {
 		logger.V(TraceLevel).Info(err.Err.Error())
-		return
+		return ctrl.Result{Requeue: true}
+	}
+	span := trace.SpanFromContext(ctx)
+	span.SetStatus(codes.Error, err.Reason)
+	if err.Err != nil {
+		span.RecordError(err.Err)
+	}
+	isTerminal := err.IsTerminal()
+	if isTerminal {
+		meta.SetStatusCondition(&obj.Status.Conditions, metav1.Condition{
+			Type:               TypeStalled,
+			Status:             metav1.ConditionTrue,
+			ObservedGeneration: obj.Generation,
+			Reason:             err.Reason,
+			Message:            err.Message,
+		})
+		obj.Status.StalledClassGeneration = r.databaseClassGeneration(ctx, obj)
+	} else {
+		meta.RemoveStatusCondition(&obj.Status.Conditions, TypeStalled)
 	}
 	keyAndValuesLen := len(err.AdditionalInfo)
 	if keyAndValuesLen%2 != 0 {
@@ -514,160 +1121,305 @@ func (r *DatabaseReconciler) handleReconcileError(obj *databasev1.Database, err
 		keyAndValuesLen = 0
 	}
 	if keyAndValuesLen > 0 {
-		r.EventRecorder.Event(obj, Warning, err.Reason, formatEventMessage(err.Message, err.AdditionalInfo...))
+		r.EventRecorder.Event(obj, Warning, err.Reason, formatEventMessage(logger, err.Message, err.AdditionalInfo...))
 		logger.Error(err.Err, err.Message, err.AdditionalInfo...)
 	} else {
 		r.EventRecorder.Event(obj, Warning, err.Reason, err.Message)
 		logger.Error(err.Err, err.Message)
 	}
-	if updateErr := r.updateReadyCondition(obj, metav1.ConditionFalse, err.Reason, err.Message); updateErr != nil {
+	if updateErr := r.updateReadyCondition(ctx, obj, metav1.ConditionFalse, err.Reason, err.Message); updateErr != nil {
 		logger.Error(err.Err, MsgDbUpdateFail)
 	}
+	if isTerminal {
+		logger.Info("Terminal error, the resource won't be reconciled again until its spec or its DatabaseClass changes",
+			"reason", err.Reason)
+		return ctrl.Result{}
+	}
+	return ctrl.Result{Requeue: true}
 }
 
 // handleReadyConditionError records an event of type Warning to obj using RsnReadyCondUpdateFail, MsgReadyCondUpdateFail
 // and additionalInfo. additionalInfo is formatted as JSON and attached to the event message.
-// An error log using message and additionalInfo is written using the global logger.
+// An error log using message and additionalInfo is written using the logger of ctx. err and additionalInfo are masked,
+// see withMasker.
 // It ignores optimistic locking error, see shouldIgnoreUpdateErr.
-func (r *DatabaseReconciler) handleReadyConditionError(obj *databasev1.Database, err error, additionalInfo ...interface{}) {
+func (r *DatabaseReconciler) handleReadyConditionError(ctx context.Context, obj *databasev1.Database, err error, additionalInfo ...interface{}) {
+	logger := log.FromContext(ctx)
+	err = maskerFrom(ctx).maskErr(err)
+	additionalInfo = maskerFrom(ctx).maskAll(additionalInfo)
 	if shouldIgnoreUpdateErr(err) {
 		logger.V(TraceLevel).Info(err.Error())
 		return
 	}
 	// In the grim situation where the Ready condition cannot be updated, dump everything to the resource event stream
 	// and logger
-	eventMessage := formatEventMessage(MsgReadyCondUpdateFail, additionalInfo...)
+	eventMessage := formatEventMessage(logger, MsgReadyCondUpdateFail, additionalInfo...)
 	r.EventRecorder.Event(obj, Warning, RsnReadyCondUpdateFail, eventMessage)
 	logger.Error(err, MsgReadyCondUpdateFail, additionalInfo...)
 }
 
+// logWarningEvent records an event of type Warning to obj using the reason, message and additional info of err and
+// writes an error log using the logger of ctx, without affecting the conditions of obj. err is masked, see withMasker.
+func (r *DatabaseReconciler) logWarningEvent(ctx context.Context, obj *databasev1.Database, err ReconcileError) {
+	logger := log.FromContext(ctx)
+	err.Err = maskerFrom(ctx).maskErr(err.Err)
+	err.AdditionalInfo = maskerFrom(ctx).maskAll(err.AdditionalInfo)
+	r.EventRecorder.Event(obj, Warning, err.Reason, formatEventMessage(logger, err.Message, err.AdditionalInfo...))
+	logger.Error(err.Err, err.Message, err.AdditionalInfo...)
+}
+
 // logInfoEvent records an event of type Normal to obj using reason, message and additionalInfo. additionalInfo is formatted
-// as JSON and attached to the event message. An info log using message and additionalInfo is written using the global logger
-func (r *DatabaseReconciler) logInfoEvent(obj *databasev1.Database, reason, message string, additionalInfo ...interface{}) {
-	eventMessage := formatEventMessage(message, additionalInfo...)
+// as JSON and attached to the event message. An info log using message and additionalInfo is written using the logger of ctx
+func (r *DatabaseReconciler) logInfoEvent(ctx context.Context, obj *databasev1.Database, reason, message string, additionalInfo ...interface{}) {
+	logger := log.FromContext(ctx)
+	additionalInfo = maskerFrom(ctx).maskAll(additionalInfo)
+	eventMessage := formatEventMessage(logger, message, additionalInfo...)
 	r.EventRecorder.Event(obj, Normal, reason, eventMessage)
 	logger.Info(message, additionalInfo...)
 }
 
-// createSecret creates a new K8s secret owned by owner with the data contained in output and dsn.
-func (r *DatabaseReconciler) createSecret(owner *databasev1.Database, secretFormat database.SecretFormat, output database.OpOutput) ReconcileError {
+// createSecret writes the credentials of owner, rendered from output, to the SecretSink of dbClass. If credentials
+// written for owner are already present, e.g. when a database instance is recreated after a drift, they are updated.
+func (r *DatabaseReconciler) createSecret(ctx context.Context, owner *databasev1.Database, dbClass databaseclassv1.DatabaseClass, opValues database.OpValues, output database.OpOutput) ReconcileError {
+	logger := log.FromContext(ctx)
 	logger.V(DebugLevel).Info("Creating secret for database resource")
 
-	// Init vars
-	secretName := FormatSecretName(owner)
-	loggingKv := StringsToInterfaceSlice("secret", secretName)
-	secretData, err := secretFormat.RenderSecretFormat(output)
-	if err != nil {
-		return ReconcileError{
-			Reason:         RsnSecretRenderFail,
-			Message:        MsgSecretRenderFail,
-			Err:            err,
-			AdditionalInfo: loggingKv,
-		}
-	}
-	var ownerRefs []metav1.OwnerReference
-	ownerRefs = append(ownerRefs, metav1.OwnerReference{
-		APIVersion: owner.APIVersion,
-		Kind:       owner.Kind,
-		Name:       owner.Name,
-		UID:        owner.UID,
-		Controller: &[]bool{true}[0], // sets this controller as owner
-	})
-	secret := &corev1.Secret{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:            secretName,
-			Namespace:       owner.Namespace,
-			OwnerReferences: ownerRefs,
-		},
-		StringData: secretData,
+	sink, err := r.secretSink(dbClass)
+	if err.IsNotEmpty() {
+		return err
 	}
-	key := client.ObjectKey{
-		Namespace: owner.Namespace,
-		Name:      secretName,
+	secretName, err := currentSecretName(owner, dbClass)
+	if err.IsNotEmpty() {
+		return err
 	}
-	oldSecret := corev1.Secret{}
-	// Get old Secret if present
-	err = r.Client.Get(context.Background(), key, &oldSecret)
-	if err != nil {
-		// If Secret was not found, it must be created
-		if k8sError.IsNotFound(err) {
-			// Create new Secret
-			if err := r.Client.Create(context.Background(), secret); err != nil {
-				return ReconcileError{
-					Reason:         MsgSecretCreateFail,
-					Message:        MsgSecretCreateFail,
-					Err:            err,
-					AdditionalInfo: loggingKv,
-				}
-			}
-			r.logInfoEvent(owner, RsnSecretCreateSucc, MsgSecretCreateSucc, loggingKv...)
-			return ReconcileError{}
-		}
-		// Return error
-		return ReconcileError{
-			Reason:         RsnSecretGetFail,
-			Message:        MsgSecretGetFail,
-			Err:            err,
-			AdditionalInfo: loggingKv,
-		}
-	} else {
+	loggingKv := StringsToInterfaceSlice("secret", sink.Location(owner, secretName))
+	if _, simpleErr := sink.Read(ctx, owner, secretName); simpleErr == nil {
+		return r.updateSecret(ctx, owner, dbClass, opValues, output)
+	} else if errors.Is(simpleErr, secretsink.ErrNotOwned) {
 		// Create was called on already existing Secret
 		return ReconcileError{
 			Reason:         RsnSecretExists,
 			Message:        MsgSecretExists,
-			Err:            err,
+			Err:            simpleErr,
 			AdditionalInfo: loggingKv,
 		}
+	} else if !errors.Is(simpleErr, secretsink.ErrNotFound) {
+		return ReconcileError{
+			Reason:         RsnSecretGetFail,
+			Message:        MsgSecretGetFail,
+			Err:            simpleErr,
+			AdditionalInfo: loggingKv,
+		}
+	}
+	if err := r.writeSecret(ctx, owner, dbClass, sink, opValues, output, RsnSecretCreateFail, MsgSecretCreateFail); err.IsNotEmpty() {
+		return err
 	}
+	loggingKv = StringsToInterfaceSlice("secret", owner.Status.SecretLocation)
+	r.logInfoEvent(ctx, owner, RsnSecretCreateSucc, MsgSecretCreateSucc, loggingKv...)
+	return ReconcileError{}
 }
 
-// createSecret creates a new K8s secret owned by owner with the data contained in output and dsn.
-func (r *DatabaseReconciler) updateSecret(owner *databasev1.Database, secretFormat database.SecretFormat, output database.OpOutput) ReconcileError {
+// updateSecret replaces the credentials of owner with the ones rendered from output in the SecretSink of dbClass.
+func (r *DatabaseReconciler) updateSecret(ctx context.Context, owner *databasev1.Database, dbClass databaseclassv1.DatabaseClass, opValues database.OpValues, output database.OpOutput) ReconcileError {
+	logger := log.FromContext(ctx)
 	logger.V(DebugLevel).Info("Updating secret for database resource")
 
-	// TODO: extract common behavior of Secret rendering into a method and put it in createSecret as well (factory method)?
-	// Init vars
-	secretName := FormatSecretName(owner)
-	loggingKv := StringsToInterfaceSlice("secret", secretName)
-	secretData, err := secretFormat.RenderSecretFormat(output)
-	if err != nil {
+	sink, err := r.secretSink(dbClass)
+	if err.IsNotEmpty() {
+		return err
+	}
+	if err := r.writeSecret(ctx, owner, dbClass, sink, opValues, output, RsnSecretUpdateFail, MsgSecretUpdateFail); err.IsNotEmpty() {
+		return err
+	}
+	loggingKv := StringsToInterfaceSlice("secret", owner.Status.SecretLocation)
+	r.logInfoEvent(ctx, owner, RsnSecretUpdateSucc, MsgSecretUpdateSucc, loggingKv...)
+	return ReconcileError{}
+}
+
+// writeSecret renders the SecretFormat of dbClass with output and writes the result to sink. The hash of the result
+// and its location are recorded in the status of owner. If the write fails, the returned error uses reason and message.
+// If the SecretTemplate of owner is immutable, the result is written to a new version of the Secret and the previous
+// version is deleted.
+func (r *DatabaseReconciler) writeSecret(ctx context.Context, owner *databasev1.Database, dbClass databaseclassv1.DatabaseClass, sink secretsink.SecretSink, opValues database.OpValues, output database.OpOutput, reason, message string) ReconcileError {
+	logger := log.FromContext(ctx)
+	template := secretTemplate(owner, dbClass)
+	version := 0
+	if template.IsImmutable() {
+		version = owner.Status.SecretVersion + 1
+	}
+	secretName, err := renderSecretName(owner, template, version)
+	if err.IsNotEmpty() {
+		return err
+	}
+	loggingKv := StringsToInterfaceSlice("secret", sink.Location(owner, secretName))
+	secretData, simpleErr := dbClass.Spec.SecretFormat.RenderSecretFormat(ctx, opValues.WithOutput(output))
+	if simpleErr != nil {
 		return ReconcileError{
 			Reason:         RsnSecretRenderFail,
 			Message:        MsgSecretRenderFail,
-			Err:            err,
+			Err:            simpleErr,
 			AdditionalInfo: loggingKv,
 		}
 	}
-	var ownerRefs []metav1.OwnerReference
-	ownerRefs = append(ownerRefs, metav1.OwnerReference{
+	if dbClass.Spec.ServiceBinding {
+		secretData = secretData.WithBinding(dbClass.Spec.Driver, output)
+	}
+	ownerRef := metav1.OwnerReference{
 		APIVersion: owner.APIVersion,
 		Kind:       owner.Kind,
 		Name:       owner.Name,
 		UID:        owner.UID,
 		Controller: &[]bool{true}[0], // sets this controller as owner
-	})
-	newSecret := &corev1.Secret{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:            secretName,
-			Namespace:       owner.Namespace,
-			OwnerReferences: ownerRefs,
-		},
-		StringData: secretData,
 	}
-	if err := r.Client.Update(context.Background(), newSecret); err != nil {
+	secretHash := secretData.Hash()
+	credentials := secretsink.Credentials{
+		Data:        secretData,
+		Hash:        secretHash,
+		Type:        corev1.SecretType(template.Type),
+		Labels:      template.Labels,
+		Annotations: template.Annotations,
+		Immutable:   template.IsImmutable(),
+	}
+	if err := sink.Write(ctx, owner, ownerRef, secretName, credentials); err != nil {
+		if errors.Is(err, secretsink.ErrNotOwned) {
+			reason, message = RsnSecretExists, MsgSecretExists
+		}
 		return ReconcileError{
-			Reason:         MsgSecretUpdateFail,
-			Message:        MsgSecretUpdateFail,
+			Reason:         reason,
+			Message:        message,
 			Err:            err,
 			AdditionalInfo: loggingKv,
 		}
 	}
-	r.logInfoEvent(owner, RsnSecretUpdateSucc, MsgSecretUpdateSucc, loggingKv...)
+	if previousVersion := owner.Status.SecretVersion; template.IsImmutable() && previousVersion > 0 {
+		// The new version is in place, failing to delete the previous one doesn't affect the credentials
+		if previousName, err := renderSecretName(owner, template, previousVersion); !err.IsNotEmpty() {
+			if err := sink.Delete(ctx, owner, previousName); err != nil {
+				logger.Error(err, MsgSecretDeleteFail, "secret", sink.Location(owner, previousName))
+			}
+		}
+	}
+	owner.Status.SecretHash = secretHash
+	owner.Status.SecretSink = string(secretSinkType(dbClass))
+	owner.Status.SecretLocation = sink.Location(owner, secretName)
+	owner.Status.SecretVersion = version
+	// Only Secrets can be projected into workloads by the Service Binding specification
+	owner.Status.Binding = nil
+	if secretSinkType(dbClass) == databaseclassv1.SecretSinkKubernetes {
+		owner.Status.Binding = &databasev1.ServiceBinding{Name: secretName}
+	}
 	return ReconcileError{}
 }
 
-// updateReadyCondition updates the Ready Condition status of obj.
-func (r *DatabaseReconciler) updateReadyCondition(obj *databasev1.Database, status metav1.ConditionStatus, reason, message string) error {
+// deleteSecret deletes the credentials of owner from the SecretSink of dbClass.
+func (r *DatabaseReconciler) deleteSecret(ctx context.Context, owner *databasev1.Database, dbClass databaseclassv1.DatabaseClass) ReconcileError {
+	sink, err := r.secretSink(dbClass)
+	if err.IsNotEmpty() {
+		return err
+	}
+	secretName, err := currentSecretName(owner, dbClass)
+	if err.IsNotEmpty() {
+		return err
+	}
+	if err := sink.Delete(ctx, owner, secretName); err != nil {
+		return ReconcileError{
+			Reason:         RsnSecretDeleteFail,
+			Message:        MsgSecretDeleteFail,
+			Err:            err,
+			AdditionalInfo: StringsToInterfaceSlice("secret", sink.Location(owner, secretName)),
+		}
+	}
+	return ReconcileError{}
+}
+
+// secretSink returns the SecretSink configured by dbClass. See databaseclassv1.SecretSink.
+func (r *DatabaseReconciler) secretSink(dbClass databaseclassv1.DatabaseClass) (secretsink.SecretSink, ReconcileError) {
+	config := dbClass.Spec.SecretSink
+	sinkType := secretSinkType(dbClass)
+	invalid := func(err error) ReconcileError {
+		return ReconcileError{
+			Reason:         RsnSecretSinkInvalid,
+			Message:        MsgSecretSinkInvalid,
+			Err:            err,
+			AdditionalInfo: StringsToInterfaceSlice(DatabaseClass, dbClass.Name, "secretSink", string(sinkType)),
+		}
+	}
+	switch sinkType {
+	case databaseclassv1.SecretSinkKubernetes:
+		return secretsink.Kubernetes{Client: r.Client}, ReconcileError{}
+	case databaseclassv1.SecretSinkVault:
+		if config.Vault == nil {
+			return nil, invalid(fmt.Errorf("vault must be specified for secret sink of type '%s'", sinkType))
+		}
+		return secretsink.Vault{
+			Reader:            r.Client,
+			Address:           config.Vault.Address,
+			Mount:             config.Vault.Mount,
+			Path:              config.Vault.Path,
+			TokenSecretKeyRef: config.Vault.TokenSecretKeyRef,
+			DefaultNamespace:  r.Namespace,
+		}, ReconcileError{}
+	case databaseclassv1.SecretSinkFile:
+		if config.File == nil || config.File.Directory == "" {
+			return nil, invalid(fmt.Errorf("file.directory must be specified for secret sink of type '%s'", sinkType))
+		}
+		return secretsink.File{Directory: config.File.Directory}, ReconcileError{}
+	default:
+		return nil, invalid(fmt.Errorf("unknown secret sink type '%s'", sinkType))
+	}
+}
+
+// secretSinkType returns the type of the SecretSink configured by dbClass, databaseclassv1.SecretSinkKubernetes if
+// none is configured.
+func secretSinkType(dbClass databaseclassv1.DatabaseClass) databaseclassv1.SecretSinkType {
+	if dbClass.Spec.SecretSink == nil || dbClass.Spec.SecretSink.Type == "" {
+		return databaseclassv1.SecretSinkKubernetes
+	}
+	return dbClass.Spec.SecretSink.Type
+}
+
+// secretTemplate returns the SecretTemplate of dbClass overridden by the SecretTemplate of obj.
+func secretTemplate(obj *databasev1.Database, dbClass databaseclassv1.DatabaseClass) database.SecretTemplate {
+	template := database.SecretTemplate{}
+	if dbClass.Spec.SecretTemplate != nil {
+		template = *dbClass.Spec.SecretTemplate
+	}
+	return template.Merge(obj.Spec.SecretTemplate)
+}
+
+// renderSecretName renders the name of the Secret of obj from template. version is only used by immutable templates.
+func renderSecretName(obj *databasev1.Database, template database.SecretTemplate, version int) (string, ReconcileError) {
+	opValues, err := newOpValuesFromLiterals(obj)
+	if err.IsNotEmpty() {
+		return "", err
+	}
+	name, simpleErr := template.RenderName(opValues, version)
+	if simpleErr != nil {
+		return "", ReconcileError{
+			Reason:  RsnSecretNameInvalid,
+			Message: MsgSecretNameInvalid,
+			Err:     simpleErr,
+		}
+	}
+	return name, ReconcileError{}
+}
+
+// currentSecretName returns the name of the Secret the credentials of obj are currently stored in, i.e. the name of the
+// last version written if the SecretTemplate of obj is immutable.
+func currentSecretName(obj *databasev1.Database, dbClass databaseclassv1.DatabaseClass) (string, ReconcileError) {
+	version := obj.Status.SecretVersion
+	if version == 0 {
+		// No version was written yet, the first one is expected
+		version = 1
+	}
+	return renderSecretName(obj, secretTemplate(obj, dbClass), version)
+}
+
+// updateReadyCondition updates the Ready Condition status of obj. If status is true, the Stalled condition is removed.
+func (r *DatabaseReconciler) updateReadyCondition(ctx context.Context, obj *databasev1.Database, status metav1.ConditionStatus, reason, message string) error {
+	if status == metav1.ConditionTrue {
+		meta.RemoveStatusCondition(&obj.Status.Conditions, TypeStalled)
+	}
 	meta.SetStatusCondition(&obj.Status.Conditions, metav1.Condition{
 		Type:    TypeReady,
 		Status:  status,
@@ -676,20 +1428,17 @@ func (r *DatabaseReconciler) updateReadyCondition(obj *databasev1.Database, stat
 	})
 
 	// Update condition field
-	return r.Client.Status().Update(context.Background(), obj)
+	return r.Client.Status().Update(ctx, obj)
 }
 
 // shouldRotate returns true if there isn't any Secret associated with the given Database object (secret deletion),
 // or if the rotate annotation is present. It returns false otherwise, or if an error was generated during execution.
-func (r *DatabaseReconciler) shouldRotate(obj *databasev1.Database) (bool, ReconcileError) {
+func (r *DatabaseReconciler) shouldRotate(ctx context.Context, obj *databasev1.Database) (bool, ReconcileError) {
+	logger := log.FromContext(ctx)
 	logger.V(TraceLevel).Info("Checking if credentials should be rotated")
-	if isSecretPresent, err := r.isSecretPresent(obj); !isSecretPresent {
+	if isSecretPresent, err := r.isSecretPresent(ctx, obj); !isSecretPresent {
 		if err.IsNotEmpty() {
-			return false, ReconcileError{
-				Reason:  RsnSecretGetFail,
-				Message: MsgSecretGetFail,
-				Err:     err.Err,
-			}
+			return false, err
 		}
 		return true, ReconcileError{}
 	}
@@ -697,29 +1446,137 @@ func (r *DatabaseReconciler) shouldRotate(obj *databasev1.Database) (bool, Recon
 	if isRotateAnnotationTrue(obj) {
 		return true, ReconcileError{}
 	}
+	// Rotate if any value referenced by the ParamsFrom changed since the last create or rotate operation
+	params, err := resolveParamsFrom(ctx, r.Client, obj)
+	if err != nil {
+		return false, ReconcileError{
+			Reason:  RsnParamsResolveFail,
+			Message: MsgParamsResolveFail,
+			Err:     err,
+		}
+	}
+	if paramsFromHash(obj, params) != obj.Status.ParamsHash {
+		logger.Info("Parameters referenced by the database resource changed, rotating credentials")
+		return true, ReconcileError{}
+	}
+	return false, ReconcileError{}
+}
+
+// restoreTamperedSecret checks whether the Secret bound to obj was modified by someone other than the Operator, by
+// comparing the hash of its content with the hash stored in the status of obj. If so, depending on the
+// SecretTamperPolicy of the DatabaseClass, the Secret is rendered again from the non-sensitive outputs stored in the
+// status of obj, or the credentials must be rotated. It returns true if the credentials must be rotated.
+func (r *DatabaseReconciler) restoreTamperedSecret(ctx context.Context, obj *databasev1.Database) (bool, ReconcileError) {
+	logger := log.FromContext(ctx)
+	// Resources created by previous versions of the Operator don't store any hash
+	if obj.Status.SecretHash == "" {
+		return false, ReconcileError{}
+	}
+	dbClass, err := r.getDbmsClassFromDb(ctx, obj)
+	if err.IsNotEmpty() {
+		return false, err
+	}
+	sink, err := r.secretSink(dbClass)
+	if err.IsNotEmpty() {
+		return false, err
+	}
+	secretName, err := currentSecretName(obj, dbClass)
+	if err.IsNotEmpty() {
+		return false, err
+	}
+	loggingKv := StringsToInterfaceSlice("secret", sink.Location(obj, secretName))
+	logger.V(TraceLevel).Info("Checking if secret bound to Database resource was modified")
+
+	credentials, simpleErr := sink.Read(ctx, obj, secretName)
+	if simpleErr != nil {
+		if errors.Is(simpleErr, secretsink.ErrNotFound) || errors.Is(simpleErr, secretsink.ErrNotOwned) {
+			// Missing Secrets are handled by shouldRotate
+			return false, ReconcileError{}
+		}
+		return false, ReconcileError{
+			Reason:         RsnSecretGetFail,
+			Message:        MsgSecretGetFail,
+			Err:            simpleErr,
+			AdditionalInfo: loggingKv,
+		}
+	}
+	// The hash recorded by the sink is checked as well since the status of obj might not be up-to-date yet right after
+	// the Operator wrote the Secret
+	secretHash := credentials.Data.Hash()
+	if secretHash == obj.Status.SecretHash || secretHash == credentials.Hash {
+		return false, ReconcileError{}
+	}
+	r.EventRecorder.Event(obj, Warning, RsnSecretTampered, formatEventMessage(logger, MsgSecretTampered, loggingKv...))
+	logger.Info(MsgSecretTampered, loggingKv...)
+
+	if dbClass.Spec.SecretTamperPolicy != databaseclassv1.SecretTamperPolicyRerender {
+		return true, ReconcileError{}
+	}
+	opValues, err := r.newOpValuesFromResource(ctx, obj)
+	if err.IsNotEmpty() {
+		return false, err
+	}
+	output := database.OpOutput{Result: obj.Status.Outputs}
+	secretData, simpleErr := dbClass.Spec.SecretFormat.RenderSecretFormat(ctx, opValues.WithOutput(output))
+	if simpleErr != nil {
+		// The Secret cannot be rendered from non-sensitive outputs alone
+		logger.V(DebugLevel).Info("Secret cannot be rendered from non-sensitive outputs, rotating credentials",
+			"error", maskerFrom(ctx).mask(simpleErr.Error()))
+		return true, ReconcileError{}
+	}
+	if dbClass.Spec.ServiceBinding && secretData.WithBinding(dbClass.Spec.Driver, output)[database.BindingPasswordKey] == "" {
+		// A Secret without password can't be bound to workloads
+		logger.V(DebugLevel).Info("Secret rendered from non-sensitive outputs has no password, rotating credentials")
+		return true, ReconcileError{}
+	}
+	if err := r.updateSecret(ctx, obj, dbClass, opValues, output); err.IsNotEmpty() {
+		return false, err
+	}
+	if err := r.updateReadyCondition(ctx, obj, metav1.ConditionTrue, RsnSecretRestoreSucc, MsgSecretRestoreSucc); err != nil {
+		return false, ReconcileError{
+			Reason:         RsnReadyCondUpdateFail,
+			Message:        MsgReadyCondUpdateFail,
+			Err:            err,
+			AdditionalInfo: loggingKv,
+		}
+	}
+	r.logInfoEvent(ctx, obj, RsnSecretRestoreSucc, MsgSecretRestoreSucc, loggingKv...)
 	return false, ReconcileError{}
 }
 
-// isSecretPresent returns true if the Secret bound to obj is present. It returns false otherwise, or if an
-// error was generated during execution.
-func (r *DatabaseReconciler) isSecretPresent(obj *databasev1.Database) (bool, ReconcileError) {
-	secretName := FormatSecretName(obj)
-	loggingKv := StringsToInterfaceSlice(SecretName, secretName)
+// isSecretPresent returns true if the Secret bound to obj is present in the SecretSink of its DatabaseClass. It
+// returns false otherwise, or if an error was generated during execution.
+func (r *DatabaseReconciler) isSecretPresent(ctx context.Context, obj *databasev1.Database) (bool, ReconcileError) {
+	logger := log.FromContext(ctx)
 	logger.V(TraceLevel).Info("Checking if secret bound to Database resource is present")
 
-	var secret corev1.Secret
-	secretObjKey := client.ObjectKey{Namespace: obj.Namespace, Name: FormatSecretName(obj)}
-	if err := r.Client.Get(context.Background(), secretObjKey, &secret); err != nil {
-		if k8sError.IsNotFound(err) {
+	dbClass, err := r.getDbmsClassFromDb(ctx, obj)
+	if err.IsNotEmpty() {
+		return false, err
+	}
+	sink, err := r.secretSink(dbClass)
+	if err.IsNotEmpty() {
+		return false, err
+	}
+	secretName, err := currentSecretName(obj, dbClass)
+	if err.IsNotEmpty() {
+		return false, err
+	}
+	if _, err := sink.Read(ctx, obj, secretName); err != nil {
+		if errors.Is(err, secretsink.ErrNotFound) {
 			// Secret for given object is not present
 			return false, ReconcileError{}
 		}
+		if errors.Is(err, secretsink.ErrNotOwned) {
+			// Secret is present but was not written for obj, see createSecret
+			return true, ReconcileError{}
+		}
 		// Another error was generated while getting the Secret, return it
 		return false, ReconcileError{
 			Reason:         RsnSecretGetFail,
 			Message:        MsgSecretGetFail,
 			Err:            err,
-			AdditionalInfo: loggingKv,
+			AdditionalInfo: StringsToInterfaceSlice("secret", sink.Location(obj, secretName)),
 		}
 	}
 	return true, ReconcileError{}
@@ -737,10 +1594,18 @@ func (r ReconcileError) With(values []interface{}) ReconcileError {
 		Message:        r.Message,
 		Err:            r.Err,
 		AdditionalInfo: append(r.AdditionalInfo, values...),
+		Terminal:       r.Terminal,
 	}
 }
 
-// FormatSecretName returns the name of a Database's Secret resource as it should appear in metadata.name.
+// IsTerminal returns true if r can't be solved by retrying, i.e. if it is marked as Terminal, its reason belongs to
+// terminalReasons or Err is a terminal DBMS error. See database.IsTerminalError.
+func (r ReconcileError) IsTerminal() bool {
+	return r.Terminal || terminalReasons[r.Reason] || database.IsTerminalError(r.Err)
+}
+
+// FormatSecretName returns the name of a Database's Secret resource as it should appear in metadata.name if neither the
+// Database resource nor its DatabaseClass configure a SecretTemplate.
 func FormatSecretName(obj *databasev1.Database) string {
 	return obj.Name + "-credentials"
 }
@@ -751,8 +1616,9 @@ func (r *DatabaseReconciler) triggerReconciler() predicate.Predicate {
 	return predicate.Funcs{
 		GenericFunc: func(e event.GenericEvent) bool {
 			obj := e.Object.(*databasev1.Database)
+			ctx := log.IntoContext(context.Background(), r.Log.WithValues("database", client.ObjectKeyFromObject(obj)))
 			// If credentials are supposed to be rotated
-			if shouldRotate, err := r.shouldRotate(obj); shouldRotate || err.IsNotEmpty() {
+			if shouldRotate, err := r.shouldRotate(ctx, obj); shouldRotate || err.IsNotEmpty() {
 				return true
 			}
 			// If object is supposed to be deleted
@@ -768,6 +1634,53 @@ func (r *DatabaseReconciler) triggerReconciler() predicate.Predicate {
 	}
 }
 
+// isStalled checks whether the Stalled condition of obj was set by a terminal error for the current generation of obj
+// and of its DatabaseClass. See handleReconcileError.
+func (r *DatabaseReconciler) isStalled(ctx context.Context, obj *databasev1.Database) bool {
+	stalled := meta.FindStatusCondition(obj.Status.Conditions, TypeStalled)
+	if stalled == nil || stalled.Status != metav1.ConditionTrue || stalled.ObservedGeneration != obj.Generation {
+		return false
+	}
+	return obj.Status.StalledClassGeneration == r.databaseClassGeneration(ctx, obj)
+}
+
+// databaseClassGeneration returns the generation of the DatabaseClass of obj, 0 if it can't be read.
+func (r *DatabaseReconciler) databaseClassGeneration(ctx context.Context, obj *databasev1.Database) int64 {
+	dbClassName := r.DbmsList.GetDatabaseClassNameByEndpointName(obj.Spec.Endpoint)
+	if dbClassName == "" {
+		return 0
+	}
+	dbClass := databaseclassv1.DatabaseClass{}
+	if err := r.Get(ctx, client.ObjectKey{Name: dbClassName}, &dbClass); err != nil {
+		return 0
+	}
+	return dbClass.Generation
+}
+
+// stalledDatabases returns a handler.MapFunc enqueueing the stalled Database resources bound to the endpoints of a
+// DatabaseClass, so that a change of the DatabaseClass solving their terminal error is detected by isStalled.
+func (r *DatabaseReconciler) stalledDatabases(dbClass client.Object) []reconcile.Request {
+	list := databasev1.DatabaseList{}
+	if err := r.List(context.Background(), &list); err != nil {
+		r.Log.Error(err, "could not list database resources of databaseclass", DatabaseClass, dbClass.GetName())
+		return nil
+	}
+	var requests []reconcile.Request
+	for i := range list.Items {
+		db := &list.Items[i]
+		if meta.IsStatusConditionTrue(db.Status.Conditions, TypeStalled) && r.DbmsList.GetDatabaseClassNameByEndpointName(db.Spec.Endpoint) == dbClass.GetName() {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(db)})
+		}
+	}
+	return requests
+}
+
+// isDriftDetected checks whether the Ready condition of obj reports a drift. See checkDrift.
+func isDriftDetected(obj *databasev1.Database) bool {
+	ready := meta.FindStatusCondition(obj.Status.Conditions, TypeReady)
+	return ready != nil && ready.Status == metav1.ConditionFalse && ready.Reason == RsnDbDriftDetected
+}
+
 // isRotateAnnotationTrue checks whether the rotate annotation is present and set to true or ""
 func isRotateAnnotationTrue(obj client.Object) bool {
 	if val, ok := obj.GetAnnotations()[rotateAnnotationKey]; ok && val == "" || val == "true" {
@@ -776,8 +1689,130 @@ func isRotateAnnotationTrue(obj client.Object) bool {
 	return false
 }
 
-// newOpValuesFromResource constructs a database.OpValues struct starting from a Database resource.
-func newOpValuesFromResource(obj *databasev1.Database) (database.OpValues, ReconcileError) {
+// newGenerator returns the database.Generator of the operations of dbClass, deriving its values from seed.
+func newGenerator(seed []byte, dbClass databaseclassv1.DatabaseClass) *database.Generator {
+	policy := database.PasswordPolicy{}
+	if dbClass.Spec.PasswordPolicy != nil {
+		policy = *dbClass.Spec.PasswordPolicy
+	}
+	return database.NewGenerator(seed, policy)
+}
+
+// NewInstanceNamer returns a databasev1.InstanceNamer naming the database instances of the endpoints of p, see
+// instanceName.
+func NewInstanceNamer(p pool.Pool, clusterID string) databasev1.InstanceNamer {
+	return func(obj *databasev1.Database) string {
+		return instanceName(p, clusterID, obj)
+	}
+}
+
+// instanceName returns the name of the database instance of obj: the one recorded in its status if it was already
+// created, otherwise the one computed by database.InstanceName with the driver of its endpoint in p and clusterID.
+// Database resources created by previous versions of the Operator have no instance name in their status but carry the
+// finalizer added once their instance was created: their instance is named after the resource.
+func instanceName(p pool.Pool, clusterID string, obj *databasev1.Database) string {
+	if obj.Status.InstanceName != "" {
+		return obj.Status.InstanceName
+	}
+	if contains(obj.GetFinalizers(), databaseFinalizer) {
+		return obj.Name
+	}
+	var driver string
+	if entry, ok := p.Get(obj.Spec.Endpoint).(*pool.DbmsEntry); ok {
+		driver = entry.Driver()
+	}
+	return database.InstanceName(driver, clusterID, obj.Namespace, obj.Name)
+}
+
+// persistedOutputs returns the non-sensitive outputs of obj once output has been returned by an operation. The values
+// of output take precedence, the ones it doesn't return are kept from the previous operations, so that an identifier
+// returned by create is still available to rotate and delete if rotate doesn't return it again. Keys which are no longer
+// declared as non-sensitive by dbClass are dropped, see nonSensitiveOutputs.
+func persistedOutputs(obj *databasev1.Database, dbClass databaseclassv1.DatabaseClass, output database.OpOutput) map[string]string {
+	keys := nonSensitiveOutputs(dbClass)
+	persisted := database.OpOutput{Result: obj.Status.Outputs}.Filter(keys)
+	for k, v := range output.Filter(keys) {
+		if persisted == nil {
+			persisted = make(map[string]string)
+		}
+		persisted[k] = v
+	}
+	return persisted
+}
+
+// nonSensitiveOutputs returns the keys of the deprecated NonSensitiveOutputs of dbClass along with the keys declared as
+// non-sensitive by its operations, see database.Operation.Outputs.
+func nonSensitiveOutputs(dbClass databaseclassv1.DatabaseClass) []string {
+	keys := append([]string{}, dbClass.Spec.NonSensitiveOutputs...)
+	for _, operation := range dbClass.Spec.Operations {
+		keys = append(keys, operation.NonSensitiveOutputs()...)
+	}
+	return keys
+}
+
+// validateOutput checks that output contains the required keys declared by the operation opTemplate, see
+// database.OpOutput.MissingKeys. The missing keys are listed in the message of the returned error, so that they are
+// shown in the Ready condition of the Database resource.
+func validateOutput(opTemplate database.Operation, output database.OpOutput) ReconcileError {
+	missing := output.MissingKeys(opTemplate.Outputs)
+	if len(missing) == 0 {
+		return ReconcileError{}
+	}
+	return ReconcileError{
+		Reason:  RsnOpOutputInvalid,
+		Message: fmt.Sprintf("%s: %s", MsgOpOutputInvalid, strings.Join(missing, ", ")),
+		Err:     fmt.Errorf("operation '%s' did not return the declared keys %v", opTemplate.Name, missing),
+	}
+}
+
+// newOpValuesFromResource constructs a database.OpValues struct starting from a Database resource. The ParamsFrom of obj
+// take precedence over its literal Params, see resolveParamsFrom. The other values describe the context of obj, e.g.
+// its namespace and endpoint, see database.TemplateContextVersion.
+func (r *DatabaseReconciler) newOpValuesFromResource(ctx context.Context, obj *databasev1.Database) (database.OpValues, ReconcileError) {
+	opValues, err := newOpValuesFromLiterals(obj)
+	if err.IsNotEmpty() {
+		return database.OpValues{}, err
+	}
+	params, simpleErr := resolveParamsFrom(ctx, r.Client, obj)
+	if simpleErr != nil {
+		return database.OpValues{}, ReconcileError{
+			Reason:  RsnParamsResolveFail,
+			Message: MsgParamsResolveFail,
+			Err:     simpleErr,
+		}
+	}
+	if len(params) > 0 && opValues.Parameters == nil {
+		opValues.Parameters = make(map[string]string, len(params))
+	}
+	for k, v := range params {
+		opValues.Parameters[k] = v
+	}
+
+	namespace := corev1.Namespace{}
+	if simpleErr := r.Get(ctx, client.ObjectKey{Name: obj.Namespace}, &namespace); simpleErr != nil {
+		return database.OpValues{}, ReconcileError{
+			Reason:         RsnNamespaceGetFail,
+			Message:        MsgNamespaceGetFail,
+			Err:            simpleErr,
+			AdditionalInfo: StringsToInterfaceSlice("namespace", obj.Namespace),
+		}
+	}
+	opValues.Namespace = database.NamespaceValues{Name: namespace.Name, Labels: namespace.Labels}
+	opValues.Endpoint = database.EndpointValues{Name: obj.Spec.Endpoint}
+	if entry, ok := r.Pool.Get(obj.Spec.Endpoint).(*pool.DbmsEntry); ok {
+		opValues.Endpoint.Host, opValues.Endpoint.Port = entry.Dsn().HostPort()
+	}
+	opValues.DatabaseClass = r.DbmsList.GetDatabaseClassNameByEndpointName(obj.Spec.Endpoint)
+	opValues.ClusterID = r.ClusterID
+	opValues.InstanceName = instanceName(r.Pool, r.ClusterID, obj)
+	opValues.Outputs = obj.Status.Outputs
+	return opValues, ReconcileError{}
+}
+
+// newOpValuesFromLiterals constructs a database.OpValues struct from the metadata and the literal Params of a Database
+// resource, i.e. without resolving its ParamsFrom. It is used for values which must not depend on referenced values,
+// e.g. the name of the Secret.
+func newOpValuesFromLiterals(obj *databasev1.Database) (database.OpValues, ReconcileError) {
 	metaIn := obj.ObjectMeta
 	var metadata map[string]interface{}
 	temp, _ := json.Marshal(metaIn)
@@ -802,6 +1837,7 @@ func newOpValuesFromResource(obj *databasev1.Database) (database.OpValues, Recon
 	}
 
 	return database.OpValues{
+		Version:    database.TemplateContextVersion,
 		Metadata:   metadata,
 		Parameters: spec,
 	}, ReconcileError{}
@@ -809,9 +1845,9 @@ func newOpValuesFromResource(obj *databasev1.Database) (database.OpValues, Recon
 
 // formatEventMessage formats an event message with key and values formatted as a json key-value structure. If keyAndValues
 // is empty, it returns the message back.
-func formatEventMessage(message string, keyAndValues ...interface{}) string {
+func formatEventMessage(logger logr.Logger, message string, keyAndValues ...interface{}) string {
 	if len(keyAndValues) > 0 {
-		extraValues := formatKeyAndValuesAsJson(keyAndValues)
+		extraValues := formatKeyAndValuesAsJson(logger, keyAndValues)
 		if extraValues != "" {
 			return fmt.Sprintf("%s: %s", message, extraValues)
 		}
@@ -820,7 +1856,7 @@ func formatEventMessage(message string, keyAndValues ...interface{}) string {
 }
 
 // formatKeyAndValuesAsJson converts a slice of interface{} into a json key-value string. Keys need to be strings by JSON's convention.
-func formatKeyAndValuesAsJson(keyAndValues []interface{}) string {
+func formatKeyAndValuesAsJson(logger logr.Logger, keyAndValues []interface{}) string {
 	keyAndValuesLen := len(keyAndValues)
 	if keyAndValuesLen%2 != 0 {
 		logger.Error(fmt.Errorf("expected an even number of arguments, provided: %d", keyAndValuesLen),
@@ -82,6 +82,12 @@ func testDatabaseLifecycleHappyPath(db databasev1.Database, duration, timeout, i
 	By("creating the relative Secret resource successfully", func() {
 		assertSecretCreate(db, timeout, interval)
 	})
+	By("exposing the Secret as a Provisioned Service", func() {
+		assertSecretBindable(db, timeout, interval)
+	})
+	By("persisting the non-sensitive outputs of the create operation", func() {
+		assertOutputsPersisted(db, timeout, interval)
+	})
 	By("rotating the credentials", func() {
 		// Add rotate annotation
 		Expect(k8sClient.Get(context.Background(), client.ObjectKey{Namespace: db.Namespace, Name: db.Name}, &db))
@@ -114,7 +120,10 @@ func testDatabaseLifecycleHappyPath(db databasev1.Database, duration, timeout, i
 		Eventually(func() error {
 			return checkDbReady(&db)
 		}, timeout, interval).Should(BeNil())
+		// The rotate operation is rendered with the outputs of the create operation, which must still be persisted
+		assertOutputsPersisted(db, timeout, interval)
 	})
+	// The delete operation is rendered with the outputs of the create operation, see testdata/dbclass-*.yaml
 	By("deleting the API resource successfully", func() {
 		performAndAssertDbDelete(db, timeout, interval)
 	})
@@ -185,6 +194,49 @@ func assertSecretCreate(db databasev1.Database, timeout, interval interface{}) {
 	Expect(secret.Data).To(HaveKeyWithValue("password", []byte("testpassword")))
 }
 
+// assertSecretBindable asserts the Secret of db can be projected into a workload the way a Service Binding
+// implementation does: the Secret is found through status.binding.name and each of its keys becomes a file.
+func assertSecretBindable(db databasev1.Database, timeout, interval interface{}) {
+	freshDb := databasev1.Database{}
+	Eventually(func() *databasev1.ServiceBinding {
+		_ = k8sClient.Get(context.Background(), client.ObjectKeyFromObject(&db), &freshDb)
+		return freshDb.Status.Binding
+	}, timeout, interval).ShouldNot(BeNil())
+	secret := v1.Secret{}
+	Expect(k8sClient.Get(context.Background(), client.ObjectKey{Namespace: db.Namespace,
+		Name: freshDb.Status.Binding.Name}, &secret)).Should(Succeed())
+	// Project the Secret like a ServiceBinding would, i.e. to $SERVICE_BINDING_ROOT/<binding name>/<key>
+	bindingRoot, err := ioutil.TempDir("", "bindings")
+	Expect(err).NotTo(HaveOccurred())
+	defer os.RemoveAll(bindingRoot)
+	bindingDir := path.Join(bindingRoot, db.Name)
+	Expect(os.Mkdir(bindingDir, 0700)).To(Succeed())
+	for key, value := range secret.Data {
+		Expect(ioutil.WriteFile(path.Join(bindingDir, key), value, 0600)).To(Succeed())
+	}
+	for _, key := range []string{"type", "provider", "host", "port", "database", "username", "password"} {
+		value, err := ioutil.ReadFile(path.Join(bindingDir, key))
+		Expect(err).NotTo(HaveOccurred(), "missing well-known binding entry '%s'", key)
+		Expect(value).NotTo(BeEmpty(), "empty well-known binding entry '%s'", key)
+	}
+	// Taken from testdata/db-postgres.yaml
+	Expect(ioutil.ReadFile(path.Join(bindingDir, "password"))).To(Equal([]byte("testpassword")))
+}
+
+// assertOutputsPersisted asserts the instance name and the non-sensitive outputs of db have been persisted in its
+// status, so that they can be used by later operations.
+func assertOutputsPersisted(db databasev1.Database, timeout, interval interface{}) {
+	freshDb := databasev1.Database{}
+	Eventually(func() map[string]string {
+		_ = k8sClient.Get(context.Background(), client.ObjectKeyFromObject(&db), &freshDb)
+		return freshDb.Status.Outputs
+	}, timeout, interval).Should(HaveKey("dbName"))
+	// The create operation of testdata/dbclass-*.yaml is rendered with the instance name, which is unique across
+	// namespaces
+	Expect(freshDb.Status.InstanceName).To(HavePrefix(db.Namespace + "_"))
+	Expect(freshDb.Status.Outputs).To(Equal(map[string]string{"dbName": freshDb.Status.InstanceName}))
+}
+
 // performAndAssertDbDelete deletes a Database resource and asserts it has been deleted successfully. It also deletes
 // the relative Secret resource when using envtest.
 func performAndAssertDbDelete(db databasev1.Database, timeout, interval interface{}) {
@@ -242,3 +294,19 @@ func checkDbReady(db *databasev1.Database) error {
 func isTestEnvUsingExistingCluster() bool {
 	return os.Getenv("TEST_USE_EXISTING_CLUSTER") == "true"
 }
+
+var _ = Describe(FormatTestDesc(Unit, "ReconcileError.IsTerminal"), func() {
+	It("should consider errors which can't be solved by retrying as terminal", func() {
+		Expect(ReconcileError{Reason: typeutil.RsnOpNotSupported}.IsTerminal()).To(BeTrue())
+		Expect(ReconcileError{Reason: typeutil.RsnOpOutputInvalid}.IsTerminal()).To(BeTrue())
+		Expect(ReconcileError{Reason: typeutil.RsnDbCreateFail, Terminal: true}.IsTerminal()).To(BeTrue())
+		Expect(ReconcileError{Reason: typeutil.RsnOpRenderFail}.With([]interface{}{"key", "value"}).IsTerminal()).To(BeTrue())
+	})
+	It("should consider the other errors as transient", func() {
+		Expect(ReconcileError{Reason: typeutil.RsnDbmsConnFail, Err: fmt.Errorf("connection refused")}.IsTerminal()).To(BeFalse())
+		Expect(ReconcileError{Reason: typeutil.RsnDbCreateFail}.With([]interface{}{"key", "value"}).IsTerminal()).To(BeFalse())
+		// Solved outside the Database resource and its DatabaseClass
+		Expect(ReconcileError{Reason: typeutil.RsnSecretExists}.IsTerminal()).To(BeFalse())
+		Expect(ReconcileError{Reason: typeutil.RsnDbcConfigGetFail}.IsTerminal()).To(BeFalse())
+	})
+})
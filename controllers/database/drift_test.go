@@ -0,0 +1,105 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	databasev1 "github.com/bedag/kubernetes-dbaas/apis/database/v1"
+	databaseclassv1 "github.com/bedag/kubernetes-dbaas/apis/databaseclass/v1"
+	"github.com/bedag/kubernetes-dbaas/pkg/database"
+	. "github.com/bedag/kubernetes-dbaas/pkg/test"
+	"github.com/bedag/kubernetes-dbaas/pkg/typeutil"
+	"github.com/go-logr/logr"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"time"
+)
+
+// fakeExistsEndpoint is a database.Driver whose exists operation returns output.
+type fakeExistsEndpoint struct {
+	fakeOpEndpoint
+	output database.OpOutput
+}
+
+func (e *fakeExistsEndpoint) Exists(context.Context, database.Operation) database.OpOutput {
+	return e.output
+}
+
+var _ = Describe(FormatTestDesc(Unit, "DatabaseReconciler.checkDrift"), func() {
+	var (
+		r        *DatabaseReconciler
+		endpoint *fakeExistsEndpoint
+		db       *databasev1.Database
+		ctx      context.Context
+	)
+	getReady := func() *metav1.Condition {
+		obj := databasev1.Database{}
+		Expect(r.Get(ctx, client.ObjectKeyFromObject(db), &obj)).To(Succeed())
+		return meta.FindStatusCondition(obj.Status.Conditions, typeutil.TypeReady)
+	}
+	BeforeEach(func() {
+		ctx = context.Background()
+		scheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+		Expect(databasev1.AddToScheme(scheme)).To(Succeed())
+		Expect(databaseclassv1.AddToScheme(scheme)).To(Succeed())
+		db = &databasev1.Database{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "orders"},
+			Spec:       databasev1.DatabaseSpec{Endpoint: "ep"},
+			Status: databasev1.DatabaseStatus{
+				InstanceName: "team_a_orders",
+				Conditions: []metav1.Condition{{
+					Type:               typeutil.TypeReady,
+					Status:             metav1.ConditionTrue,
+					Reason:             typeutil.RsnDbCreateSucc,
+					Message:            typeutil.MsgDbCreateSucc,
+					LastTransitionTime: metav1.Now(),
+				}},
+			},
+		}
+		dbClass := &databaseclassv1.DatabaseClass{
+			ObjectMeta: metav1.ObjectMeta{Name: "dbc"},
+			Spec: databaseclassv1.DatabaseClassSpec{
+				Driver: database.Postgres,
+				Operations: map[string]database.Operation{
+					database.ExistsMapKey: {Name: "sp_exists", Inputs: map[string]string{"name": "{{ .InstanceName }}"}},
+				},
+			},
+		}
+		endpoint = &fakeExistsEndpoint{}
+		r = &DatabaseReconciler{
+			Client:         fake.NewClientBuilder().WithScheme(scheme).WithObjects(db, dbClass).Build(),
+			Log:            logr.Discard(),
+			Scheme:         scheme,
+			EventRecorder:  record.NewFakeRecorder(100),
+			DbmsList:       database.DbmsList{{DatabaseClassName: dbClass.Name, Endpoints: []database.Endpoint{{Name: "ep"}}}},
+			Pool:           fakeEndpointPool{name: "ep", endpoint: endpoint},
+			ResyncInterval: time.Minute,
+		}
+	})
+	It("should keep the Ready condition and check again later if the check fails", func() {
+		endpoint.output = database.OpOutput{Err: errors.New("connection reset")}
+		Expect(r.checkDrift(ctx, db)).To(Equal(ctrl.Result{RequeueAfter: time.Minute}))
+		Expect(getReady().Status).To(Equal(metav1.ConditionTrue))
+		Expect(endpoint.creates).To(Equal(0))
+
+		// A result without the exists key is an error of the check as well
+		endpoint.output = database.OpOutput{Result: map[string]string{}}
+		Expect(r.checkDrift(ctx, db)).To(Equal(ctrl.Result{RequeueAfter: time.Minute}))
+		Expect(getReady().Status).To(Equal(metav1.ConditionTrue))
+	})
+	It("should only report drift if the database instance is missing", func() {
+		endpoint.output = database.OpOutput{Result: map[string]string{database.ExistsResultKey: "false"}}
+		Expect(r.checkDrift(ctx, db)).To(Equal(ctrl.Result{RequeueAfter: time.Minute}))
+		Expect(getReady().Status).To(Equal(metav1.ConditionFalse))
+		Expect(getReady().Reason).To(Equal(typeutil.RsnDbDriftDetected))
+		Expect(endpoint.creates).To(Equal(0))
+	})
+})
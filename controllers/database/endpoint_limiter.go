@@ -0,0 +1,74 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"sync"
+	"time"
+)
+
+// endpointBusyRequeueAfter is the delay after which a Database resource is reconciled again if its endpoint had no
+// free slot.
+const endpointBusyRequeueAfter = time.Second
+
+// endpointLimiter limits the number of reconciliations running concurrently for each endpoint. A nil endpointLimiter
+// doesn't limit anything.
+type endpointLimiter struct {
+	mu       sync.Mutex
+	max      int
+	inFlight map[string]int
+}
+
+// newEndpointLimiter returns an endpointLimiter allowing max concurrent reconciliations per endpoint. If max is 0 or
+// negative, it returns nil.
+func newEndpointLimiter(max int) *endpointLimiter {
+	if max <= 0 {
+		return nil
+	}
+	return &endpointLimiter{
+		max:      max,
+		inFlight: make(map[string]int),
+	}
+}
+
+// tryAcquire takes a slot of endpoint without blocking. It returns false if all the slots of endpoint are taken.
+func (l *endpointLimiter) tryAcquire(endpoint string) bool {
+	if l == nil {
+		return true
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.inFlight[endpoint] >= l.max {
+		return false
+	}
+	l.inFlight[endpoint]++
+	return true
+}
+
+// release frees a slot of endpoint previously taken with tryAcquire.
+func (l *endpointLimiter) release(endpoint string) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.inFlight[endpoint] <= 1 {
+		delete(l.inFlight, endpoint)
+		return
+	}
+	l.inFlight[endpoint]--
+}
@@ -0,0 +1,97 @@
+package controllers
+
+import (
+	"context"
+	databasev1 "github.com/bedag/kubernetes-dbaas/apis/database/v1"
+	. "github.com/bedag/kubernetes-dbaas/pkg/test"
+	"github.com/go-logr/logr"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// panickingStatusClient is a client.Client panicking on status updates.
+type panickingStatusClient struct {
+	client.Client
+}
+
+func (c panickingStatusClient) Status() client.StatusWriter {
+	panic("status updates are not supported")
+}
+
+var _ = Describe(FormatTestDesc(Unit, "endpointLimiter"), func() {
+	It("should limit the number of slots per endpoint", func() {
+		limiter := newEndpointLimiter(2)
+		Expect(limiter.tryAcquire("ep")).To(BeTrue())
+		Expect(limiter.tryAcquire("ep")).To(BeTrue())
+		Expect(limiter.tryAcquire("ep")).To(BeFalse())
+		// Other endpoints have their own slots
+		Expect(limiter.tryAcquire("other-ep")).To(BeTrue())
+
+		limiter.release("ep")
+		Expect(limiter.tryAcquire("ep")).To(BeTrue())
+		Expect(limiter.tryAcquire("ep")).To(BeFalse())
+	})
+	It("should not limit anything if the limit is not positive", func() {
+		limiter := newEndpointLimiter(0)
+		Expect(limiter).To(BeNil())
+		for i := 0; i < 10; i++ {
+			Expect(limiter.tryAcquire("ep")).To(BeTrue())
+		}
+		limiter.release("ep")
+	})
+})
+
+var _ = Describe(FormatTestDesc(Unit, "DatabaseReconciler endpoint limit"), func() {
+	var (
+		r   *DatabaseReconciler
+		req ctrl.Request
+		ctx context.Context
+	)
+	BeforeEach(func() {
+		ctx = context.Background()
+		scheme := runtime.NewScheme()
+		Expect(databasev1.AddToScheme(scheme)).To(Succeed())
+		db := &databasev1.Database{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "orders"},
+			Spec:       databasev1.DatabaseSpec{Endpoint: "ep"},
+		}
+		req = ctrl.Request{NamespacedName: types.NamespacedName{Namespace: db.Namespace, Name: db.Name}}
+		r = &DatabaseReconciler{
+			Client:          fake.NewClientBuilder().WithScheme(scheme).WithObjects(db).Build(),
+			Log:             logr.Discard(),
+			EventRecorder:   record.NewFakeRecorder(100),
+			endpointLimiter: newEndpointLimiter(1),
+		}
+	})
+	It("should requeue resources whose endpoint has no free slot", func() {
+		Expect(r.endpointLimiter.tryAcquire("ep")).To(BeTrue())
+		result, err := r.Reconcile(ctx, req)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(result.RequeueAfter).To(Equal(endpointBusyRequeueAfter))
+		// The resource was left untouched
+		db := databasev1.Database{}
+		Expect(r.Get(ctx, req.NamespacedName, &db)).To(Succeed())
+		Expect(db.Status.Conditions).To(BeEmpty())
+	})
+	It("should release the slot when the reconciliation fails", func() {
+		// No DatabaseClass is configured for the endpoint, the reconciliation fails
+		result, err := r.Reconcile(ctx, req)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(result.RequeueAfter).ToNot(Equal(endpointBusyRequeueAfter))
+		Expect(r.endpointLimiter.tryAcquire("ep")).To(BeTrue())
+	})
+	It("should release the slot when the reconciliation panics", func() {
+		r.Client = panickingStatusClient{Client: r.Client}
+		Expect(func() {
+			_, _ = r.Reconcile(ctx, req)
+		}).To(Panic())
+		Expect(r.endpointLimiter.tryAcquire("ep")).To(BeTrue())
+	})
+})
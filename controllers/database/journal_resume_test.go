@@ -0,0 +1,299 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	databasev1 "github.com/bedag/kubernetes-dbaas/apis/database/v1"
+	databaseclassv1 "github.com/bedag/kubernetes-dbaas/apis/databaseclass/v1"
+	"github.com/bedag/kubernetes-dbaas/pkg/database"
+	"github.com/bedag/kubernetes-dbaas/pkg/journal"
+	. "github.com/bedag/kubernetes-dbaas/pkg/test"
+	"github.com/bedag/kubernetes-dbaas/pkg/typeutil"
+	"github.com/go-logr/logr"
+	"github.com/jackc/pgconn"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// fakeOpEndpoint is a database.Driver counting the create, rotate and delete operations it executes. Create and rotate
+// return output, or createErr if set, delete returns deleteErr.
+type fakeOpEndpoint struct {
+	database.Driver
+	output    map[string]string
+	createErr error
+	deleteErr error
+	creates   int
+	rotates   int
+	deletes   int
+}
+
+func (e *fakeOpEndpoint) CreateDb(context.Context, database.Operation) database.OpOutput {
+	e.creates++
+	if e.createErr != nil {
+		return database.OpOutput{Err: e.createErr}
+	}
+	return database.OpOutput{Result: e.output}
+}
+
+func (e *fakeOpEndpoint) Rotate(context.Context, database.Operation) database.OpOutput {
+	e.rotates++
+	if e.createErr != nil {
+		return database.OpOutput{Err: e.createErr}
+	}
+	return database.OpOutput{Result: e.output}
+}
+
+func (e *fakeOpEndpoint) DeleteDb(context.Context, database.Operation) database.OpOutput {
+	e.deletes++
+	return database.OpOutput{Err: e.deleteErr}
+}
+
+func (e *fakeOpEndpoint) Ping(context.Context) error {
+	return nil
+}
+
+var _ = Describe(FormatTestDesc(Unit, "DatabaseReconciler journal resume"), func() {
+	var (
+		r        *DatabaseReconciler
+		endpoint *fakeOpEndpoint
+		db       *databasev1.Database
+		dbClass  *databaseclassv1.DatabaseClass
+		req      ctrl.Request
+		ctx      context.Context
+	)
+	newReconciler := func() {
+		scheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+		Expect(databasev1.AddToScheme(scheme)).To(Succeed())
+		Expect(databaseclassv1.AddToScheme(scheme)).To(Succeed())
+		namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: db.Namespace}}
+		c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(db, dbClass, namespace).Build()
+		key, err := journal.LoadOrCreateKey(ctx, c, client.ObjectKey{Namespace: "dbaas-system", Name: "journal-key"})
+		Expect(err).ToNot(HaveOccurred())
+		j, err := journal.New(c, key)
+		Expect(err).ToNot(HaveOccurred())
+		r = &DatabaseReconciler{
+			Client:        c,
+			Log:           logr.Discard(),
+			Scheme:        scheme,
+			EventRecorder: record.NewFakeRecorder(100),
+			DbmsList:      database.DbmsList{{DatabaseClassName: dbClass.Name, Endpoints: []database.Endpoint{{Name: "ep"}}}},
+			Pool:          fakeEndpointPool{name: "ep", endpoint: endpoint},
+			Journal:       j,
+		}
+	}
+	recordEntry := func(entry journal.Entry) {
+		Expect(r.Journal.Record(ctx, db, journalOwnerRef(db), entry)).To(Succeed())
+	}
+	getDb := func() databasev1.Database {
+		obj := databasev1.Database{}
+		Expect(r.Get(ctx, req.NamespacedName, &obj)).To(Succeed())
+		return obj
+	}
+	BeforeEach(func() {
+		ctx = context.Background()
+		endpoint = &fakeOpEndpoint{output: map[string]string{"username": "user", "password": "new-password"}}
+		// The Operator crashed during a rotation: the Ready condition was set to false before rotating
+		db = &databasev1.Database{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "orders", UID: "1234"},
+			Spec:       databasev1.DatabaseSpec{Endpoint: "ep"},
+			Status: databasev1.DatabaseStatus{
+				InstanceName: "team_a_orders",
+				Conditions: []metav1.Condition{{
+					Type:               typeutil.TypeReady,
+					Status:             metav1.ConditionFalse,
+					Reason:             typeutil.RsnDbRotateInProg,
+					Message:            typeutil.MsgDbRotateInProg,
+					LastTransitionTime: metav1.Now(),
+				}},
+			},
+		}
+		dbClass = &databaseclassv1.DatabaseClass{
+			ObjectMeta: metav1.ObjectMeta{Name: "dbc"},
+			Spec: databaseclassv1.DatabaseClassSpec{
+				Driver: database.Postgres,
+				Operations: map[string]database.Operation{
+					database.CreateMapKey: {Name: "sp_create", Inputs: map[string]string{"name": "{{ .InstanceName }}"}},
+					database.RotateMapKey: {Name: "sp_rotate", Inputs: map[string]string{"name": "{{ .InstanceName }}"}},
+				},
+				SecretFormat: database.SecretFormat{"password": "{{ .Result.password }}"},
+			},
+		}
+		req = ctrl.Request{NamespacedName: types.NamespacedName{Namespace: db.Namespace, Name: db.Name}}
+		newReconciler()
+	})
+	It("should hand over the output of a rotation interrupted by a crash instead of creating the database", func() {
+		recordEntry(journal.Entry{
+			Operation: database.RotateMapKey,
+			Phase:     journal.PhaseExecuted,
+			Output:    map[string]string{"username": "user", "password": "journaled-password"},
+			Seed:      []byte("seed"),
+		})
+
+		_, err := r.Reconcile(ctx, req)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(endpoint.creates).To(Equal(0))
+		Expect(endpoint.rotates).To(Equal(0))
+
+		obj := getDb()
+		Expect(meta.IsStatusConditionTrue(obj.Status.Conditions, typeutil.TypeReady)).To(BeTrue())
+		secret := corev1.Secret{}
+		Expect(r.Get(ctx, client.ObjectKey{Namespace: db.Namespace, Name: FormatSecretName(db)}, &secret)).To(Succeed())
+		Expect(string(secret.Data["password"])).To(Equal("journaled-password"))
+		entry, journalErr := r.Journal.Get(ctx, db)
+		Expect(journalErr).ToNot(HaveOccurred())
+		Expect(entry).To(BeNil())
+	})
+	It("should execute an interrupted rotation again if it was not executed", func() {
+		recordEntry(journal.Entry{Operation: database.RotateMapKey, Phase: journal.PhaseIntent, Seed: []byte("seed")})
+
+		_, err := r.Reconcile(ctx, req)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(endpoint.creates).To(Equal(0))
+		Expect(endpoint.rotates).To(Equal(1))
+		Expect(meta.IsStatusConditionTrue(getDb().Status.Conditions, typeutil.TypeReady)).To(BeTrue())
+	})
+	It("should execute the operation again instead of resuming a rejected output", func() {
+		rotateOp := dbClass.Spec.Operations[database.RotateMapKey]
+		rotateOp.Outputs = []database.OutputKey{{Name: "username"}, {Name: "password"}}
+		dbClass.Spec.Operations[database.RotateMapKey] = rotateOp
+		newReconciler()
+		recordEntry(journal.Entry{
+			Operation: database.RotateMapKey,
+			Phase:     journal.PhaseExecuted,
+			Output:    map[string]string{"username": "user"},
+			Seed:      []byte("seed"),
+		})
+
+		_, err := r.Reconcile(ctx, req)
+		Expect(err).ToNot(HaveOccurred())
+		obj := getDb()
+		Expect(meta.FindStatusCondition(obj.Status.Conditions, typeutil.TypeReady).Reason).To(Equal(typeutil.RsnOpOutputInvalid))
+		entry, journalErr := r.Journal.Get(ctx, db)
+		Expect(journalErr).ToNot(HaveOccurred())
+		Expect(entry.Phase).To(Equal(journal.PhaseFailed))
+		Expect(entry.Seed).To(Equal([]byte("seed")))
+
+		// Once the resource is no longer stalled, the rotation is executed again rather than resumed
+		meta.RemoveStatusCondition(&obj.Status.Conditions, typeutil.TypeStalled)
+		Expect(r.Status().Update(ctx, &obj)).To(Succeed())
+		_, err = r.Reconcile(ctx, req)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(endpoint.creates).To(Equal(0))
+		Expect(endpoint.rotates).To(Equal(1))
+		Expect(meta.IsStatusConditionTrue(getDb().Status.Conditions, typeutil.TypeReady)).To(BeTrue())
+	})
+	It("should stall if create raises an error on purpose", func() {
+		db.Status = databasev1.DatabaseStatus{}
+		endpoint.createErr = &pgconn.PgError{Code: "P0001", Message: "database orders is locked"}
+		newReconciler()
+
+		_, err := r.Reconcile(ctx, req)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(endpoint.creates).To(Equal(1))
+		obj := getDb()
+		Expect(meta.IsStatusConditionTrue(obj.Status.Conditions, typeutil.TypeReady)).To(BeFalse())
+		Expect(meta.IsStatusConditionTrue(obj.Status.Conditions, typeutil.TypeStalled)).To(BeTrue())
+
+		// Stalled resources are not retried until their spec or DatabaseClass changes
+		_, err = r.Reconcile(ctx, req)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(endpoint.creates).To(Equal(1))
+	})
+	Context("when the output of create is rejected", func() {
+		BeforeEach(func() {
+			db.Status = databasev1.DatabaseStatus{}
+			dbClass.Spec.Operations[database.CreateMapKey] = database.Operation{
+				Name:    "sp_create",
+				Inputs:  map[string]string{"name": "{{ .InstanceName }}"},
+				Outputs: []database.OutputKey{{Name: "username"}, {Name: "password"}},
+			}
+			dbClass.Spec.Operations[database.DeleteMapKey] = database.Operation{
+				Name:   "sp_delete",
+				Inputs: map[string]string{"name": "{{ .InstanceName }}"},
+			}
+			endpoint.output = map[string]string{"username": "user"}
+			newReconciler()
+		})
+		It("should delete the database instance created and stall", func() {
+			_, err := r.Reconcile(ctx, req)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(endpoint.creates).To(Equal(1))
+			Expect(endpoint.deletes).To(Equal(1))
+			obj := getDb()
+			Expect(meta.FindStatusCondition(obj.Status.Conditions, typeutil.TypeReady).Reason).To(Equal(typeutil.RsnOpOutputInvalid))
+			Expect(meta.IsStatusConditionTrue(obj.Status.Conditions, typeutil.TypeStalled)).To(BeTrue())
+			entry, journalErr := r.Journal.Get(ctx, db)
+			Expect(journalErr).ToNot(HaveOccurred())
+			Expect(entry.Phase).To(Equal(journal.PhaseFailed))
+		})
+		It("should keep the journal entry and retry the deletion if it fails", func() {
+			endpoint.deleteErr = errors.New("connection reset")
+			_, err := r.Reconcile(ctx, req)
+			Expect(err).ToNot(HaveOccurred())
+			obj := getDb()
+			Expect(meta.FindStatusCondition(obj.Status.Conditions, typeutil.TypeReady).Reason).To(Equal(typeutil.RsnDbDeleteFail))
+			Expect(meta.IsStatusConditionTrue(obj.Status.Conditions, typeutil.TypeStalled)).To(BeFalse())
+			entry, journalErr := r.Journal.Get(ctx, db)
+			Expect(journalErr).ToNot(HaveOccurred())
+			Expect(entry.Phase).To(Equal(journal.PhaseExecuted))
+
+			// The journaled output is checked again and the deletion retried, without creating another instance
+			endpoint.deleteErr = nil
+			_, err = r.Reconcile(ctx, req)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(endpoint.creates).To(Equal(1))
+			Expect(endpoint.deletes).To(Equal(2))
+			Expect(meta.FindStatusCondition(getDb().Status.Conditions, typeutil.TypeReady).Reason).To(Equal(typeutil.RsnOpOutputInvalid))
+		})
+	})
+	Context("when the credentials returned by create can't be verified", func() {
+		BeforeEach(func() {
+			db.Status = databasev1.DatabaseStatus{}
+			dbClass.Spec.Operations[database.DeleteMapKey] = database.Operation{
+				Name:   "sp_delete",
+				Inputs: map[string]string{"name": "{{ .InstanceName }}"},
+			}
+			// Nothing listens on port 1, the connection is refused
+			dbClass.Spec.CredentialVerification = &databaseclassv1.CredentialVerification{
+				Dsn: "postgres://{{ .Result.username }}:{{ .Result.password }}@127.0.0.1:1/db",
+			}
+			newReconciler()
+		})
+		It("should delete the database instance created", func() {
+			_, err := r.Reconcile(ctx, req)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(endpoint.creates).To(Equal(1))
+			Expect(endpoint.deletes).To(Equal(1))
+			obj := getDb()
+			Expect(meta.FindStatusCondition(obj.Status.Conditions, typeutil.TypeReady).Reason).To(Equal(typeutil.RsnCredVerifyFail))
+			entry, journalErr := r.Journal.Get(ctx, db)
+			Expect(journalErr).ToNot(HaveOccurred())
+			Expect(entry.Phase).To(Equal(journal.PhaseFailed))
+		})
+		It("should not create another database instance until the one created is deleted", func() {
+			endpoint.deleteErr = errors.New("connection reset")
+			_, err := r.Reconcile(ctx, req)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(meta.FindStatusCondition(getDb().Status.Conditions, typeutil.TypeReady).Reason).To(Equal(typeutil.RsnDbDeleteFail))
+
+			_, err = r.Reconcile(ctx, req)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(endpoint.creates).To(Equal(1))
+			Expect(endpoint.deletes).To(Equal(2))
+			entry, journalErr := r.Journal.Get(ctx, db)
+			Expect(journalErr).ToNot(HaveOccurred())
+			Expect(entry.Phase).To(Equal(journal.PhaseExecuted))
+		})
+	})
+})
@@ -0,0 +1,289 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/bedag/kubernetes-dbaas/internal/metrics"
+	"github.com/bedag/kubernetes-dbaas/pkg/database"
+	"github.com/bedag/kubernetes-dbaas/pkg/pool"
+	. "github.com/bedag/kubernetes-dbaas/pkg/typeutil"
+	"github.com/go-logr/logr"
+	k8sError "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sort"
+	"strings"
+	"time"
+
+	databasev1 "github.com/bedag/kubernetes-dbaas/apis/database/v1"
+	databaseclassv1 "github.com/bedag/kubernetes-dbaas/apis/databaseclass/v1"
+	databasereportv1 "github.com/bedag/kubernetes-dbaas/apis/databasereport/v1"
+)
+
+// OrphanDetector periodically calls the list operation on each endpoint whose DatabaseClass supports it and compares
+// its result with the Database resources bound to the endpoint. The outcome is written to a DatabaseReport resource
+// named after the endpoint, see reportName, and exposed as metrics. It implements manager.Runnable.
+type OrphanDetector struct {
+	client.Client
+	Log      logr.Logger
+	DbmsList database.DbmsList
+	Pool     pool.Pool
+	// Interval is the interval between two checks of the same endpoint.
+	Interval time.Duration
+	// ClusterID is a stable identifier of the cluster, available to templates as .ClusterID.
+	ClusterID string
+}
+
+// +kubebuilder:rbac:groups=databasereport.dbaas.bedag.ch,resources=databasereports,verbs=get;list;watch;create;update
+// Start checks all the endpoints every Interval until ctx is done.
+func (d *OrphanDetector) Start(ctx context.Context) error {
+	ticker := time.NewTicker(d.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			d.checkEndpoints(ctx)
+		}
+	}
+}
+
+// NeedLeaderElection returns true, since orphaned database instances might be deleted, only one replica of the Operator
+// must perform the checks.
+func (d *OrphanDetector) NeedLeaderElection() bool {
+	return true
+}
+
+// checkEndpoints checks every endpoint whose DatabaseClass defines a list operation. Errors are logged and written to
+// the DatabaseReport of the endpoint.
+func (d *OrphanDetector) checkEndpoints(ctx context.Context) {
+	for _, dbms := range d.DbmsList {
+		dbClass := databaseclassv1.DatabaseClass{}
+		if err := d.Get(ctx, client.ObjectKey{Namespace: "", Name: dbms.DatabaseClassName}, &dbClass); err != nil {
+			d.Log.Error(err, "unable to get databaseclass", DatabaseClass, dbms.DatabaseClassName)
+			continue
+		}
+		if _, exists := dbClass.Spec.Operations[database.ListMapKey]; !exists {
+			continue
+		}
+		for _, endpoint := range dbms.Endpoints {
+			if err := d.checkEndpoint(ctx, endpoint.Name, dbClass); err != nil {
+				d.Log.Error(err, "unable to check endpoint for orphaned databases", EndpointName, endpoint.Name)
+			}
+		}
+	}
+}
+
+// checkEndpoint compares the database instances returned by the list operation of endpointName with the Database
+// resources bound to it and writes the result to the DatabaseReport of the endpoint.
+func (d *OrphanDetector) checkEndpoint(ctx context.Context, endpointName string, dbClass databaseclassv1.DatabaseClass) error {
+	d.Log.V(TraceLevel).Info("Checking endpoint for orphaned databases", EndpointName, endpointName)
+	report := &databasereportv1.DatabaseReport{}
+	isReportPresent := true
+	if err := d.Get(ctx, client.ObjectKey{Namespace: "", Name: reportName(endpointName)}, report); err != nil {
+		if !k8sError.IsNotFound(err) {
+			return err
+		}
+		isReportPresent = false
+		report.Name = reportName(endpointName)
+	}
+	previousOrphans := report.Status.OrphanedDatabases
+	report.Status = databasereportv1.DatabaseReportStatus{
+		Endpoint:      endpointName,
+		LastCheckTime: metav1.Now(),
+	}
+
+	if err := d.compare(ctx, endpointName, dbClass, &report.Status); err != nil {
+		report.Status.Error = err.Error()
+	} else if dbClass.Spec.OrphanPolicy == databaseclassv1.OrphanPolicyDelete {
+		// Only delete instances which were orphaned during the previous check as well, so that instances whose Database
+		// resource is being created or deleted are never touched
+		report.Status.DeletedDatabases, report.Status.Error = d.deleteOrphans(ctx, endpointName, dbClass,
+			intersect(report.Status.OrphanedDatabases, previousOrphans))
+	}
+	metrics.OrphanedDatabases.WithLabelValues(endpointName).Set(float64(len(report.Status.OrphanedDatabases)))
+	metrics.MissingDatabases.WithLabelValues(endpointName).Set(float64(len(report.Status.MissingDatabases)))
+
+	if isReportPresent {
+		return d.Update(ctx, report)
+	}
+	return d.Create(ctx, report)
+}
+
+// compare calls the list operation on endpointName and sets the orphaned and missing databases of status. If the list
+// operation fails, the error is returned and status is left unchanged.
+func (d *OrphanDetector) compare(ctx context.Context, endpointName string, dbClass databaseclassv1.DatabaseClass, status *databasereportv1.DatabaseReportStatus) error {
+	conn := d.Pool.Get(endpointName)
+	if conn == nil {
+		return fmt.Errorf("%s: '%s'", MsgDbmsEndpointNotFound, endpointName)
+	}
+	listOp, err := dbClass.Spec.Operations[database.ListMapKey].RenderOperation(ctx, d.opValues(endpointName, conn, dbClass))
+	if err != nil {
+		return fmt.Errorf("%s: %w", MsgOpRenderFail, err)
+	}
+	output := conn.List(ctx, listOp)
+	if output.Err != nil {
+		return output.Err
+	}
+	// List Database resources after the list operation, Database resources created in the meantime are reported as
+	// missing until the next check instead of having their database instance reported as orphaned
+	dbList := databasev1.DatabaseList{}
+	if err := d.List(ctx, &dbList); err != nil {
+		return err
+	}
+	status.OrphanedDatabases, status.MissingDatabases = findOrphans(output.Instances(), dbList.Items, endpointName,
+		NewInstanceNamer(d.Pool, d.ClusterID), database.ClusterTag(d.ClusterID))
+	return nil
+}
+
+// deleteOrphans calls the delete operation of dbClass on each of the given orphaned database instances. The operation
+// is rendered with the name of the instance as .Metadata.name and .InstanceName, values which depend on a Database resource, e.g.
+// .Namespace, are empty. Only the instances whose name carries the cluster tag of d.ClusterID, see database.ClusterTag,
+// are deleted: the other ones might belong to another cluster or might have been created outside of the Operator.
+// It returns the deleted instances and a message containing the errors generated, if any.
+func (d *OrphanDetector) deleteOrphans(ctx context.Context, endpointName string, dbClass databaseclassv1.DatabaseClass, orphans []string) ([]string, string) {
+	deleteOpTemplate, exists := dbClass.Spec.Operations[database.DeleteMapKey]
+	if !exists || len(orphans) == 0 {
+		return nil, ""
+	}
+	conn := d.Pool.Get(endpointName)
+	if conn == nil {
+		return nil, fmt.Sprintf("%s: '%s'", MsgDbmsEndpointNotFound, endpointName)
+	}
+	clusterTag := database.ClusterTag(d.ClusterID)
+	var deleted []string
+	var errs []string
+	for _, orphan := range orphans {
+		if tag, ok := database.ParseClusterTag(orphan); !ok || tag != clusterTag {
+			d.Log.V(TraceLevel).Info("Orphaned database not named by this cluster, skipping deletion", EndpointName,
+				endpointName, "database", orphan)
+			continue
+		}
+		values := d.opValues(endpointName, conn, dbClass)
+		values.Metadata = map[string]interface{}{"name": orphan}
+		values.InstanceName = orphan
+		deleteOp, err := deleteOpTemplate.RenderOperation(ctx, values)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s '%s': %s", MsgOpRenderFail, orphan, err))
+			continue
+		}
+		if output := conn.DeleteDb(ctx, deleteOp); output.Err != nil {
+			errs = append(errs, fmt.Sprintf("%s '%s': %s", MsgDbDeleteFail, orphan, output.Err))
+			continue
+		}
+		d.Log.Info("Orphaned database deleted", EndpointName, endpointName, "database", orphan)
+		deleted = append(deleted, orphan)
+	}
+	return deleted, strings.Join(errs, "; ")
+}
+
+// opValues returns the values rendering the operations of dbClass on endpointName, whose connection is conn. Values
+// which depend on a Database resource, e.g. .Namespace, are empty.
+func (d *OrphanDetector) opValues(endpointName string, conn pool.Entry, dbClass databaseclassv1.DatabaseClass) database.OpValues {
+	endpoint := database.EndpointValues{Name: endpointName}
+	if entry, ok := conn.(*pool.DbmsEntry); ok {
+		endpoint.Host, endpoint.Port = entry.Dsn().HostPort()
+	}
+	return database.OpValues{
+		Version:       database.TemplateContextVersion,
+		Endpoint:      endpoint,
+		DatabaseClass: dbClass.Name,
+		ClusterID:     d.ClusterID,
+	}
+}
+
+// reportName returns the name of the DatabaseReport of endpointName: endpointName itself if it is a valid name for a
+// resource, otherwise endpointName lowercased with every invalid character replaced by a dash and followed by a hash of
+// endpointName, so that endpoints whose names only differ by invalid characters still get different reports.
+func reportName(endpointName string) string {
+	if len(validation.IsDNS1123Subdomain(endpointName)) == 0 {
+		return endpointName
+	}
+	sum := sha256.Sum256([]byte(endpointName))
+	hash := hex.EncodeToString(sum[:])[:8]
+	name := strings.Trim(strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '-', r == '.':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r - 'A' + 'a'
+		default:
+			return '-'
+		}
+	}, endpointName), "-.")
+	if maxLength := validation.DNS1123SubdomainMaxLength - len(hash) - 1; len(name) > maxLength {
+		name = strings.TrimRight(name[:maxLength], "-.")
+	}
+	if name == "" {
+		return hash
+	}
+	return name + "-" + hash
+}
+
+// findOrphans returns the instances which are not bound to any Database resource of dbs and the Database resources of
+// dbs bound to endpointName whose instance is missing, formatted as namespace/name. Database resources being deleted
+// are ignored. Instances are matched by the instance name returned by namer, or by the name of the Database resource for
+// DatabaseClasses which name instances after it. Instances whose name carries a cluster tag other than clusterTag, see
+// database.ClusterTag, belong to another cluster sharing the endpoint and are never reported as orphaned.
+func findOrphans(instances []string, dbs []databasev1.Database, endpointName string, namer databasev1.InstanceNamer, clusterTag string) ([]string, []string) {
+	isInstancePresent := make(map[string]bool, len(instances))
+	for _, instance := range instances {
+		isInstancePresent[instance] = true
+	}
+	isBound := make(map[string]bool, len(dbs))
+	var missing []string
+	for i := range dbs {
+		db := &dbs[i]
+		if db.Spec.Endpoint != endpointName {
+			continue
+		}
+		instanceName := namer(db)
+		isBound[db.Name] = true
+		isBound[instanceName] = true
+		if db.GetDeletionTimestamp() == nil && !isInstancePresent[db.Name] && !isInstancePresent[instanceName] {
+			missing = append(missing, db.Namespace+"/"+db.Name)
+		}
+	}
+	var orphaned []string
+	for _, instance := range instances {
+		if tag, ok := database.ParseClusterTag(instance); ok && tag != clusterTag {
+			continue
+		}
+		if !isBound[instance] {
+			orphaned = append(orphaned, instance)
+		}
+	}
+	sort.Strings(missing)
+	return orphaned, missing
+}
+
+// intersect returns the elements of a which are contained in b.
+func intersect(a, b []string) []string {
+	var result []string
+	for _, s := range a {
+		if contains(b, s) {
+			result = append(result, s)
+		}
+	}
+	return result
+}
@@ -0,0 +1,232 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	databasev1 "github.com/bedag/kubernetes-dbaas/apis/database/v1"
+	databaseclassv1 "github.com/bedag/kubernetes-dbaas/apis/databaseclass/v1"
+	databasereportv1 "github.com/bedag/kubernetes-dbaas/apis/databasereport/v1"
+	"github.com/bedag/kubernetes-dbaas/internal/metrics"
+	"github.com/bedag/kubernetes-dbaas/pkg/database"
+	"github.com/bedag/kubernetes-dbaas/pkg/pool"
+	. "github.com/bedag/kubernetes-dbaas/pkg/test"
+	"github.com/bedag/kubernetes-dbaas/pkg/typeutil"
+	"github.com/go-logr/logr"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// fakeListEndpoint is a database.Driver whose list operation returns instances, or err if set. Its delete operation
+// removes the instance passed as the "name" input.
+type fakeListEndpoint struct {
+	database.Driver
+	instances []string
+	err       error
+	listed    []database.Operation
+	deleted   []string
+}
+
+func (e *fakeListEndpoint) List(_ context.Context, operation database.Operation) database.OpOutput {
+	e.listed = append(e.listed, operation)
+	if e.err != nil {
+		return database.OpOutput{Err: e.err}
+	}
+	result := make(map[string]string, len(e.instances))
+	for _, instance := range e.instances {
+		result[instance] = "localhost"
+	}
+	return database.OpOutput{Result: result}
+}
+
+func (e *fakeListEndpoint) DeleteDb(_ context.Context, operation database.Operation) database.OpOutput {
+	e.deleted = append(e.deleted, operation.Inputs["name"])
+	var remaining []string
+	for _, instance := range e.instances {
+		if instance != operation.Inputs["name"] {
+			remaining = append(remaining, instance)
+		}
+	}
+	e.instances = remaining
+	return database.OpOutput{}
+}
+
+// fakeEndpointPool is a pool.Pool containing a single endpoint.
+type fakeEndpointPool struct {
+	pool.Pool
+	name     string
+	endpoint pool.Entry
+}
+
+func (p fakeEndpointPool) Get(name string) pool.Entry {
+	if name != p.name {
+		return nil
+	}
+	return p.endpoint
+}
+
+// newBoundDatabase returns a Database resource bound to endpointName whose database instance is named instanceName.
+func newBoundDatabase(namespace, name, endpointName, instanceName string) databasev1.Database {
+	return databasev1.Database{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+		Spec:       databasev1.DatabaseSpec{Endpoint: endpointName},
+		Status:     databasev1.DatabaseStatus{InstanceName: instanceName},
+	}
+}
+
+var _ = Describe(FormatTestDesc(Unit, "findOrphans"), func() {
+	namer := func(obj *databasev1.Database) string { return obj.Status.InstanceName }
+	It("should classify the instances by the Database resources bound to the endpoint", func() {
+		dbs := []databasev1.Database{
+			newBoundDatabase("team-a", "orders", "ep", "team_a_orders_1234abcd"),
+			newBoundDatabase("team-a", "legacy", "ep", ""),
+			newBoundDatabase("team-b", "missing", "ep", "team_b_missing_1234abcd"),
+			newBoundDatabase("team-b", "other", "other-ep", "team_b_other_1234abcd"),
+		}
+		foreign := database.InstanceName("", "other-cluster", "team-a", "orders")
+		orphaned, missing := findOrphans([]string{"team_a_orders_1234abcd", "legacy", "team_b_other_1234abcd", "unknown",
+			foreign}, dbs, "ep", namer, database.ClusterTag("cluster"))
+		// Instances are owned by their instance name or, for instances named after their resource, by its name. The
+		// instances of other clusters are never orphaned
+		Expect(orphaned).To(Equal([]string{"team_b_other_1234abcd", "unknown"}))
+		Expect(missing).To(Equal([]string{"team-b/missing"}))
+	})
+	It("should not report the instances of Database resources being deleted as missing", func() {
+		db := newBoundDatabase("team-a", "orders", "ep", "team_a_orders_1234abcd")
+		now := metav1.Now()
+		db.DeletionTimestamp = &now
+		orphaned, missing := findOrphans(nil, []databasev1.Database{db}, "ep", namer, database.ClusterTag("cluster"))
+		Expect(orphaned).To(BeEmpty())
+		Expect(missing).To(BeEmpty())
+	})
+})
+
+var _ = Describe(FormatTestDesc(Unit, "instanceName"), func() {
+	p := fakeEndpointPool{name: "ep"}
+	It("should prefer the instance name recorded in the status", func() {
+		db := newBoundDatabase("team-a", "orders", "ep", "team_a_orders_1234abcd")
+		Expect(instanceName(p, "cluster", &db)).To(Equal("team_a_orders_1234abcd"))
+	})
+	It("should compute the instance name of Database resources not created yet", func() {
+		db := newBoundDatabase("team-a", "orders", "ep", "")
+		Expect(instanceName(p, "cluster", &db)).To(Equal(database.InstanceName("", "cluster", "team-a", "orders")))
+	})
+	It("should name the instances of Database resources created by previous versions after the resource", func() {
+		db := newBoundDatabase("team-a", "orders", "ep", "")
+		db.Finalizers = []string{databaseFinalizer}
+		Expect(instanceName(p, "cluster", &db)).To(Equal("orders"))
+	})
+})
+
+var _ = Describe(FormatTestDesc(Unit, "OrphanDetector"), func() {
+	var (
+		endpoint *fakeListEndpoint
+		detector *OrphanDetector
+		dbClass  databaseclassv1.DatabaseClass
+		ctx      context.Context
+	)
+	getReport := func() databasereportv1.DatabaseReport {
+		report := databasereportv1.DatabaseReport{}
+		Expect(detector.Get(ctx, client.ObjectKey{Name: "ep"}, &report)).To(Succeed())
+		return report
+	}
+	BeforeEach(func() {
+		ctx = context.Background()
+		scheme := runtime.NewScheme()
+		Expect(databasev1.AddToScheme(scheme)).To(Succeed())
+		Expect(databasereportv1.AddToScheme(scheme)).To(Succeed())
+		orders := newBoundDatabase("team-a", "orders", "ep", "team_a_orders_1234abcd")
+		missing := newBoundDatabase("team-b", "missing", "ep", "team_b_missing_1234abcd")
+		endpoint = &fakeListEndpoint{instances: []string{"team_a_orders_1234abcd", "orphan"}}
+		detector = &OrphanDetector{
+			Client:    fake.NewClientBuilder().WithScheme(scheme).WithObjects(&orders, &missing).Build(),
+			Log:       logr.Discard(),
+			Pool:      fakeEndpointPool{name: "ep", endpoint: endpoint},
+			ClusterID: "cluster",
+		}
+		dbClass = databaseclassv1.DatabaseClass{
+			ObjectMeta: metav1.ObjectMeta{Name: "dbc"},
+			Spec: databaseclassv1.DatabaseClassSpec{
+				Operations: map[string]database.Operation{
+					database.ListMapKey:   {Name: "sp_list", Inputs: map[string]string{"cluster": "{{ .ClusterID }}"}},
+					database.DeleteMapKey: {Name: "sp_delete", Inputs: map[string]string{"name": "{{ .InstanceName }}"}},
+				},
+				OrphanPolicy: databaseclassv1.OrphanPolicyReport,
+			},
+		}
+	})
+	It("should write the orphaned and missing databases to the report of the endpoint and to the metrics", func() {
+		Expect(detector.checkEndpoint(ctx, "ep", dbClass)).To(Succeed())
+		report := getReport()
+		Expect(report.Status.Endpoint).To(Equal("ep"))
+		Expect(report.Status.OrphanedDatabases).To(Equal([]string{"orphan"}))
+		Expect(report.Status.MissingDatabases).To(Equal([]string{"team-b/missing"}))
+		Expect(report.Status.Error).To(BeEmpty())
+		Expect(endpoint.listed[0].Inputs).To(Equal(map[string]string{"cluster": "cluster"}))
+		Expect(testutil.ToFloat64(metrics.OrphanedDatabases.WithLabelValues("ep"))).To(Equal(float64(1)))
+		Expect(testutil.ToFloat64(metrics.MissingDatabases.WithLabelValues("ep"))).To(Equal(float64(1)))
+
+		// The existing report is updated by the next check
+		endpoint.instances = append(endpoint.instances, "team_b_missing_1234abcd")
+		Expect(detector.checkEndpoint(ctx, "ep", dbClass)).To(Succeed())
+		report = getReport()
+		Expect(report.Status.MissingDatabases).To(BeEmpty())
+		Expect(testutil.ToFloat64(metrics.MissingDatabases.WithLabelValues("ep"))).To(Equal(float64(0)))
+		Expect(endpoint.deleted).To(BeEmpty())
+	})
+	It("should only delete instances orphaned during two consecutive checks if the policy is Delete", func() {
+		dbClass.Spec.OrphanPolicy = databaseclassv1.OrphanPolicyDelete
+		orphan := database.InstanceName("", "cluster", "team-c", "removed")
+		endpoint.instances = append(endpoint.instances, orphan)
+		Expect(detector.checkEndpoint(ctx, "ep", dbClass)).To(Succeed())
+		Expect(endpoint.deleted).To(BeEmpty())
+
+		Expect(detector.checkEndpoint(ctx, "ep", dbClass)).To(Succeed())
+		Expect(endpoint.deleted).To(Equal([]string{orphan}))
+		Expect(getReport().Status.DeletedDatabases).To(Equal([]string{orphan}))
+	})
+	It("should never delete instances which weren't named by this cluster", func() {
+		dbClass.Spec.OrphanPolicy = databaseclassv1.OrphanPolicyDelete
+		foreign := database.InstanceName("", "other-cluster", "team-c", "removed")
+		endpoint.instances = append(endpoint.instances, foreign)
+		for i := 0; i < 3; i++ {
+			Expect(detector.checkEndpoint(ctx, "ep", dbClass)).To(Succeed())
+		}
+		Expect(endpoint.instances).To(ContainElement(foreign))
+		Expect(endpoint.deleted).To(BeEmpty())
+		// Instances of other clusters aren't reported, the ones named otherwise are reported but kept
+		Expect(getReport().Status.OrphanedDatabases).To(Equal([]string{"orphan"}))
+	})
+	It("should not compare nor delete anything if the list operation fails", func() {
+		dbClass.Spec.OrphanPolicy = databaseclassv1.OrphanPolicyDelete
+		endpoint.instances = append(endpoint.instances, database.InstanceName("", "cluster", "team-c", "removed"))
+		Expect(detector.checkEndpoint(ctx, "ep", dbClass)).To(Succeed())
+		endpoint.err = errors.New("connection reset")
+		Expect(detector.checkEndpoint(ctx, "ep", dbClass)).To(Succeed())
+		Expect(endpoint.deleted).To(BeEmpty())
+		Expect(getReport().Status.OrphanedDatabases).To(BeEmpty())
+		Expect(getReport().Status.Error).To(ContainSubstring("connection reset"))
+	})
+	It("should name the report after the endpoint if it is a valid name", func() {
+		Expect(detector.checkEndpoint(ctx, "ep", dbClass)).To(Succeed())
+		Expect(getReport().Status.Endpoint).To(Equal("ep"))
+		Expect(reportName("ep")).To(Equal("ep"))
+		Expect(reportName("DB_Primary")).To(MatchRegexp(`^db-primary-[0-9a-f]{8}$`))
+		Expect(reportName("db_primary")).ToNot(Equal(reportName("db-primary")))
+		Expect(reportName("_")).To(MatchRegexp(`^[0-9a-f]{8}$`))
+	})
+	It("should write errors to the report", func() {
+		endpoint.err = errors.New("stored procedure not found")
+		Expect(detector.checkEndpoint(ctx, "ep", dbClass)).To(Succeed())
+		Expect(getReport().Status.Error).To(ContainSubstring("stored procedure not found"))
+
+		Expect(detector.checkEndpoint(ctx, "unknown-ep", dbClass)).To(Succeed())
+		report := databasereportv1.DatabaseReport{}
+		Expect(detector.Get(ctx, client.ObjectKey{Name: "unknown-ep"}, &report)).To(Succeed())
+		Expect(report.Status.Error).To(ContainSubstring(typeutil.MsgDbmsEndpointNotFound))
+	})
+})
@@ -0,0 +1,275 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	corev1 "k8s.io/api/core/v1"
+	k8sError "k8s.io/apimachinery/pkg/api/errors"
+	"reflect"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sort"
+	"strings"
+
+	databasev1 "github.com/bedag/kubernetes-dbaas/apis/database/v1"
+	"github.com/bedag/kubernetes-dbaas/internal/tracing"
+)
+
+// maskedValue replaces the values of parameters read from Secrets in events and logs.
+const maskedValue = "******"
+
+// resolveParamsFrom returns the values of the ParamsFrom of obj, read with c from the namespace of obj. The values read
+// from Secrets are added to the masker of ctx, see withMasker. References marked as optional resolve to an empty
+// string if their Secret, ConfigMap or key is missing.
+func resolveParamsFrom(ctx context.Context, c client.Client, obj *databasev1.Database) (map[string]string, error) {
+	params := make(map[string]string, len(obj.Spec.ParamsFrom))
+	for param, source := range obj.Spec.ParamsFrom {
+		var value string
+		var found bool
+		var err error
+		var optional *bool
+		switch {
+		case source.SecretKeyRef != nil && source.ConfigMapKeyRef == nil:
+			optional = source.SecretKeyRef.Optional
+			secret := corev1.Secret{}
+			if err = c.Get(ctx, client.ObjectKey{Namespace: obj.Namespace, Name: source.SecretKeyRef.Name}, &secret); err == nil {
+				var data []byte
+				data, found = secret.Data[source.SecretKeyRef.Key]
+				value = string(data)
+				maskerFrom(ctx).add(value)
+			}
+		case source.ConfigMapKeyRef != nil && source.SecretKeyRef == nil:
+			optional = source.ConfigMapKeyRef.Optional
+			configMap := corev1.ConfigMap{}
+			if err = c.Get(ctx, client.ObjectKey{Namespace: obj.Namespace, Name: source.ConfigMapKeyRef.Name}, &configMap); err == nil {
+				value, found = configMap.Data[source.ConfigMapKeyRef.Key]
+			}
+		default:
+			return nil, fmt.Errorf("exactly one of secretKeyRef and configMapKeyRef must be set for parameter '%s'", param)
+		}
+		isOptional := optional != nil && *optional
+		if err != nil && !(k8sError.IsNotFound(err) && isOptional) {
+			return nil, fmt.Errorf("unable to resolve parameter '%s': %w", param, err)
+		}
+		if err == nil && !found && !isOptional {
+			return nil, fmt.Errorf("unable to resolve parameter '%s': key not found", param)
+		}
+		params[param] = value
+	}
+	return params, nil
+}
+
+// paramsFromHash returns the hash of the values of params whose key is in the ParamsFrom of obj, or an empty string if
+// obj has no ParamsFrom, so that resources without references are never considered changed.
+func paramsFromHash(obj *databasev1.Database, params map[string]string) string {
+	if len(obj.Spec.ParamsFrom) == 0 {
+		return ""
+	}
+	values := make(map[string]string, len(obj.Spec.ParamsFrom))
+	for param := range obj.Spec.ParamsFrom {
+		values[param] = params[param]
+	}
+	// json.Marshal sorts map keys
+	data, _ := json.Marshal(values)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// isReferencedBy returns true if obj references the Secret or ConfigMap ref in its ParamsFrom.
+func isReferencedBy(ref client.Object, obj *databasev1.Database) bool {
+	if ref.GetNamespace() != obj.Namespace {
+		return false
+	}
+	for _, source := range obj.Spec.ParamsFrom {
+		switch ref.(type) {
+		case *corev1.Secret:
+			if source.SecretKeyRef != nil && source.SecretKeyRef.Name == ref.GetName() {
+				return true
+			}
+		case *corev1.ConfigMap:
+			if source.ConfigMapKeyRef != nil && source.ConfigMapKeyRef.Name == ref.GetName() {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// paramsFromIndexKey is the key of the field index of Database resources by the Secrets and ConfigMaps referenced in
+// their ParamsFrom, see paramsFromReferences.
+const paramsFromIndexKey = ".spec.paramsFrom"
+
+// paramsFromReferences returns the index values of the Secrets and ConfigMaps referenced in the ParamsFrom of obj, see
+// paramsFromReference. It is the client.IndexerFunc of paramsFromIndexKey.
+func paramsFromReferences(obj client.Object) []string {
+	db, ok := obj.(*databasev1.Database)
+	if !ok {
+		return nil
+	}
+	var references []string
+	for _, source := range db.Spec.ParamsFrom {
+		if source.SecretKeyRef != nil {
+			references = append(references, paramsFromReference(&corev1.Secret{}, source.SecretKeyRef.Name))
+		}
+		if source.ConfigMapKeyRef != nil {
+			references = append(references, paramsFromReference(&corev1.ConfigMap{}, source.ConfigMapKeyRef.Name))
+		}
+	}
+	return references
+}
+
+// paramsFromReference returns the index value of the Secret or ConfigMap ref named name, see paramsFromIndexKey.
+func paramsFromReference(ref client.Object, name string) string {
+	switch ref.(type) {
+	case *corev1.Secret:
+		return "Secret/" + name
+	case *corev1.ConfigMap:
+		return "ConfigMap/" + name
+	}
+	return ""
+}
+
+// referencingDatabases returns a handler.MapFunc enqueueing the Database resources referencing a Secret or ConfigMap
+// in their ParamsFrom, so that a change of the referenced value is detected by shouldRotate. Database resources are
+// looked up through the field index paramsFromIndexKey rather than by listing the whole namespace.
+func (r *DatabaseReconciler) referencingDatabases(ref client.Object) []reconcile.Request {
+	list := databasev1.DatabaseList{}
+	if err := r.List(context.Background(), &list, client.InNamespace(ref.GetNamespace()),
+		client.MatchingFields{paramsFromIndexKey: paramsFromReference(ref, ref.GetName())}); err != nil {
+		r.Log.Error(err, "could not list database resources referencing object", "namespace", ref.GetNamespace(),
+			"name", ref.GetName())
+		return nil
+	}
+	var requests []reconcile.Request
+	for i := range list.Items {
+		if isReferencedBy(ref, &list.Items[i]) {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&list.Items[i])})
+		}
+	}
+	return requests
+}
+
+// paramsSourceChanged returns a predicate.Predicate filtering out the updates of Secrets and ConfigMaps which don't
+// change their data, e.g. the frequent metadata updates of Secrets and ConfigMaps managed by other controllers.
+func paramsSourceChanged() predicate.Predicate {
+	return predicate.Funcs{
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			switch oldObj := e.ObjectOld.(type) {
+			case *corev1.Secret:
+				newObj, ok := e.ObjectNew.(*corev1.Secret)
+				return !ok || !reflect.DeepEqual(oldObj.Data, newObj.Data)
+			case *corev1.ConfigMap:
+				newObj, ok := e.ObjectNew.(*corev1.ConfigMap)
+				return !ok || !reflect.DeepEqual(oldObj.Data, newObj.Data)
+			}
+			return true
+		},
+	}
+}
+
+// masker replaces sensitive values in strings and errors. A nil masker doesn't replace anything. It is not safe for
+// concurrent use, each reconciliation uses its own masker.
+type masker struct {
+	values []string
+}
+
+// maskerKey is the key of the masker in a context.Context.
+type maskerKey struct{}
+
+// withMasker returns a copy of ctx carrying a new masker, see maskerFrom. The masker is also registered as the error
+// masker of ctx, see tracing.WithErrorMasker, so that errors recorded in spans are masked as well.
+func withMasker(ctx context.Context) context.Context {
+	m := &masker{}
+	return tracing.WithErrorMasker(context.WithValue(ctx, maskerKey{}, m), m.maskErr)
+}
+
+// maskerFrom returns the masker of ctx, or nil if ctx doesn't carry any.
+func maskerFrom(ctx context.Context) *masker {
+	m, _ := ctx.Value(maskerKey{}).(*masker)
+	return m
+}
+
+// add adds value to the values replaced by m. Empty values are ignored.
+func (m *masker) add(value string) {
+	if m == nil || value == "" {
+		return
+	}
+	for _, v := range m.values {
+		if v == value {
+			return
+		}
+	}
+	m.values = append(m.values, value)
+	// Replace longer values first, so that values containing others are masked entirely
+	sort.Slice(m.values, func(i, j int) bool { return len(m.values[i]) > len(m.values[j]) })
+}
+
+// mask returns s with the values of m replaced by maskedValue.
+func (m *masker) mask(s string) string {
+	if m == nil {
+		return s
+	}
+	for _, v := range m.values {
+		s = strings.ReplaceAll(s, v, maskedValue)
+	}
+	return s
+}
+
+// maskErr returns err with the values of m replaced in its message. The returned error wraps err, so that it can still
+// be inspected with errors.Is and errors.As.
+func (m *masker) maskErr(err error) error {
+	if err == nil {
+		return nil
+	}
+	if message := m.mask(err.Error()); message != err.Error() {
+		return maskedError{message: message, err: err}
+	}
+	return err
+}
+
+// maskAll returns additionalInfo with the values of m replaced in its strings.
+func (m *masker) maskAll(additionalInfo []interface{}) []interface{} {
+	masked := make([]interface{}, len(additionalInfo))
+	for i, v := range additionalInfo {
+		if s, ok := v.(string); ok {
+			v = m.mask(s)
+		}
+		masked[i] = v
+	}
+	return masked
+}
+
+// maskedError is an error whose message was masked by a masker.
+type maskedError struct {
+	message string
+	err     error
+}
+
+func (e maskedError) Error() string {
+	return e.message
+}
+
+func (e maskedError) Unwrap() error {
+	return e.err
+}
@@ -0,0 +1,173 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	databasev1 "github.com/bedag/kubernetes-dbaas/apis/database/v1"
+	. "github.com/bedag/kubernetes-dbaas/pkg/test"
+	"github.com/go-logr/logr"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+)
+
+var _ = Describe(FormatTestDesc(Unit, "ParamsFrom resolution"), func() {
+	var (
+		c         client.Client
+		db        *databasev1.Database
+		secret    *corev1.Secret
+		configMap *corev1.ConfigMap
+		ctx       context.Context
+	)
+	secretRef := func(name, key string, optional bool) databasev1.ParamSource {
+		return databasev1.ParamSource{SecretKeyRef: &corev1.SecretKeySelector{
+			LocalObjectReference: corev1.LocalObjectReference{Name: name},
+			Key:                  key,
+			Optional:             &optional,
+		}}
+	}
+	configMapRef := func(name, key string, optional bool) databasev1.ParamSource {
+		return databasev1.ParamSource{ConfigMapKeyRef: &corev1.ConfigMapKeySelector{
+			LocalObjectReference: corev1.LocalObjectReference{Name: name},
+			Key:                  key,
+			Optional:             &optional,
+		}}
+	}
+	BeforeEach(func() {
+		ctx = withMasker(context.Background())
+		scheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+		Expect(databasev1.AddToScheme(scheme)).To(Succeed())
+		db = &databasev1.Database{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "orders"},
+			Spec:       databasev1.DatabaseSpec{Endpoint: "ep"},
+		}
+		secret = &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "orders-params"},
+			Data:       map[string][]byte{"owner": []byte("s3cr3t-owner")},
+		}
+		configMap = &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "orders-config"},
+			Data:       map[string]string{"collation": "utf8"},
+		}
+		c = fake.NewClientBuilder().WithScheme(scheme).WithObjects(secret, configMap).Build()
+	})
+	Context("resolveParamsFrom", func() {
+		It("should resolve values of Secrets and ConfigMaps and mask the values of Secrets", func() {
+			db.Spec.ParamsFrom = map[string]databasev1.ParamSource{
+				"owner":     secretRef("orders-params", "owner", false),
+				"collation": configMapRef("orders-config", "collation", false),
+			}
+			params, err := resolveParamsFrom(ctx, c, db)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(params).To(Equal(map[string]string{"owner": "s3cr3t-owner", "collation": "utf8"}))
+			Expect(maskerFrom(ctx).mask("owner is s3cr3t-owner, collation is utf8")).
+				To(Equal("owner is " + maskedValue + ", collation is utf8"))
+		})
+		It("should resolve missing optional objects and keys to empty strings", func() {
+			db.Spec.ParamsFrom = map[string]databasev1.ParamSource{
+				"missingObject": secretRef("missing", "owner", true),
+				"missingKey":    configMapRef("orders-config", "missing", true),
+			}
+			params, err := resolveParamsFrom(ctx, c, db)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(params).To(Equal(map[string]string{"missingObject": "", "missingKey": ""}))
+		})
+		It("should return an error if a required key is missing", func() {
+			db.Spec.ParamsFrom = map[string]databasev1.ParamSource{"owner": secretRef("orders-params", "missing", false)}
+			_, err := resolveParamsFrom(ctx, c, db)
+			Expect(err).To(MatchError("unable to resolve parameter 'owner': key not found"))
+		})
+		It("should return an error if a required object is missing", func() {
+			db.Spec.ParamsFrom = map[string]databasev1.ParamSource{"collation": configMapRef("missing", "collation", false)}
+			_, err := resolveParamsFrom(ctx, c, db)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("unable to resolve parameter 'collation'"))
+		})
+		It("should return an error unless exactly one reference is set", func() {
+			both := secretRef("orders-params", "owner", false)
+			both.ConfigMapKeyRef = configMapRef("orders-config", "collation", false).ConfigMapKeyRef
+			for _, source := range []databasev1.ParamSource{both, {}} {
+				db.Spec.ParamsFrom = map[string]databasev1.ParamSource{"owner": source}
+				_, err := resolveParamsFrom(ctx, c, db)
+				Expect(err).To(MatchError("exactly one of secretKeyRef and configMapKeyRef must be set for parameter 'owner'"))
+			}
+		})
+	})
+	Context("paramsFromHash", func() {
+		BeforeEach(func() {
+			db.Spec.ParamsFrom = map[string]databasev1.ParamSource{"owner": secretRef("orders-params", "owner", false)}
+		})
+		It("should be empty without ParamsFrom", func() {
+			db.Spec.ParamsFrom = nil
+			Expect(paramsFromHash(db, map[string]string{"owner": "alice"})).To(BeEmpty())
+		})
+		It("should only change with the values of ParamsFrom", func() {
+			hash := paramsFromHash(db, map[string]string{"owner": "alice"})
+			Expect(hash).ToNot(BeEmpty())
+			Expect(paramsFromHash(db, map[string]string{"owner": "alice"})).To(Equal(hash))
+			Expect(paramsFromHash(db, map[string]string{"owner": "alice", "size": "10G"})).To(Equal(hash))
+			Expect(paramsFromHash(db, map[string]string{"owner": "bob"})).ToNot(Equal(hash))
+		})
+	})
+	Context("referencingDatabases", func() {
+		It("should only enqueue the Database resources referencing the object in its namespace", func() {
+			db.Spec.ParamsFrom = map[string]databasev1.ParamSource{"owner": secretRef("orders-params", "owner", false)}
+			other := &databasev1.Database{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "invoices"},
+				Spec: databasev1.DatabaseSpec{Endpoint: "ep", ParamsFrom: map[string]databasev1.ParamSource{
+					"collation": configMapRef("orders-params", "collation", false),
+				}},
+			}
+			scheme := runtime.NewScheme()
+			Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+			Expect(databasev1.AddToScheme(scheme)).To(Succeed())
+			r := &DatabaseReconciler{
+				Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(db, other).Build(),
+				Log:    logr.Discard(),
+			}
+			requests := r.referencingDatabases(secret)
+			Expect(requests).To(HaveLen(1))
+			Expect(requests[0].NamespacedName).To(Equal(client.ObjectKeyFromObject(db)))
+			Expect(paramsFromReferences(db)).To(Equal([]string{"Secret/orders-params"}))
+			Expect(paramsFromReferences(other)).To(Equal([]string{"ConfigMap/orders-params"}))
+		})
+	})
+	Context("paramsSourceChanged", func() {
+		It("should filter out updates which don't change the data", func() {
+			updated := secret.DeepCopy()
+			updated.Labels = map[string]string{"app": "orders"}
+			Expect(paramsSourceChanged().Update(event.UpdateEvent{ObjectOld: secret, ObjectNew: updated})).To(BeFalse())
+			updated.Data["owner"] = []byte("new-owner")
+			Expect(paramsSourceChanged().Update(event.UpdateEvent{ObjectOld: secret, ObjectNew: updated})).To(BeTrue())
+			Expect(paramsSourceChanged().Create(event.CreateEvent{Object: configMap})).To(BeTrue())
+		})
+	})
+	Context("masker", func() {
+		It("should mask values in strings, errors and key and values", func() {
+			m := maskerFrom(ctx)
+			m.add("pass")
+			m.add("password123")
+			m.add("")
+			Expect(m.mask("password123 and pass")).To(Equal(maskedValue + " and " + maskedValue))
+			cause := errors.New("login with password123 failed")
+			err := m.maskErr(cause)
+			Expect(err).To(MatchError("login with " + maskedValue + " failed"))
+			Expect(errors.Is(err, cause)).To(BeTrue())
+			Expect(m.maskAll([]interface{}{"value", "pass", "count", 1})).
+				To(Equal([]interface{}{"value", maskedValue, "count", 1}))
+		})
+		It("should not mask anything without a masker", func() {
+			var m *masker
+			m.add("pass")
+			Expect(m.mask("pass")).To(Equal("pass"))
+			Expect(maskerFrom(context.Background())).To(BeNil())
+		})
+	})
+})
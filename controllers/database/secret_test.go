@@ -0,0 +1,73 @@
+package controllers
+
+import (
+	"context"
+	databasev1 "github.com/bedag/kubernetes-dbaas/apis/database/v1"
+	databaseclassv1 "github.com/bedag/kubernetes-dbaas/apis/databaseclass/v1"
+	"github.com/bedag/kubernetes-dbaas/pkg/database"
+	"github.com/bedag/kubernetes-dbaas/pkg/secretsink"
+	. "github.com/bedag/kubernetes-dbaas/pkg/test"
+	"github.com/go-logr/logr"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe(FormatTestDesc(Unit, "DatabaseReconciler.writeSecret"), func() {
+	var (
+		r       *DatabaseReconciler
+		db      *databasev1.Database
+		dbClass databaseclassv1.DatabaseClass
+		output  database.OpOutput
+		ctx     context.Context
+	)
+	writeSecret := func() corev1.Secret {
+		sink := secretsink.Kubernetes{Client: r.Client}
+		Expect(r.writeSecret(ctx, db, dbClass, sink, database.OpValues{}, output, "", "").IsNotEmpty()).To(BeFalse())
+		secret := corev1.Secret{}
+		Expect(r.Get(ctx, client.ObjectKey{Namespace: db.Namespace, Name: FormatSecretName(db)}, &secret)).To(Succeed())
+		return secret
+	}
+	BeforeEach(func() {
+		ctx = context.Background()
+		scheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+		Expect(databasev1.AddToScheme(scheme)).To(Succeed())
+		db = &databasev1.Database{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "orders", UID: "1234"},
+			Spec:       databasev1.DatabaseSpec{Endpoint: "ep"},
+		}
+		r = &DatabaseReconciler{
+			Client:        fake.NewClientBuilder().WithScheme(scheme).WithObjects(db).Build(),
+			Log:           logr.Discard(),
+			EventRecorder: record.NewFakeRecorder(100),
+		}
+		dbClass = databaseclassv1.DatabaseClass{
+			ObjectMeta: metav1.ObjectMeta{Name: "dbc"},
+			Spec: databaseclassv1.DatabaseClassSpec{
+				Driver:       database.Postgres,
+				SecretFormat: database.SecretFormat{"dsn": "{{ .Result.username }}@{{ .Result.fqdn }}"},
+			},
+		}
+		output = database.OpOutput{Result: map[string]string{"username": "user", "password": "p@ss", "fqdn": "db.example.com"}}
+	})
+	It("should only write the keys of the SecretFormat by default", func() {
+		secret := writeSecret()
+		Expect(secret.Data).To(Equal(map[string][]byte{"dsn": []byte("user@db.example.com")}))
+		Expect(db.Status.Binding).To(Equal(&databasev1.ServiceBinding{Name: FormatSecretName(db)}))
+	})
+	It("should add the well-known entries of the Service Binding specification if enabled", func() {
+		dbClass.Spec.ServiceBinding = true
+		secret := writeSecret()
+		Expect(secret.Data).To(HaveKeyWithValue("dsn", []byte("user@db.example.com")))
+		Expect(secret.Data).To(HaveKeyWithValue(database.BindingTypeKey, []byte("postgresql")))
+		Expect(secret.Data).To(HaveKeyWithValue(database.BindingHostKey, []byte("db.example.com")))
+		Expect(secret.Data).To(HaveKeyWithValue(database.BindingPasswordKey, []byte("p@ss")))
+	})
+})
@@ -0,0 +1,76 @@
+package controllers
+
+import (
+	"context"
+	databasev1 "github.com/bedag/kubernetes-dbaas/apis/database/v1"
+	databaseclassv1 "github.com/bedag/kubernetes-dbaas/apis/databaseclass/v1"
+	"github.com/bedag/kubernetes-dbaas/pkg/database"
+	. "github.com/bedag/kubernetes-dbaas/pkg/test"
+	"github.com/bedag/kubernetes-dbaas/pkg/typeutil"
+	"github.com/go-logr/logr"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+var _ = Describe(FormatTestDesc(Unit, "DatabaseReconciler stall"), func() {
+	var (
+		r       *DatabaseReconciler
+		db      *databasev1.Database
+		dbClass *databaseclassv1.DatabaseClass
+		ctx     context.Context
+	)
+	BeforeEach(func() {
+		ctx = context.Background()
+		scheme := runtime.NewScheme()
+		Expect(databasev1.AddToScheme(scheme)).To(Succeed())
+		Expect(databaseclassv1.AddToScheme(scheme)).To(Succeed())
+		db = &databasev1.Database{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "orders", Generation: 2},
+			Spec:       databasev1.DatabaseSpec{Endpoint: "ep"},
+		}
+		other := &databasev1.Database{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "other"},
+			Spec:       databasev1.DatabaseSpec{Endpoint: "other-ep"},
+		}
+		dbClass = &databaseclassv1.DatabaseClass{ObjectMeta: metav1.ObjectMeta{Name: "dbc", Generation: 3}}
+		r = &DatabaseReconciler{
+			Client:        fake.NewClientBuilder().WithScheme(scheme).WithObjects(db, other, dbClass).Build(),
+			Log:           logr.Discard(),
+			EventRecorder: record.NewFakeRecorder(100),
+			DbmsList: database.DbmsList{
+				{DatabaseClassName: "dbc", Endpoints: []database.Endpoint{{Name: "ep"}}},
+				{DatabaseClassName: "other-dbc", Endpoints: []database.Endpoint{{Name: "other-ep"}}},
+			},
+		}
+	})
+	It("should stall resources for the current generation of the resource and of its DatabaseClass", func() {
+		r.handleReconcileError(ctx, db, ReconcileError{Reason: typeutil.RsnOpNotSupported, Message: typeutil.MsgOpNotSupported})
+		Expect(meta.IsStatusConditionTrue(db.Status.Conditions, typeutil.TypeStalled)).To(BeTrue())
+		Expect(db.Status.StalledClassGeneration).To(Equal(int64(3)))
+		Expect(r.isStalled(ctx, db)).To(BeTrue())
+
+		// A change of the DatabaseClass may solve the error
+		Expect(r.Get(ctx, client.ObjectKeyFromObject(dbClass), dbClass)).To(Succeed())
+		dbClass.Generation = 4
+		Expect(r.Update(ctx, dbClass)).To(Succeed())
+		Expect(r.isStalled(ctx, db)).To(BeFalse())
+	})
+	It("should not stall resources whose spec changed", func() {
+		r.handleReconcileError(ctx, db, ReconcileError{Reason: typeutil.RsnOpNotSupported, Message: typeutil.MsgOpNotSupported})
+		db.Generation = 3
+		Expect(r.isStalled(ctx, db)).To(BeFalse())
+	})
+	It("should enqueue the stalled resources of a DatabaseClass", func() {
+		Expect(r.stalledDatabases(dbClass)).To(BeEmpty())
+		r.handleReconcileError(ctx, db, ReconcileError{Reason: typeutil.RsnOpNotSupported, Message: typeutil.MsgOpNotSupported})
+		Expect(r.stalledDatabases(dbClass)).To(ConsistOf(reconcile.Request{NamespacedName: client.ObjectKeyFromObject(db)}))
+		Expect(r.stalledDatabases(&databaseclassv1.DatabaseClass{ObjectMeta: metav1.ObjectMeta{Name: "other-dbc"}})).To(BeEmpty())
+	})
+})
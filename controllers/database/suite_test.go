@@ -139,7 +139,7 @@ var _ = BeforeSuite(func() {
 				err = k8sClient.Get(context.Background(), client.ObjectKey{Name: dbms.DatabaseClassName}, &dbc)
 				Expect(err).ToNot(HaveOccurred())
 
-				err = dbmsPool.RegisterDbms(dbms, dbc.Spec.Driver)
+				err = dbmsPool.RegisterDbms(dbms, dbc.Spec.Driver, nil)
 				Expect(err).ToNot(HaveOccurred())
 			}
 		})
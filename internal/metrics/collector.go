@@ -0,0 +1,82 @@
+package metrics
+
+import (
+	"context"
+	databasev1 "github.com/bedag/kubernetes-dbaas/apis/database/v1"
+	. "github.com/bedag/kubernetes-dbaas/pkg/typeutil"
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"time"
+)
+
+// Phases of a Database resource, see Phase.
+const (
+	PhasePending  = "Pending"
+	PhaseReady    = "Ready"
+	PhaseFailed   = "Failed"
+	PhaseStalled  = "Stalled"
+	PhaseDeleting = "Deleting"
+)
+
+// collectTimeout is the maximum time spent listing Database resources during a scrape.
+const collectTimeout = 10 * time.Second
+
+var databasesDesc = prometheus.NewDesc(
+	prometheus.BuildFQName(namespace, "", "databases"),
+	"Number of Database resources per namespace and phase",
+	[]string{namespaceLabel, phaseLabel}, nil,
+)
+
+// DatabaseCollector is a prometheus.Collector exposing the number of Database resources per namespace and phase. The
+// Database resources are listed at each scrape, therefore reader should be backed by a cache.
+type DatabaseCollector struct {
+	reader client.Reader
+}
+
+// NewDatabaseCollector returns a DatabaseCollector listing Database resources through reader.
+func NewDatabaseCollector(reader client.Reader) *DatabaseCollector {
+	return &DatabaseCollector{reader: reader}
+}
+
+// Describe implements prometheus.Collector.
+func (c *DatabaseCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- databasesDesc
+}
+
+// Collect implements prometheus.Collector.
+func (c *DatabaseCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), collectTimeout)
+	defer cancel()
+	dbList := databasev1.DatabaseList{}
+	if err := c.reader.List(ctx, &dbList); err != nil {
+		ch <- prometheus.NewInvalidMetric(databasesDesc, err)
+		return
+	}
+	type key struct{ namespace, phase string }
+	counts := make(map[key]int)
+	for _, db := range dbList.Items {
+		counts[key{db.Namespace, Phase(db)}]++
+	}
+	for k, count := range counts {
+		ch <- prometheus.MustNewConstMetric(databasesDesc, prometheus.GaugeValue, float64(count), k.namespace, k.phase)
+	}
+}
+
+// Phase summarizes the conditions of db into a phase. Database resources being deleted are in PhaseDeleting, the ones
+// whose reconciliation failed with a terminal error in PhaseStalled. The others are in PhaseReady, PhaseFailed or
+// PhasePending according to their Ready condition.
+func Phase(db databasev1.Database) string {
+	switch {
+	case db.GetDeletionTimestamp() != nil:
+		return PhaseDeleting
+	case meta.IsStatusConditionTrue(db.Status.Conditions, TypeStalled):
+		return PhaseStalled
+	case meta.IsStatusConditionTrue(db.Status.Conditions, TypeReady):
+		return PhaseReady
+	case meta.IsStatusConditionFalse(db.Status.Conditions, TypeReady):
+		return PhaseFailed
+	default:
+		return PhasePending
+	}
+}
@@ -0,0 +1,67 @@
+package metrics
+
+import (
+	"context"
+	"github.com/bedag/kubernetes-dbaas/pkg/database"
+	"time"
+)
+
+// PingOperation is the value of the operation label for pings.
+const PingOperation = "ping"
+
+// InstrumentedDriver is a database.Driver recording the latency and the result of each call to the wrapped Driver in
+// OperationDuration and OperationsTotal.
+type InstrumentedDriver struct {
+	database.Driver
+	endpoint    string
+	dbClassName string
+}
+
+// NewInstrumentedDriver returns an InstrumentedDriver wrapping driver, whose metrics are labeled with endpoint and
+// dbClassName.
+func NewInstrumentedDriver(driver database.Driver, endpoint, dbClassName string) *InstrumentedDriver {
+	return &InstrumentedDriver{
+		Driver:      driver,
+		endpoint:    endpoint,
+		dbClassName: dbClassName,
+	}
+}
+
+func (d *InstrumentedDriver) CreateDb(ctx context.Context, operation database.Operation) database.OpOutput {
+	return d.observe(database.CreateMapKey, func() database.OpOutput { return d.Driver.CreateDb(ctx, operation) })
+}
+
+func (d *InstrumentedDriver) DeleteDb(ctx context.Context, operation database.Operation) database.OpOutput {
+	return d.observe(database.DeleteMapKey, func() database.OpOutput { return d.Driver.DeleteDb(ctx, operation) })
+}
+
+func (d *InstrumentedDriver) Rotate(ctx context.Context, operation database.Operation) database.OpOutput {
+	return d.observe(database.RotateMapKey, func() database.OpOutput { return d.Driver.Rotate(ctx, operation) })
+}
+
+func (d *InstrumentedDriver) Exists(ctx context.Context, operation database.Operation) database.OpOutput {
+	return d.observe(database.ExistsMapKey, func() database.OpOutput { return d.Driver.Exists(ctx, operation) })
+}
+
+func (d *InstrumentedDriver) List(ctx context.Context, operation database.Operation) database.OpOutput {
+	return d.observe(database.ListMapKey, func() database.OpOutput { return d.Driver.List(ctx, operation) })
+}
+
+func (d *InstrumentedDriver) Usage(ctx context.Context, operation database.Operation) database.OpOutput {
+	return d.observe(database.UsageMapKey, func() database.OpOutput { return d.Driver.Usage(ctx, operation) })
+}
+
+func (d *InstrumentedDriver) Ping(ctx context.Context) error {
+	start := time.Now()
+	err := d.Driver.Ping(ctx)
+	ObserveOperation(d.endpoint, d.dbClassName, PingOperation, start, err)
+	return err
+}
+
+// observe calls execute and records its latency and result as operation.
+func (d *InstrumentedDriver) observe(operation string, execute func() database.OpOutput) database.OpOutput {
+	start := time.Now()
+	output := execute()
+	ObserveOperation(d.endpoint, d.dbClassName, operation, start, output.Err)
+	return output
+}
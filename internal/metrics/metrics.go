@@ -0,0 +1,121 @@
+// Package metrics contains the Prometheus metrics exposed by the Operator. Metrics are registered to the registry of
+// controller-runtime, therefore they are served by the metrics endpoint of the manager.
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+	"time"
+)
+
+const (
+	namespace          = "dbaas"
+	endpointLabel      = "endpoint"
+	namespaceLabel     = "namespace"
+	databaseLabel      = "database"
+	databaseClassLabel = "databaseclass"
+	operationLabel     = "operation"
+	resultLabel        = "result"
+	phaseLabel         = "phase"
+
+	// Values of the result label of OperationsTotal
+	ResultSuccess = "success"
+	ResultFailure = "failure"
+)
+
+var (
+	// OrphanedDatabases is the number of database instances not bound to any Database resource, per endpoint.
+	OrphanedDatabases = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "orphaned_databases",
+		Help:      "Number of database instances on the endpoint which are not bound to any Database resource",
+	}, []string{endpointLabel})
+	// MissingDatabases is the number of Database resources whose database instance is missing, per endpoint.
+	MissingDatabases = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "missing_databases",
+		Help:      "Number of Database resources bound to the endpoint whose database instance is missing",
+	}, []string{endpointLabel})
+	// DatabaseSizeBytes is the size of a database instance as returned by the usage operation.
+	DatabaseSizeBytes = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "database_size_bytes",
+		Help:      "Size of the database instance in bytes",
+	}, []string{namespaceLabel, databaseLabel, endpointLabel})
+	// DatabaseTables is the number of tables of a database instance as returned by the usage operation.
+	DatabaseTables = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "database_tables",
+		Help:      "Number of tables of the database instance",
+	}, []string{namespaceLabel, databaseLabel, endpointLabel})
+	// DatabaseActiveConnections is the number of connections to a database instance as returned by the usage operation.
+	DatabaseActiveConnections = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "database_active_connections",
+		Help:      "Number of active connections to the database instance",
+	}, []string{namespaceLabel, databaseLabel, endpointLabel})
+	// OperationDuration is the latency of the operations executed on DBMS endpoints, see InstrumentedDriver.
+	OperationDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "operation_duration_seconds",
+		Help:      "Latency of the operations executed on the endpoint",
+		Buckets:   []float64{0.005, 0.01, 0.05, 0.1, 0.5, 1, 2.5, 5, 10, 30, 60},
+	}, []string{endpointLabel, databaseClassLabel, operationLabel})
+	// OperationsTotal is the number of operations executed on DBMS endpoints by result, see InstrumentedDriver.
+	OperationsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "operations_total",
+		Help:      "Number of operations executed on the endpoint by result",
+	}, []string{endpointLabel, databaseClassLabel, operationLabel, resultLabel})
+	// EndpointUp is 1 if the last keepalive ping of an endpoint succeeded, 0 otherwise.
+	EndpointUp = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "endpoint_up",
+		Help:      "Whether the last keepalive ping of the endpoint succeeded",
+	}, []string{endpointLabel})
+)
+
+func init() {
+	metrics.Registry.MustRegister(OrphanedDatabases, MissingDatabases, DatabaseSizeBytes, DatabaseTables,
+		DatabaseActiveConnections, OperationDuration, OperationsTotal, EndpointUp)
+}
+
+// ObserveOperation records an operation executed on endpoint for dbClassName which started at start and returned err.
+func ObserveOperation(endpoint, dbClassName, operation string, start time.Time, err error) {
+	OperationDuration.WithLabelValues(endpoint, dbClassName, operation).Observe(time.Since(start).Seconds())
+	result := ResultSuccess
+	if err != nil {
+		result = ResultFailure
+	}
+	OperationsTotal.WithLabelValues(endpoint, dbClassName, operation, result).Inc()
+}
+
+// SetEndpointUp sets the EndpointUp gauge of endpoint according to the error returned by its last ping.
+func SetEndpointUp(endpoint string, pingErr error) {
+	if pingErr != nil {
+		EndpointUp.WithLabelValues(endpoint).Set(0)
+		return
+	}
+	EndpointUp.WithLabelValues(endpoint).Set(1)
+}
+
+// SetDatabaseUsage sets the usage gauges of the database instance identified by namespace, name and endpoint. Nil
+// values are skipped.
+func SetDatabaseUsage(namespace, name, endpoint string, sizeBytes, tables, activeConnections *int64) {
+	for gauge, value := range map[*prometheus.GaugeVec]*int64{
+		DatabaseSizeBytes:         sizeBytes,
+		DatabaseTables:            tables,
+		DatabaseActiveConnections: activeConnections,
+	} {
+		if value != nil {
+			gauge.WithLabelValues(namespace, name, endpoint).Set(float64(*value))
+		}
+	}
+}
+
+// DeleteDatabaseUsage removes the usage gauges of the database instance identified by namespace, name and endpoint.
+func DeleteDatabaseUsage(namespace, name, endpoint string) {
+	for _, gauge := range []*prometheus.GaugeVec{DatabaseSizeBytes, DatabaseTables, DatabaseActiveConnections} {
+		gauge.DeleteLabelValues(namespace, name, endpoint)
+	}
+}
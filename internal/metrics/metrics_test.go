@@ -0,0 +1,101 @@
+package metrics_test
+
+import (
+	"context"
+	"errors"
+	databasev1 "github.com/bedag/kubernetes-dbaas/apis/database/v1"
+	"github.com/bedag/kubernetes-dbaas/internal/metrics"
+	"github.com/bedag/kubernetes-dbaas/pkg/database"
+	. "github.com/bedag/kubernetes-dbaas/pkg/test"
+	"github.com/bedag/kubernetes-dbaas/pkg/typeutil"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"strings"
+)
+
+// fakeDriver is a database.Driver whose operations return err.
+type fakeDriver struct {
+	database.Driver
+	err error
+}
+
+func (d fakeDriver) CreateDb(context.Context, database.Operation) database.OpOutput {
+	return database.OpOutput{Err: d.err}
+}
+
+func (d fakeDriver) Ping(context.Context) error {
+	return d.err
+}
+
+var _ = Describe(FormatTestDesc(Unit, "InstrumentedDriver"), func() {
+	It("should count successful and failed operations per endpoint, class and operation", func() {
+		successes := metrics.OperationsTotal.WithLabelValues("ep-ok", "dbc", database.CreateMapKey, metrics.ResultSuccess)
+		failures := metrics.OperationsTotal.WithLabelValues("ep-ko", "dbc", database.CreateMapKey, metrics.ResultFailure)
+		before := testutil.ToFloat64(successes)
+
+		metrics.NewInstrumentedDriver(fakeDriver{}, "ep-ok", "dbc").CreateDb(context.Background(), database.Operation{})
+		Expect(testutil.ToFloat64(successes)).To(Equal(before + 1))
+
+		before = testutil.ToFloat64(failures)
+		output := metrics.NewInstrumentedDriver(fakeDriver{err: errors.New("down")}, "ep-ko", "dbc").CreateDb(context.Background(), database.Operation{})
+		Expect(output.Err).To(HaveOccurred())
+		Expect(testutil.ToFloat64(failures)).To(Equal(before + 1))
+	})
+	It("should record the latency of pings", func() {
+		Expect(metrics.NewInstrumentedDriver(fakeDriver{}, "ep-ping", "dbc").Ping(context.Background())).To(Succeed())
+		Expect(testutil.ToFloat64(metrics.OperationsTotal.WithLabelValues("ep-ping", "dbc", metrics.PingOperation,
+			metrics.ResultSuccess))).To(Equal(float64(1)))
+		histogram := dto.Metric{}
+		observer := metrics.OperationDuration.WithLabelValues("ep-ping", "dbc", metrics.PingOperation)
+		Expect(observer.(prometheus.Metric).Write(&histogram)).To(Succeed())
+		Expect(histogram.GetHistogram().GetSampleCount()).To(Equal(uint64(1)))
+	})
+})
+
+var _ = Describe(FormatTestDesc(Unit, "SetEndpointUp"), func() {
+	It("should set the gauge according to the ping result", func() {
+		metrics.SetEndpointUp("ep", nil)
+		Expect(testutil.ToFloat64(metrics.EndpointUp.WithLabelValues("ep"))).To(Equal(float64(1)))
+		metrics.SetEndpointUp("ep", errors.New("down"))
+		Expect(testutil.ToFloat64(metrics.EndpointUp.WithLabelValues("ep"))).To(Equal(float64(0)))
+	})
+})
+
+var _ = Describe(FormatTestDesc(Unit, "DatabaseCollector"), func() {
+	newDatabase := func(namespace, name string, conditions ...metav1.Condition) *databasev1.Database {
+		return &databasev1.Database{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+			Status:     databasev1.DatabaseStatus{Conditions: conditions},
+		}
+	}
+	ready := metav1.Condition{Type: typeutil.TypeReady, Status: metav1.ConditionTrue}
+	notReady := metav1.Condition{Type: typeutil.TypeReady, Status: metav1.ConditionFalse}
+	stalled := metav1.Condition{Type: typeutil.TypeStalled, Status: metav1.ConditionTrue}
+
+	It("should count Database resources per namespace and phase", func() {
+		scheme := runtime.NewScheme()
+		Expect(databasev1.AddToScheme(scheme)).To(Succeed())
+		c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+			newDatabase("a", "db1", ready),
+			newDatabase("a", "db2", ready),
+			newDatabase("a", "db3", notReady),
+			newDatabase("b", "db1", notReady, stalled),
+			newDatabase("b", "db2"),
+		).Build()
+		expected := `
+# HELP dbaas_databases Number of Database resources per namespace and phase
+# TYPE dbaas_databases gauge
+dbaas_databases{namespace="a",phase="Failed"} 1
+dbaas_databases{namespace="a",phase="Ready"} 2
+dbaas_databases{namespace="b",phase="Pending"} 1
+dbaas_databases{namespace="b",phase="Stalled"} 1
+`
+		Expect(testutil.CollectAndCompare(metrics.NewDatabaseCollector(c), strings.NewReader(expected))).To(Succeed())
+	})
+})
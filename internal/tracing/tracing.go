@@ -0,0 +1,98 @@
+// Package tracing configures the OpenTelemetry tracing of the Operator. Tracing is disabled by default: until Setup is
+// called with tracing enabled, spans created through Tracer are discarded.
+package tracing
+
+import (
+	"context"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// ServiceName is the name of the service reported in the exported spans.
+	ServiceName         = "kubernetes-dbaas"
+	instrumentationName = "github.com/bedag/kubernetes-dbaas"
+
+	// Attributes attached to spans
+	EndpointKey      = attribute.Key("dbaas.endpoint")
+	DatabaseClassKey = attribute.Key("dbaas.databaseclass")
+	OperationKey     = attribute.Key("dbaas.operation")
+	DatabaseKey      = attribute.Key("dbaas.database")
+	NamespaceKey     = attribute.Key("dbaas.namespace")
+)
+
+// Config configures the export of spans.
+type Config struct {
+	// Enabled enables tracing.
+	Enabled bool
+	// Endpoint is the address of the OTLP gRPC receiver spans are exported to, e.g. "otel-collector:4317".
+	Endpoint string
+	// Insecure disables TLS when connecting to Endpoint.
+	Insecure bool
+	// SamplingRatio is the ratio of traces which are sampled, between 0 and 1.
+	SamplingRatio float64
+}
+
+// Tracer returns the tracer used by the Operator to create spans.
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// Setup registers a global tracer provider exporting spans to the OTLP receiver configured in config. It returns a
+// function flushing and shutting down the tracer provider, which must be called before the Operator exits. If tracing
+// is not enabled, Setup does nothing.
+func Setup(ctx context.Context, config Config) (func(context.Context) error, error) {
+	if !config.Enabled {
+		return func(context.Context) error { return nil }, nil
+	}
+	options := []otlptracegrpc.Option{otlptracegrpc.WithEndpoint(config.Endpoint)}
+	if config.Insecure {
+		options = append(options, otlptracegrpc.WithInsecure())
+	}
+	exporter, err := otlptracegrpc.New(ctx, options...)
+	if err != nil {
+		return nil, err
+	}
+	provider := NewTracerProvider(exporter, sdktrace.ParentBased(sdktrace.TraceIDRatioBased(config.SamplingRatio)))
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	return provider.Shutdown, nil
+}
+
+// NewTracerProvider returns a tracer provider exporting the spans sampled by sampler to exporter.
+func NewTracerProvider(exporter sdktrace.SpanExporter, sampler sdktrace.Sampler) *sdktrace.TracerProvider {
+	return sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sampler),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceNameKey.String(ServiceName))),
+	)
+}
+
+// errorMaskerKey is the key of the error masker in a context.Context.
+type errorMaskerKey struct{}
+
+// WithErrorMasker returns a copy of ctx carrying mask, which RecordError applies to the errors it records so that
+// sensitive values don't leak into the exported spans.
+func WithErrorMasker(ctx context.Context, mask func(error) error) context.Context {
+	return context.WithValue(ctx, errorMaskerKey{}, mask)
+}
+
+// RecordError records err in span and sets the status of span to error. If ctx carries an error masker, see
+// WithErrorMasker, err is masked before being recorded. It does nothing if err is nil.
+func RecordError(ctx context.Context, span trace.Span, err error) {
+	if err == nil {
+		return
+	}
+	if mask, ok := ctx.Value(errorMaskerKey{}).(func(error) error); ok {
+		err = mask(err)
+	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
@@ -0,0 +1,64 @@
+package database
+
+// Well-known keys of a Secret projected by the Service Binding specification, see
+// https://github.com/servicebinding/spec#well-known-secret-entries.
+const (
+	BindingTypeKey     = "type"
+	BindingProviderKey = "provider"
+	BindingHostKey     = "host"
+	BindingPortKey     = "port"
+	BindingDatabaseKey = "database"
+	BindingUsernameKey = "username"
+	BindingPasswordKey = "password"
+)
+
+// bindingTypes maps drivers to the binding types expected by workloads, e.g. by Spring Cloud Bindings.
+var bindingTypes = map[string]string{
+	Postgres:  "postgresql",
+	Sqlserver: "sqlserver",
+	Mysql:     "mysql",
+	Mariadb:   "mysql",
+}
+
+// bindingOutputKeys maps well-known keys to the keys of the create and rotate outputs they are taken from, in order of
+// preference.
+var bindingOutputKeys = map[string][]string{
+	BindingHostKey:     {"host", "fqdn", "server"},
+	BindingPortKey:     {"port"},
+	BindingDatabaseKey: {"database", "dbName"},
+	BindingUsernameKey: {"username"},
+	BindingPasswordKey: {"password"},
+}
+
+// WithBinding returns a copy of s completed with the well-known keys of the Service Binding specification. The type and
+// provider are derived from driver, the other keys from the result of output, or from its generated values if the result
+// doesn't contain them, e.g. a password generated by the Operator. Keys already present in s are left untouched, keys
+// whose value can't be derived are omitted.
+func (s SecretFormat) WithBinding(driver string, output OpOutput) SecretFormat {
+	binding := make(SecretFormat, len(s)+len(bindingOutputKeys)+2)
+	if bindingType, ok := bindingTypes[driver]; ok {
+		binding[BindingTypeKey] = bindingType
+		binding[BindingProviderKey] = driver
+	}
+	for key, outputKeys := range bindingOutputKeys {
+		for _, outputKey := range outputKeys {
+			if value := output.Result[outputKey]; value != "" {
+				binding[key] = value
+				break
+			}
+		}
+		if _, ok := binding[key]; ok {
+			continue
+		}
+		for _, outputKey := range outputKeys {
+			if value := output.Generated[outputKey]; value != "" {
+				binding[key] = value
+				break
+			}
+		}
+	}
+	for k, v := range s {
+		binding[k] = v
+	}
+	return binding
+}
@@ -0,0 +1,57 @@
+package database_test
+
+import (
+	"github.com/bedag/kubernetes-dbaas/pkg/database"
+	. "github.com/bedag/kubernetes-dbaas/pkg/test"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe(FormatTestDesc(Unit, "SecretFormat.WithBinding"), func() {
+	output := database.OpOutput{Result: map[string]string{
+		"username": "user",
+		"password": "Password&1",
+		"port":     "5432",
+		"dbName":   "db",
+		"fqdn":     "db.example.com",
+	}}
+
+	It("should add the well-known keys derived from the driver and the output", func() {
+		Expect(database.SecretFormat{"dsn": "value"}.WithBinding(database.Postgres, output)).To(Equal(database.SecretFormat{
+			"dsn":      "value",
+			"type":     "postgresql",
+			"provider": "postgres",
+			"host":     "db.example.com",
+			"port":     "5432",
+			"database": "db",
+			"username": "user",
+			"password": "Password&1",
+		}))
+	})
+	It("should map MariaDB to the mysql type", func() {
+		binding := database.SecretFormat{}.WithBinding(database.Mariadb, output)
+		Expect(binding).To(HaveKeyWithValue("type", "mysql"))
+		Expect(binding).To(HaveKeyWithValue("provider", "mariadb"))
+	})
+	It("should not override the keys of the SecretFormat", func() {
+		binding := database.SecretFormat{"host": "proxy.example.com"}.WithBinding(database.Postgres, output)
+		Expect(binding).To(HaveKeyWithValue("host", "proxy.example.com"))
+	})
+	It("should omit the keys which can't be derived", func() {
+		binding := database.SecretFormat{}.WithBinding("unknown", database.OpOutput{Result: map[string]string{"username": "user"}})
+		Expect(binding).To(Equal(database.SecretFormat{"username": "user"}))
+	})
+	It("should fall back to the generated values", func() {
+		generatedOutput := database.OpOutput{
+			Result:    map[string]string{"username": "user"},
+			Generated: map[string]string{"password": "Generated&1"},
+		}
+		binding := database.SecretFormat{}.WithBinding(database.Postgres, generatedOutput)
+		Expect(binding).To(HaveKeyWithValue("password", "Generated&1"))
+	})
+	It("should not modify the SecretFormat", func() {
+		format := database.SecretFormat{"dsn": "value"}
+		format.WithBinding(database.Postgres, output)
+		Expect(format).To(HaveLen(1))
+	})
+})
@@ -0,0 +1,147 @@
+package database
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// TLS modes, see TLSConfig.
+const (
+	TLSModeDisable    = "disable"
+	TLSModeRequire    = "require"
+	TLSModeVerifyFull = "verify-full"
+)
+
+// SecretReader returns the value referenced by ref.
+type SecretReader func(ref SecretKeyRef) ([]byte, error)
+
+// +kubebuilder:object:generate=true
+// ConnectionConfig configures the connections opened to an endpoint. Unset fields keep the defaults of the driver and
+// the parameters specified in the DSN.
+type ConnectionConfig struct {
+	// MaxOpenConnections is the maximum number of connections opened to the endpoint.
+	MaxOpenConnections int `json:"maxOpenConnections,omitempty"`
+	// MaxIdleConnections is the maximum number of idle connections retained. Ignored by the postgres driver, which
+	// closes idle connections after MaxConnectionIdleTime.
+	MaxIdleConnections int `json:"maxIdleConnections,omitempty"`
+	// MaxConnectionLifetime is the time in seconds after which a connection is closed.
+	MaxConnectionLifetime int `json:"maxConnectionLifetime,omitempty"`
+	// MaxConnectionIdleTime is the time in seconds after which an idle connection is closed.
+	MaxConnectionIdleTime int `json:"maxConnectionIdleTime,omitempty"`
+	// ConnectTimeout is the time in seconds after which an attempt to connect to the endpoint fails.
+	ConnectTimeout int `json:"connectTimeout,omitempty"`
+	// TLS configures the encryption of the connections.
+	TLS TLSConfig `json:"tls,omitempty"`
+}
+
+// +kubebuilder:object:generate=true
+// TLSConfig configures the encryption of the connections to an endpoint. Certificates and keys are PEM encoded and
+// read from Secrets in the namespace of the Operator unless specified otherwise.
+type TLSConfig struct {
+	// Mode is one of TLSModeDisable, TLSModeRequire, which encrypts the connections without verifying the certificate
+	// of the endpoint, and TLSModeVerifyFull, which verifies it against CASecretKeyRef and ServerName. If empty, the
+	// parameters of the DSN apply.
+	// +kubebuilder:validation:Enum=disable;require;verify-full
+	Mode string `json:"mode,omitempty"`
+	// ServerName is the host name expected in the certificate of the endpoint. Defaults to the host of the DSN.
+	ServerName string `json:"serverName,omitempty"`
+	// CASecretKeyRef references the certificates of the authorities the certificate of the endpoint is verified
+	// against. Defaults to the certificates of the system.
+	CASecretKeyRef *SecretKeyRef `json:"caSecretKeyRef,omitempty"`
+	// CertSecretKeyRef references the client certificate presented to the endpoint.
+	CertSecretKeyRef *SecretKeyRef `json:"certSecretKeyRef,omitempty"`
+	// KeySecretKeyRef references the private key of the client certificate.
+	KeySecretKeyRef *SecretKeyRef `json:"keySecretKeyRef,omitempty"`
+}
+
+// ConnectionOptions are the settings applied by a Driver to its connections, see ConnectionConfig. The zero value
+// keeps the defaults of the driver.
+type ConnectionOptions struct {
+	MaxOpenConnections    int
+	MaxIdleConnections    int
+	MaxConnectionLifetime time.Duration
+	MaxConnectionIdleTime time.Duration
+	ConnectTimeout        time.Duration
+	// TLSMode is the mode of TLSConfig.
+	TLSMode string
+	// TLS is the configuration of the encrypted connections. It is nil unless TLSMode is TLSModeRequire or
+	// TLSModeVerifyFull.
+	TLS *tls.Config
+	// CA is the PEM encoded content of the certificates set as TLS.RootCAs, if any.
+	CA []byte
+}
+
+// Options returns the ConnectionOptions specified by c. Certificates and keys are read through readSecret.
+func (c ConnectionConfig) Options(readSecret SecretReader) (ConnectionOptions, error) {
+	options := ConnectionOptions{
+		MaxOpenConnections:    c.MaxOpenConnections,
+		MaxIdleConnections:    c.MaxIdleConnections,
+		MaxConnectionLifetime: time.Duration(c.MaxConnectionLifetime) * time.Second,
+		MaxConnectionIdleTime: time.Duration(c.MaxConnectionIdleTime) * time.Second,
+		ConnectTimeout:        time.Duration(c.ConnectTimeout) * time.Second,
+		TLSMode:               c.TLS.Mode,
+	}
+	switch c.TLS.Mode {
+	case "", TLSModeDisable:
+		return options, nil
+	case TLSModeRequire, TLSModeVerifyFull:
+	default:
+		return ConnectionOptions{}, fmt.Errorf("invalid tls mode '%s': must be one of '%s', '%s' or '%s'",
+			c.TLS.Mode, TLSModeDisable, TLSModeRequire, TLSModeVerifyFull)
+	}
+
+	options.TLS = &tls.Config{
+		ServerName:         c.TLS.ServerName,
+		InsecureSkipVerify: c.TLS.Mode == TLSModeRequire,
+	}
+	if c.TLS.CASecretKeyRef != nil {
+		ca, err := readSecret(*c.TLS.CASecretKeyRef)
+		if err != nil {
+			return ConnectionOptions{}, fmt.Errorf("unable to read ca certificate: %s", err)
+		}
+		rootCAs := x509.NewCertPool()
+		if !rootCAs.AppendCertsFromPEM(ca) {
+			return ConnectionOptions{}, fmt.Errorf("no valid certificate found in ca certificate")
+		}
+		options.TLS.RootCAs = rootCAs
+		options.CA = ca
+	}
+	if c.TLS.CertSecretKeyRef != nil || c.TLS.KeySecretKeyRef != nil {
+		if c.TLS.CertSecretKeyRef == nil || c.TLS.KeySecretKeyRef == nil {
+			return ConnectionOptions{}, fmt.Errorf("client certificate and key must be specified together")
+		}
+		cert, err := readSecret(*c.TLS.CertSecretKeyRef)
+		if err != nil {
+			return ConnectionOptions{}, fmt.Errorf("unable to read client certificate: %s", err)
+		}
+		key, err := readSecret(*c.TLS.KeySecretKeyRef)
+		if err != nil {
+			return ConnectionOptions{}, fmt.Errorf("unable to read client key: %s", err)
+		}
+		keyPair, err := tls.X509KeyPair(cert, key)
+		if err != nil {
+			return ConnectionOptions{}, fmt.Errorf("invalid client certificate: %s", err)
+		}
+		options.TLS.Certificates = []tls.Certificate{keyPair}
+	}
+	return options, nil
+}
+
+// applySqlOptions applies the connection pool settings of options to db.
+func applySqlOptions(db *sql.DB, options ConnectionOptions) {
+	if options.MaxOpenConnections > 0 {
+		db.SetMaxOpenConns(options.MaxOpenConnections)
+	}
+	if options.MaxIdleConnections > 0 {
+		db.SetMaxIdleConns(options.MaxIdleConnections)
+	}
+	if options.MaxConnectionLifetime > 0 {
+		db.SetConnMaxLifetime(options.MaxConnectionLifetime)
+	}
+	if options.MaxConnectionIdleTime > 0 {
+		db.SetConnMaxIdleTime(options.MaxConnectionIdleTime)
+	}
+}
@@ -0,0 +1,131 @@
+package database_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"github.com/bedag/kubernetes-dbaas/pkg/database"
+	. "github.com/bedag/kubernetes-dbaas/pkg/test"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"math/big"
+	"time"
+)
+
+// selfSignedCertificate returns a PEM encoded self-signed certificate and its private key.
+func selfSignedCertificate() ([]byte, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	Expect(err).ToNot(HaveOccurred())
+	template := x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test"},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	Expect(err).ToNot(HaveOccurred())
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	Expect(err).ToNot(HaveOccurred())
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})
+}
+
+var _ = Describe(FormatTestDesc(Unit, "ConnectionConfig.Options"), func() {
+	var config database.ConnectionConfig
+	var secrets map[string][]byte
+	var options database.ConnectionOptions
+	var err error
+	readSecret := func(ref database.SecretKeyRef) ([]byte, error) {
+		value, exists := secrets[ref.Name+"/"+ref.Key]
+		if !exists {
+			return nil, fmt.Errorf("not found")
+		}
+		return value, nil
+	}
+	BeforeEach(func() {
+		cert, key := selfSignedCertificate()
+		secrets = map[string][]byte{"tls/ca.crt": cert, "tls/tls.crt": cert, "tls/tls.key": key}
+		config = database.ConnectionConfig{
+			MaxOpenConnections:    10,
+			MaxConnectionLifetime: 60,
+		}
+	})
+	JustBeforeEach(func() {
+		options, err = config.Options(readSecret)
+	})
+	Context("when no TLS mode is specified", func() {
+		It("should convert the pool settings", func() {
+			Expect(err).ToNot(HaveOccurred())
+			Expect(options.MaxOpenConnections).To(Equal(10))
+			Expect(options.MaxConnectionLifetime).To(Equal(time.Minute))
+			Expect(options.TLS).To(BeNil())
+		})
+	})
+	Context("when the TLS mode is invalid", func() {
+		BeforeEach(func() {
+			config.TLS.Mode = "verify-ca"
+		})
+		It("should return an error", func() {
+			Expect(err).To(HaveOccurred())
+		})
+	})
+	Context("when the TLS mode is require", func() {
+		BeforeEach(func() {
+			config.TLS.Mode = database.TLSModeRequire
+		})
+		It("should not verify the certificate of the endpoint", func() {
+			Expect(err).ToNot(HaveOccurred())
+			Expect(options.TLS).ToNot(BeNil())
+			Expect(options.TLS.InsecureSkipVerify).To(BeTrue())
+		})
+	})
+	Context("when the TLS mode is verify-full", func() {
+		BeforeEach(func() {
+			config.TLS = database.TLSConfig{
+				Mode:             database.TLSModeVerifyFull,
+				ServerName:       "db.example.com",
+				CASecretKeyRef:   &database.SecretKeyRef{Name: "tls", Key: "ca.crt"},
+				CertSecretKeyRef: &database.SecretKeyRef{Name: "tls", Key: "tls.crt"},
+				KeySecretKeyRef:  &database.SecretKeyRef{Name: "tls", Key: "tls.key"},
+			}
+		})
+		It("should verify the certificate of the endpoint and present the client certificate", func() {
+			Expect(err).ToNot(HaveOccurred())
+			Expect(options.TLS.InsecureSkipVerify).To(BeFalse())
+			Expect(options.TLS.ServerName).To(Equal("db.example.com"))
+			Expect(options.TLS.RootCAs).ToNot(BeNil())
+			Expect(options.CA).To(Equal(secrets["tls/ca.crt"]))
+			Expect(options.TLS.Certificates).To(HaveLen(1))
+		})
+		Context("when the client key is missing", func() {
+			BeforeEach(func() {
+				config.TLS.KeySecretKeyRef = nil
+			})
+			It("should return an error", func() {
+				Expect(err).To(HaveOccurred())
+			})
+		})
+		Context("when the ca certificate can't be read", func() {
+			BeforeEach(func() {
+				config.TLS.CASecretKeyRef = &database.SecretKeyRef{Name: "tls", Key: "missing"}
+			})
+			It("should return an error", func() {
+				Expect(err).To(HaveOccurred())
+			})
+		})
+		Context("when the ca certificate is invalid", func() {
+			BeforeEach(func() {
+				secrets["tls/ca.crt"] = []byte("invalid")
+			})
+			It("should return an error", func() {
+				Expect(err).To(HaveOccurred())
+			})
+		})
+	})
+})
@@ -0,0 +1,193 @@
+package database
+
+import (
+	"fmt"
+	"sort"
+	"text/template"
+	"text/template/parse"
+)
+
+// zeroOnMissingKeyOption renders keys missing in maps as the zero value of the map, e.g. an empty string.
+const zeroOnMissingKeyOption = "missingkey=zero"
+
+// valueDependentFuncs are the template functions whose errors depend on values only known at runtime, e.g. b64dec
+// failing on a value returned by the DBMS which isn't base64-encoded. Their errors are ignored by dry runs.
+var valueDependentFuncs = map[string]bool{
+	"urlDsn":   true,
+	"jdbcDsn":  true,
+	"adoDsn":   true,
+	"libpqDsn": true,
+	"b64dec":   true,
+	"b32dec":   true,
+}
+
+// Drivers returns the drivers supported by New.
+func Drivers() []string {
+	return []string{Mariadb, Mysql, Postgres, Sqlserver}
+}
+
+// IsDriverSupported returns true if driver is supported by New.
+func IsDriverSupported(driver string) bool {
+	return containsString(Drivers(), driver)
+}
+
+// DryRun test-renders the inputs of op with values, see DryRunTemplate. The generator functions are available if
+// values carry a Generator, see OpValues.WithGenerator, so that passwords violating its policy are reported.
+func (op Operation) DryRun(values OpValues) error {
+	return dryRunMap(op.Inputs, values, values.generator)
+}
+
+// DryRun test-renders the receiver with values, see DryRunTemplate.
+func (s SecretFormat) DryRun(values SecretValues) error {
+	return dryRunMap(s, values, nil)
+}
+
+// DryRunTemplate parses text and test-renders it with values, usually synthetic, in order to report errors before the
+// template is used, e.g. syntax errors, unknown functions or fields. Unlike RenderGoTemplate, keys missing in maps are
+// rendered as zero values instead of generating an error, since maps such as .Parameters are only known at runtime.
+// For the same reason, errors of the functions depending on the values, e.g. b64dec, are ignored.
+func DryRunTemplate(text string, values interface{}) error {
+	_, err := renderGoTemplate(text, values, dryRunFuncs(nil), zeroOnMissingKeyOption)
+	return err
+}
+
+// ResultKeys parses text and returns the sorted keys of the operation result it references, either as fields, e.g.
+// {{ .Result.password }}, or with the index function, e.g. {{ index .Result "password" }}. Keys computed at runtime and
+// uses of the whole result, e.g. {{ urlDsn "postgres" .Result }}, are ignored.
+func ResultKeys(text string) ([]string, error) {
+	tmpl, err := template.New("").Funcs(dryRunFuncs(nil)).Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	found := make(map[string]bool)
+	for _, t := range tmpl.Templates() {
+		if t.Tree != nil {
+			collectResultKeys(t.Tree.Root, found)
+		}
+	}
+	keys := make([]string, 0, len(found))
+	for k := range found {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// collectResultKeys adds the keys of the operation result referenced by node and its children to found, see
+// ResultKeys.
+func collectResultKeys(node parse.Node, found map[string]bool) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			collectResultKeys(child, found)
+		}
+	case *parse.ActionNode:
+		collectResultKeys(n.Pipe, found)
+	case *parse.IfNode:
+		collectBranchResultKeys(&n.BranchNode, found)
+	case *parse.RangeNode:
+		collectBranchResultKeys(&n.BranchNode, found)
+	case *parse.WithNode:
+		collectBranchResultKeys(&n.BranchNode, found)
+	case *parse.TemplateNode:
+		collectResultKeys(n.Pipe, found)
+	case *parse.PipeNode:
+		if n == nil {
+			return
+		}
+		for _, cmd := range n.Cmds {
+			collectResultKeys(cmd, found)
+		}
+	case *parse.CommandNode:
+		if len(n.Args) == 3 && isIdentifier(n.Args[0], "index") && isResultNode(n.Args[1]) {
+			if key, ok := n.Args[2].(*parse.StringNode); ok {
+				found[key.Text] = true
+			}
+		}
+		for _, arg := range n.Args {
+			collectResultKeys(arg, found)
+		}
+	case *parse.FieldNode:
+		if len(n.Ident) > 1 && n.Ident[0] == "Result" {
+			found[n.Ident[1]] = true
+		}
+	case *parse.VariableNode:
+		if len(n.Ident) > 2 && n.Ident[0] == "$" && n.Ident[1] == "Result" {
+			found[n.Ident[2]] = true
+		}
+	}
+}
+
+// collectBranchResultKeys adds the keys of the operation result referenced by the pipeline and the lists of node to
+// found, see ResultKeys.
+func collectBranchResultKeys(node *parse.BranchNode, found map[string]bool) {
+	collectResultKeys(node.Pipe, found)
+	collectResultKeys(node.List, found)
+	collectResultKeys(node.ElseList, found)
+}
+
+// isIdentifier returns true if node is the function name.
+func isIdentifier(node parse.Node, name string) bool {
+	identifier, ok := node.(*parse.IdentifierNode)
+	return ok && identifier.Ident == name
+}
+
+// isResultNode returns true if node is the whole operation result, i.e. .Result or $.Result.
+func isResultNode(node parse.Node) bool {
+	switch n := node.(type) {
+	case *parse.FieldNode:
+		return len(n.Ident) == 1 && n.Ident[0] == "Result"
+	case *parse.VariableNode:
+		return len(n.Ident) == 2 && n.Ident[0] == "$" && n.Ident[1] == "Result"
+	}
+	return false
+}
+
+// dryRunMap test-renders each value of templates with values, in the order of their keys, see DryRunTemplate. The
+// functions of generator are available if it is not nil.
+func dryRunMap(templates map[string]string, values interface{}, generator *Generator) error {
+	keys := make([]string, 0, len(templates))
+	for k := range templates {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	funcs := dryRunFuncs(generator)
+	for _, k := range keys {
+		if _, err := renderGoTemplate(templates[k], values, funcs, zeroOnMissingKeyOption); err != nil {
+			return fmt.Errorf("unable to render '%s': %w", k, err)
+		}
+	}
+	return nil
+}
+
+// dryRunFuncs returns the template functions of dry runs: TemplateFuncs, whose value dependent functions ignore
+// errors, along with the functions of generator if it is not nil.
+func dryRunFuncs(generator *Generator) template.FuncMap {
+	funcs := TemplateFuncs()
+	for name, f := range funcs {
+		if !valueDependentFuncs[name] {
+			continue
+		}
+		switch f := f.(type) {
+		case func(string, interface{}) (string, error):
+			funcs[name] = func(driver string, values interface{}) (string, error) {
+				s, _ := f(driver, values)
+				return s, nil
+			}
+		case func(string) (string, error):
+			funcs[name] = func(s string) (string, error) {
+				decoded, _ := f(s)
+				return decoded, nil
+			}
+		}
+	}
+	if generator != nil {
+		for name, f := range generator.funcs() {
+			funcs[name] = f
+		}
+	}
+	return funcs
+}
@@ -0,0 +1,67 @@
+package database_test
+
+import (
+	"github.com/bedag/kubernetes-dbaas/pkg/database"
+	. "github.com/bedag/kubernetes-dbaas/pkg/test"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe(FormatTestDesc(Unit, "DryRun"), func() {
+	values := database.OpValues{
+		Version:    database.TemplateContextVersion,
+		Metadata:   map[string]interface{}{"name": "dry-run", "labels": map[string]interface{}{}},
+		Parameters: map[string]string{},
+	}
+	It("should accept values which are only known at runtime", func() {
+		op := database.Operation{Name: "sp_create", Inputs: map[string]string{
+			"name":   "{{ .Metadata.name }}",
+			"dept":   "{{ .Parameters.department | upper }}",
+			"team":   "{{ .Metadata.labels.team }}",
+			"dbName": `{{ index .Outputs "dbName" | default .InstanceName }}`,
+		}}
+		Expect(op.DryRun(values)).To(Succeed())
+		format := database.SecretFormat{
+			"password": "{{ .Result.password }}",
+			"cert":     "{{ .Result.cert | b64dec }}",
+			"dsn":      `{{ urlDsn "postgres" .Result }}`,
+		}
+		Expect(format.DryRun(values.WithOutput(database.OpOutput{}))).To(Succeed())
+	})
+	It("should report errors in templates", func() {
+		for _, text := range []string{
+			"{{ .Metadata.name ",
+			"{{ .Paramters.department }}",
+			"{{ unknown .Metadata.name }}",
+			"{{ .Result.username }}",
+			`{{ dict "key" }}`,
+		} {
+			op := database.Operation{Name: "sp_create", Inputs: map[string]string{"input": text}}
+			Expect(op.DryRun(values)).ToNot(Succeed(), "template '%s' should be reported", text)
+		}
+		Expect(database.DryRunTemplate("{{ .Result.username }", values.WithOutput(database.OpOutput{}))).ToNot(Succeed())
+	})
+	It("should report generated passwords violating the policy", func() {
+		op := database.Operation{Name: "sp_create", Inputs: map[string]string{"password": `{{ password 32 "alnum" }}`}}
+		generator := database.NewGenerator([]byte("seed"), database.PasswordPolicy{MaxLength: 24})
+		Expect(op.DryRun(values.WithGenerator(generator))).ToNot(Succeed())
+		generator = database.NewGenerator([]byte("seed"), database.PasswordPolicy{})
+		Expect(op.DryRun(values.WithGenerator(generator))).To(Succeed())
+		// Values can only be generated in operations rendered with a Generator
+		Expect(op.DryRun(values)).ToNot(Succeed())
+	})
+	It("should return the keys of the result referenced by templates", func() {
+		keys, err := database.ResultKeys(`{{ .Result.username }}:{{ index .Result "password" | b64dec }}` +
+			`{{ if .Result.host }}@{{ $.Result.host }}{{ end }}{{ urlDsn "postgres" .Result }}`)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(keys).To(Equal([]string{"host", "password", "username"}))
+		_, err = database.ResultKeys("{{ .Result.username }")
+		Expect(err).To(HaveOccurred())
+	})
+	It("should only support the registered drivers", func() {
+		for _, driver := range database.Drivers() {
+			Expect(database.IsDriverSupported(driver)).To(BeTrue())
+		}
+		Expect(database.IsDriverSupported("oracle")).To(BeFalse())
+	})
+})
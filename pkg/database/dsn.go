@@ -1,6 +1,13 @@
 package database
 
-import "github.com/xo/dburl"
+import (
+	"github.com/xo/dburl"
+	"net/url"
+	"strings"
+)
+
+// redacted replaces the sensitive parts of a Dsn in error messages, see Dsn.Redact.
+const redacted = "[REDACTED]"
 
 type Dsn string
 
@@ -40,7 +47,47 @@ func (dsn Dsn) GenPostgres() (string, error) {
 	return parsedDsn, nil
 }
 
+// HostPort returns the host and the port of dsn. They are empty if dsn can't be parsed or doesn't contain them.
+func (dsn Dsn) HostPort() (string, string) {
+	u, err := dburl.Parse(dsn.String())
+	if err != nil {
+		return "", ""
+	}
+	return u.Hostname(), u.Port()
+}
+
 // String returns a string from a Dsn.
 func (dsn Dsn) String() string {
 	return string(dsn)
 }
+
+// Redact returns err with the password of dsn removed from its message, so that it can be logged safely. If dsn cannot
+// be parsed, any occurrence of dsn is removed instead. The returned error wraps err.
+func (dsn Dsn) Redact(err error) error {
+	if err == nil || dsn == "" {
+		return err
+	}
+	msg := strings.ReplaceAll(err.Error(), dsn.String(), redacted)
+	if u, parseErr := url.Parse(dsn.String()); parseErr == nil && u.User != nil {
+		if password, set := u.User.Password(); set && password != "" {
+			for _, s := range []string{password, url.QueryEscape(password), url.PathEscape(password)} {
+				msg = strings.ReplaceAll(msg, s, redacted)
+			}
+		}
+	}
+	return &redactedError{msg: msg, err: err}
+}
+
+// redactedError is an error whose message was redacted, see Dsn.Redact.
+type redactedError struct {
+	msg string
+	err error
+}
+
+func (e *redactedError) Error() string {
+	return e.msg
+}
+
+func (e *redactedError) Unwrap() error {
+	return e.err
+}
@@ -1,31 +1,80 @@
 package database
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
-	_ "github.com/go-sql-driver/mysql"
+	"github.com/go-sql-driver/mysql"
 	"strconv"
+	"sync/atomic"
 )
 
+// terminalMysqlErrors are the numbers of MySQL errors which can't be solved by retrying, see
+// https://dev.mysql.com/doc/mysql-errors/8.0/en/server-error-reference.html.
+var terminalMysqlErrors = map[uint16]bool{
+	1044: true, // ER_DBACCESS_DENIED_ERROR
+	1049: true, // ER_BAD_DB_ERROR
+	1064: true, // ER_PARSE_ERROR
+	1142: true, // ER_TABLEACCESS_DENIED_ERROR
+	1305: true, // ER_SP_DOES_NOT_EXIST
+	1318: true, // ER_SP_WRONG_NO_OF_ARGS
+	1370: true, // ER_PROCACCESS_DENIED_ERROR
+	1644: true, // ER_SIGNAL_EXCEPTION, raised by the stored procedure
+}
+
+// mysqlTLSConfigs counts the TLS configurations registered by NewMysqlConn, so that each gets a unique name.
+var mysqlTLSConfigs uint64
+
 // MysqlConn represents a connection to a MySQL DBMS.
 type MysqlConn struct {
 	c *sql.DB
+	// tlsConfigName is the name of the TLS configuration registered for the connection, if any.
+	tlsConfigName string
 }
 
-// NewMysqlConn opens a new SQL Server connection from a given dsn.
-func NewMysqlConn(dsn string) (*MysqlConn, error) {
-	dbConn, err := sql.Open("mysql", dsn)
+// NewMysqlConn opens a new MySQL connection from a given dsn, configured by options. If options specify a TLS mode,
+// the tls parameter of the dsn is ignored.
+func NewMysqlConn(dsn string, options ConnectionOptions) (*MysqlConn, error) {
+	config, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		return nil, err
+	}
+	if options.ConnectTimeout > 0 {
+		config.Timeout = options.ConnectTimeout
+	}
+	conn := MysqlConn{}
+	switch {
+	case options.TLS != nil:
+		conn.tlsConfigName = fmt.Sprintf("kubernetes-dbaas-%d", atomic.AddUint64(&mysqlTLSConfigs, 1))
+		if err := mysql.RegisterTLSConfig(conn.tlsConfigName, options.TLS); err != nil {
+			return nil, err
+		}
+		config.TLSConfig = conn.tlsConfigName
+	case options.TLSMode == TLSModeDisable:
+		config.TLSConfig = "false"
+	}
+	if options.TLSMode != "" {
+		// Parse the configuration again, otherwise the TLS configuration of dsn still applies
+		if config, err = mysql.ParseDSN(config.FormatDSN()); err != nil {
+			conn.deregisterTLSConfig()
+			return nil, err
+		}
+	}
+	connector, err := mysql.NewConnector(config)
 	if err != nil {
+		conn.deregisterTLSConfig()
 		return nil, err
 	}
+	conn.c = sql.OpenDB(connector)
+	applySqlOptions(conn.c, options)
 
-	conn := MysqlConn{dbConn}
 	return &conn, nil
 }
 
 // CreateDb attempts to create a new database as specified in the operation parameter. It returns an OpOutput with the
 // result of the call.
-func (c *MysqlConn) CreateDb(operation Operation) OpOutput {
+func (c *MysqlConn) CreateDb(ctx context.Context, operation Operation) OpOutput {
 	sp, err := GetMysqlOpQuery(operation)
 	if err != nil {
 		return OpOutput{
@@ -34,28 +83,18 @@ func (c *MysqlConn) CreateDb(operation Operation) OpOutput {
 		}
 	}
 
-	rows, err := c.c.Query(sp)
+	rows, err := c.c.QueryContext(ctx, sp)
 	if err != nil {
 		return OpOutput{Result: nil, Err: err}
 	}
+	defer rows.Close()
 
-	var key string
-	var value string
-	result := make(map[string]string)
-	for rows.Next() {
-		err = rows.Scan(&key, &value)
-		if err != nil {
-			return OpOutput{nil, err}
-		}
-		result[key] = value
-	}
-
-	return OpOutput{result, nil}
+	return scanKeyValueRows(rows)
 }
 
 // DeleteDb attempts to delete a database instance as specified in the operation parameter. It returns an OpOutput with the
 // result of the call if present.
-func (c *MysqlConn) DeleteDb(operation Operation) OpOutput {
+func (c *MysqlConn) DeleteDb(ctx context.Context, operation Operation) OpOutput {
 	sp, err := GetMysqlOpQuery(operation)
 	if err != nil {
 		return OpOutput{
@@ -63,16 +102,16 @@ func (c *MysqlConn) DeleteDb(operation Operation) OpOutput {
 			Err:    err,
 		}
 	}
-	_, err = c.c.Exec(sp)
+	_, err = c.c.ExecContext(ctx, sp)
 	if err != nil {
-		return OpOutput{nil, err}
+		return OpOutput{Err: err}
 	}
 
 	return OpOutput{}
 }
 
 // Rotate attempts to rotate the credentials of a connection.
-func (c *MysqlConn) Rotate(operation Operation) OpOutput {
+func (c *MysqlConn) Rotate(ctx context.Context, operation Operation) OpOutput {
 	sp, err := GetMysqlOpQuery(operation)
 	if err != nil {
 		return OpOutput{
@@ -81,28 +120,79 @@ func (c *MysqlConn) Rotate(operation Operation) OpOutput {
 		}
 	}
 
-	rows, err := c.c.Query(sp)
+	rows, err := c.c.QueryContext(ctx, sp)
 	if err != nil {
 		return OpOutput{Result: nil, Err: err}
 	}
+	defer rows.Close()
 
-	var key string
-	var value string
-	result := make(map[string]string)
-	for rows.Next() {
-		err = rows.Scan(&key, &value)
-		if err != nil {
-			return OpOutput{nil, err}
-		}
-		result[key] = value
+	return scanKeyValueRows(rows)
+}
+
+// Exists checks whether the database instance specified in the operation parameter exists. It returns an OpOutput
+// with the result of the call. See OpOutput.IsExisting.
+func (c *MysqlConn) Exists(ctx context.Context, operation Operation) OpOutput {
+	sp, err := GetMysqlOpQuery(operation)
+	if err != nil {
+		return OpOutput{Err: err}
+	}
+	rows, err := c.c.QueryContext(ctx, sp)
+	if err != nil {
+		return OpOutput{Err: err}
 	}
+	defer rows.Close()
 
-	return OpOutput{result, nil}
+	return scanKeyValueRows(rows)
+}
+
+// List returns the database instances present on the endpoint. It returns an OpOutput with the result of the call.
+// See OpOutput.Instances.
+func (c *MysqlConn) List(ctx context.Context, operation Operation) OpOutput {
+	sp, err := GetMysqlOpQuery(operation)
+	if err != nil {
+		return OpOutput{Err: err}
+	}
+	rows, err := c.c.QueryContext(ctx, sp)
+	if err != nil {
+		return OpOutput{Err: err}
+	}
+	defer rows.Close()
+
+	return scanKeyValueRows(rows)
+}
+
+// Usage returns the usage metrics of the database instance specified in the operation parameter. It returns an
+// OpOutput with the result of the call. See OpOutput.UsageMetrics.
+func (c *MysqlConn) Usage(ctx context.Context, operation Operation) OpOutput {
+	sp, err := GetMysqlOpQuery(operation)
+	if err != nil {
+		return OpOutput{Err: err}
+	}
+	rows, err := c.c.QueryContext(ctx, sp)
+	if err != nil {
+		return OpOutput{Err: err}
+	}
+	defer rows.Close()
+
+	return scanKeyValueRows(rows)
 }
 
 // Ping returns an error if a connection cannot be established with the DBMS, else it returns nil.
-func (c *MysqlConn) Ping() error {
-	return c.c.Ping()
+func (c *MysqlConn) Ping(ctx context.Context) error {
+	return c.c.PingContext(ctx)
+}
+
+// Close closes the connections to the DBMS.
+func (c *MysqlConn) Close() error {
+	c.deregisterTLSConfig()
+	return c.c.Close()
+}
+
+// deregisterTLSConfig removes the TLS configuration registered for the connection, if any.
+func (c *MysqlConn) deregisterTLSConfig() {
+	if c.tlsConfigName != "" {
+		mysql.DeregisterTLSConfig(c.tlsConfigName)
+	}
 }
 
 // GetMysqlOpQuery constructs a CALL query from the specified operation. Keys of operation.Inputs must be integers, they
@@ -143,3 +233,9 @@ func getMysqlInputs(inputs map[string]string) (string, error) {
 
 	return result, nil
 }
+
+// isTerminalMysqlError returns true if err is a MySQL error whose number belongs to terminalMysqlErrors.
+func isTerminalMysqlError(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	return errors.As(err, &mysqlErr) && terminalMysqlErrors[mysqlErr.Number]
+}
@@ -1,6 +1,7 @@
 package database_test
 
 import (
+	"context"
 	"fmt"
 	"github.com/bedag/kubernetes-dbaas/pkg/database"
 	. "github.com/bedag/kubernetes-dbaas/pkg/test"
@@ -18,7 +19,7 @@ var _ = Describe(FormatTestDesc(Integration, "Mariadb CreateDb"), func() {
 	dsn, err := database.Dsn(os.Getenv("MARIADB_DSN")).GenMysql()
 	Expect(err).ToNot(HaveOccurred())
 
-	conn, err := database.NewMysqlConn(dsn)
+	conn, err := database.NewMysqlConn(dsn, database.ConnectionOptions{})
 	Expect(err).ToNot(HaveOccurred())
 
 	BeforeEach(func() {
@@ -44,7 +45,7 @@ var _ = Describe(FormatTestDesc(Integration, "Mariadb CreateDb"), func() {
 			Err: nil,
 		}
 		// Execute tested behavior
-		result = conn.CreateDb(createOperation)
+		result = conn.CreateDb(context.Background(), createOperation)
 	})
 	Context("when Operation is defined correctly", func() {
 		It("should not return an error", func() {
@@ -0,0 +1,88 @@
+package database
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+const (
+	// instanceNameHashLength is the number of hex characters of the hash which makes instance names unique.
+	instanceNameHashLength = 8
+	// clusterTagLength is the number of hex characters of the cluster tag of instance names, see ClusterTag.
+	clusterTagLength = 6
+	// defaultInstanceNameMaxLength is the maximum length of instance names for drivers without a specific rule. It is
+	// the shortest limit of the supported drivers.
+	defaultInstanceNameMaxLength = 63
+)
+
+// instanceNameMaxLengths are the maximum lengths of identifiers by driver.
+var instanceNameMaxLengths = map[string]int{
+	Postgres:  63,
+	Mysql:     64,
+	Mariadb:   64,
+	Sqlserver: 128,
+}
+
+// InstanceName returns a deterministic name for the database instance of the Database resource name in namespace of
+// the cluster clusterID. The name is made of the namespace and the name of the resource, lowercased with every character
+// other than letters, digits and underscores replaced by an underscore, followed by the cluster tag of clusterID, see
+// ClusterTag, and a hash of clusterID, namespace and name, e.g. "team_a_orders_5f3a9c1b2c3d4e". It always starts with a
+// letter and the readable part is truncated to the maximum identifier length of driver, so that it can be used unquoted
+// by any supported DBMS. Resources with the same name in different namespaces or clusters get different names.
+func InstanceName(driver, clusterID, namespace, name string) string {
+	maxLength, ok := instanceNameMaxLengths[driver]
+	if !ok {
+		maxLength = defaultInstanceNameMaxLength
+	}
+	sum := sha256.Sum256([]byte(clusterID + "/" + namespace + "/" + name))
+	hash := ClusterTag(clusterID) + hex.EncodeToString(sum[:])[:instanceNameHashLength]
+
+	prefix := sanitizeIdentifier(namespace + "_" + name)
+	if prefix[0] < 'a' || prefix[0] > 'z' {
+		prefix = "d" + prefix
+	}
+	if maxPrefixLength := maxLength - len(hash) - 1; len(prefix) > maxPrefixLength {
+		prefix = prefix[:maxPrefixLength]
+	}
+	return prefix + "_" + hash
+}
+
+// ClusterTag returns the tag identifying the cluster clusterID in the names returned by InstanceName, so that the
+// database instances of a cluster can be told apart from the ones of other clusters sharing the same endpoint.
+func ClusterTag(clusterID string) string {
+	sum := sha256.Sum256([]byte(clusterID))
+	return hex.EncodeToString(sum[:])[:clusterTagLength]
+}
+
+// ParseClusterTag returns the cluster tag of instanceName, see ClusterTag. It returns false if instanceName wasn't
+// returned by InstanceName, e.g. because the database instance was created by a previous version of the Operator or
+// outside of it.
+func ParseClusterTag(instanceName string) (string, bool) {
+	suffixLength := clusterTagLength + instanceNameHashLength
+	if len(instanceName) <= suffixLength+1 || instanceName[len(instanceName)-suffixLength-1] != '_' {
+		return "", false
+	}
+	suffix := instanceName[len(instanceName)-suffixLength:]
+	for _, r := range suffix {
+		if !(r >= '0' && r <= '9' || r >= 'a' && r <= 'f') {
+			return "", false
+		}
+	}
+	return suffix[:clusterTagLength], true
+}
+
+// sanitizeIdentifier returns s lowercased, with every character other than letters, digits and underscores replaced by
+// an underscore.
+func sanitizeIdentifier(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '_':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r - 'A' + 'a'
+		default:
+			return '_'
+		}
+	}, s)
+}
@@ -0,0 +1,53 @@
+package database_test
+
+import (
+	"github.com/bedag/kubernetes-dbaas/pkg/database"
+	. "github.com/bedag/kubernetes-dbaas/pkg/test"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"strings"
+)
+
+var _ = Describe(FormatTestDesc(Unit, "InstanceName"), func() {
+	It("should return the same name for the same resource", func() {
+		name := database.InstanceName(database.Postgres, "cluster", "team-a", "orders")
+		Expect(name).To(MatchRegexp("^team_a_orders_[0-9a-f]{14}$"))
+		Expect(database.InstanceName(database.Postgres, "cluster", "team-a", "orders")).To(Equal(name))
+	})
+	It("should return different names across namespaces and clusters", func() {
+		name := database.InstanceName(database.Postgres, "cluster", "team-a", "orders")
+		Expect(database.InstanceName(database.Postgres, "cluster", "team-b", "orders")).ToNot(Equal(name))
+		Expect(database.InstanceName(database.Postgres, "other-cluster", "team-a", "orders")).ToNot(Equal(name))
+		// Names which are equal once sanitized are still distinguished by the hash
+		Expect(database.InstanceName(database.Postgres, "cluster", "team", "a-orders")).ToNot(
+			Equal(database.InstanceName(database.Postgres, "cluster", "team-a", "orders")))
+	})
+	It("should follow the identifier rules of the driver", func() {
+		longName := strings.Repeat("a", 200)
+		Expect(database.InstanceName(database.Postgres, "cluster", "team", longName)).To(HaveLen(63))
+		Expect(database.InstanceName(database.Mysql, "cluster", "team", longName)).To(HaveLen(64))
+		Expect(database.InstanceName(database.Mariadb, "cluster", "team", longName)).To(HaveLen(64))
+		Expect(database.InstanceName(database.Sqlserver, "cluster", "team", longName)).To(HaveLen(128))
+		Expect(database.InstanceName("unknown", "cluster", "team", longName)).To(HaveLen(63))
+		Expect(database.InstanceName(database.Sqlserver, "cluster", "1st-team", "Orders.v2")).To(
+			MatchRegexp("^d1st_team_orders_v2_[0-9a-f]{14}$"))
+	})
+})
+
+var _ = Describe(FormatTestDesc(Unit, "ParseClusterTag"), func() {
+	It("should return the cluster tag of the names returned by InstanceName", func() {
+		tag, ok := database.ParseClusterTag(database.InstanceName(database.Postgres, "cluster", "team-a", "orders"))
+		Expect(ok).To(BeTrue())
+		Expect(tag).To(Equal(database.ClusterTag("cluster")))
+		Expect(database.ClusterTag("other-cluster")).ToNot(Equal(tag))
+		tag, ok = database.ParseClusterTag(database.InstanceName(database.Postgres, "cluster", "team", strings.Repeat("a", 200)))
+		Expect(ok).To(BeTrue())
+		Expect(tag).To(Equal(database.ClusterTag("cluster")))
+	})
+	It("should not return a cluster tag for other names", func() {
+		for _, name := range []string{"orders", "team_a_orders_1234abcd", "team_a_orders_5f3a9c1b2c3d4g", "_5f3a9c1b2c3d4e"} {
+			_, ok := database.ParseClusterTag(name)
+			Expect(ok).To(BeFalse(), name)
+		}
+	})
+})
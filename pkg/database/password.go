@@ -0,0 +1,251 @@
+package database
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+const (
+	// DefaultPasswordMinLength is the minimum length of generated passwords if the PasswordPolicy doesn't specify any.
+	DefaultPasswordMinLength = 16
+	// DefaultPasswordMaxLength is the maximum length of generated passwords if the PasswordPolicy doesn't specify any.
+	DefaultPasswordMaxLength = 128
+	// DefaultCharsets are the charsets of generated passwords if the template doesn't specify any.
+	DefaultCharsets = "alnum"
+
+	// maxPasswordAttempts is the number of candidates drawn before giving up on a password containing every charset.
+	maxPasswordAttempts = 100
+)
+
+// charsetClasses are the characters of the classes composing each charset. A password generated from a charset
+// contains at least one character of each of its classes.
+var charsetClasses = map[string][]string{
+	"lower":   {"abcdefghijklmnopqrstuvwxyz"},
+	"upper":   {"ABCDEFGHIJKLMNOPQRSTUVWXYZ"},
+	"digits":  {"0123456789"},
+	"alpha":   {"abcdefghijklmnopqrstuvwxyz", "ABCDEFGHIJKLMNOPQRSTUVWXYZ"},
+	"alnum":   {"abcdefghijklmnopqrstuvwxyz", "ABCDEFGHIJKLMNOPQRSTUVWXYZ", "0123456789"},
+	"hex":     {"0123456789abcdef"},
+	"symbols": {"!#$%&()*+,-./:;<=>?@[]^_{|}~"},
+}
+
+// errNoGenerator is returned by the generator functions of TemplateFuncs, which are only bound to a Generator when
+// rendering operations.
+var errNoGenerator = errors.New("values can only be generated in operation inputs")
+
+// +kubebuilder:object:generate=true
+// PasswordPolicy constrains the passwords generated by the templates of operation inputs.
+type PasswordPolicy struct {
+	// MinLength is the minimum length of generated passwords. Defaults to 16.
+	MinLength int `json:"minLength,omitempty"`
+	// MaxLength is the maximum length of generated passwords. Defaults to 128.
+	MaxLength int `json:"maxLength,omitempty"`
+	// AllowedCharsets lists the charsets templates may request, e.g. "alnum". Defaults to all charsets.
+	AllowedCharsets []string `json:"allowedCharsets,omitempty"`
+	// ExcludedCharacters are never used in generated passwords, e.g. quotes which a stored procedure doesn't accept.
+	ExcludedCharacters string `json:"excludedCharacters,omitempty"`
+}
+
+// Generator generates the passwords and random values of the templates of an operation. Values are derived from a
+// secret seed, so that rendering the same templates with the same seed generates the same values, e.g. when an
+// operation is retried.
+type Generator struct {
+	seed   []byte
+	policy PasswordPolicy
+	// calls is the number of values generated so far, it makes each value unique
+	calls uint32
+	// used is true if the template being rendered generated a value
+	used bool
+	// generated contains the rendered templates which generated a value, by key
+	generated map[string]string
+}
+
+// NewGenerator returns a Generator deriving its values from seed and generating passwords obeying policy.
+func NewGenerator(seed []byte, policy PasswordPolicy) *Generator {
+	return &Generator{seed: seed, policy: policy}
+}
+
+// Password returns a password of length characters taken from charsets, a list of charsets joined with "+", e.g.
+// "alnum+symbols". The password contains at least one character of each class of the charsets, e.g. a lowercase
+// letter, an uppercase letter and a digit for "alnum". It returns an error if the request violates the policy of g.
+func (g *Generator) Password(length int, charsets string) (string, error) {
+	minLength, maxLength := g.policy.MinLength, g.policy.MaxLength
+	if minLength == 0 {
+		minLength = DefaultPasswordMinLength
+	}
+	if maxLength == 0 {
+		maxLength = DefaultPasswordMaxLength
+	}
+	if length < minLength || length > maxLength {
+		return "", fmt.Errorf("password length %d violates policy: must be between %d and %d", length, minLength, maxLength)
+	}
+	classes, err := g.classes(charsets)
+	if err != nil {
+		return "", err
+	}
+	if length < len(classes) {
+		return "", fmt.Errorf("password length %d is too short for charsets '%s'", length, charsets)
+	}
+	alphabet := strings.Join(classes, "")
+	stream := g.stream()
+	for attempt := 0; attempt < maxPasswordAttempts; attempt++ {
+		password := make([]byte, length)
+		for i := range password {
+			password[i] = alphabet[stream.intn(len(alphabet))]
+		}
+		if containsEachClass(string(password), classes) {
+			return string(password), nil
+		}
+	}
+	return "", fmt.Errorf("unable to generate a password containing each class of charsets '%s'", charsets)
+}
+
+// RandomHex returns length random bytes, hex-encoded. Random values are not constrained by the policy of g.
+func (g *Generator) RandomHex(length int) (string, error) {
+	if length <= 0 {
+		return "", fmt.Errorf("invalid length %d: must be positive", length)
+	}
+	stream := g.stream()
+	value := make([]byte, length)
+	for i := range value {
+		value[i] = byte(stream.intn(256))
+	}
+	return hex.EncodeToString(value), nil
+}
+
+// Generated returns the rendered operation inputs which called password or randomHex, by key. Since the generated
+// values are never stored by the Operator, they must be handed over through the SecretFormat, see OpOutput.Generated.
+func (g *Generator) Generated() map[string]string {
+	return g.generated
+}
+
+// render renders text with values and the functions bound to g. If text generated a value, the result is recorded
+// under key, see Generated.
+func (g *Generator) render(key, text string, values interface{}, options ...string) (string, error) {
+	g.used = false
+	funcs := TemplateFuncs()
+	for name, f := range g.funcs() {
+		funcs[name] = f
+	}
+	rendered, err := renderGoTemplate(text, values, funcs, options...)
+	if err != nil {
+		return "", err
+	}
+	if g.used {
+		if g.generated == nil {
+			g.generated = map[string]string{}
+		}
+		g.generated[key] = rendered
+	}
+	return rendered, nil
+}
+
+// funcs returns the template functions bound to g.
+func (g *Generator) funcs() template.FuncMap {
+	return template.FuncMap{
+		"password":  g.Password,
+		"randomHex": g.RandomHex,
+	}
+}
+
+// classes returns the classes of charsets, without the excluded characters of the policy of g.
+func (g *Generator) classes(charsets string) ([]string, error) {
+	if charsets == "" {
+		charsets = DefaultCharsets
+	}
+	seen := map[string]bool{}
+	var classes []string
+	for _, charset := range strings.Split(charsets, "+") {
+		members, ok := charsetClasses[charset]
+		if !ok {
+			return nil, fmt.Errorf("unknown charset '%s'", charset)
+		}
+		if len(g.policy.AllowedCharsets) > 0 && !containsString(g.policy.AllowedCharsets, charset) {
+			return nil, fmt.Errorf("charset '%s' violates policy: must be one of %s", charset, strings.Join(g.policy.AllowedCharsets, ", "))
+		}
+		for _, class := range members {
+			class = strings.Map(func(r rune) rune {
+				if strings.ContainsRune(g.policy.ExcludedCharacters, r) {
+					return -1
+				}
+				return r
+			}, class)
+			if class != "" && !seen[class] {
+				seen[class] = true
+				classes = append(classes, class)
+			}
+		}
+	}
+	if len(classes) == 0 {
+		return nil, fmt.Errorf("charsets '%s' contain only excluded characters", charsets)
+	}
+	return classes, nil
+}
+
+// stream returns the stream of the next value generated by g.
+func (g *Generator) stream() *hmacStream {
+	g.used = true
+	g.calls++
+	return &hmacStream{seed: g.seed, call: g.calls}
+}
+
+// hmacStream is a deterministic stream of bytes derived from a seed with HMAC-SHA256 in counter mode.
+type hmacStream struct {
+	seed   []byte
+	call   uint32
+	block  uint32
+	buffer []byte
+}
+
+// byte returns the next byte of s.
+func (s *hmacStream) byte() byte {
+	if len(s.buffer) == 0 {
+		mac := hmac.New(sha256.New, s.seed)
+		var counter [8]byte
+		binary.BigEndian.PutUint32(counter[:4], s.call)
+		binary.BigEndian.PutUint32(counter[4:], s.block)
+		mac.Write(counter[:])
+		s.buffer = mac.Sum(nil)
+		s.block++
+	}
+	b := s.buffer[0]
+	s.buffer = s.buffer[1:]
+	return b
+}
+
+// intn returns a uniformly distributed integer in [0, n), n must be between 1 and 256.
+func (s *hmacStream) intn(n int) int {
+	// Reject the bytes which would bias the result towards the first values
+	limit := 256 - 256%n
+	for {
+		if b := int(s.byte()); b < limit {
+			return b % n
+		}
+	}
+}
+
+// containsEachClass returns true if s contains at least one character of each of classes.
+func containsEachClass(s string, classes []string) bool {
+	for _, class := range classes {
+		if !strings.ContainsAny(s, class) {
+			return false
+		}
+	}
+	return true
+}
+
+// containsString returns true if s has been found in list.
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
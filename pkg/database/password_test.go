@@ -0,0 +1,89 @@
+package database_test
+
+import (
+	"context"
+	"github.com/bedag/kubernetes-dbaas/pkg/database"
+	. "github.com/bedag/kubernetes-dbaas/pkg/test"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"strings"
+)
+
+var _ = Describe(FormatTestDesc(Unit, "Generator"), func() {
+	seed := []byte("0123456789abcdef0123456789abcdef")
+	op := database.Operation{
+		Name: "sp_create",
+		Inputs: map[string]string{
+			"name":     "{{ .Metadata.name }}",
+			"password": `{{ password 32 "alnum+symbols" }}`,
+			"token":    "{{ randomHex 16 }}",
+		},
+	}
+	values := database.OpValues{Metadata: map[string]interface{}{"name": "db"}}
+	render := func(seed []byte, policy database.PasswordPolicy) (database.Operation, *database.Generator, error) {
+		generator := database.NewGenerator(seed, policy)
+		rendered, err := op.RenderOperation(context.Background(), values.WithGenerator(generator))
+		return rendered, generator, err
+	}
+
+	It("should generate the same values from the same seed", func() {
+		first, generator, err := render(seed, database.PasswordPolicy{})
+		Expect(err).ToNot(HaveOccurred())
+		second, _, err := render(seed, database.PasswordPolicy{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(second).To(Equal(first))
+		Expect(first.Inputs["name"]).To(Equal("db"))
+		Expect(first.Inputs["password"]).To(HaveLen(32))
+		Expect(first.Inputs["password"]).To(MatchRegexp("[a-z]"))
+		Expect(first.Inputs["password"]).To(MatchRegexp("[A-Z]"))
+		Expect(first.Inputs["password"]).To(MatchRegexp("[0-9]"))
+		Expect(first.Inputs["token"]).To(MatchRegexp("^[0-9a-f]{32}$"))
+		Expect(generator.Generated()).To(Equal(map[string]string{
+			"password": first.Inputs["password"],
+			"token":    first.Inputs["token"],
+		}))
+	})
+	It("should generate other values from another seed", func() {
+		first, _, err := render(seed, database.PasswordPolicy{})
+		Expect(err).ToNot(HaveOccurred())
+		second, _, err := render([]byte("another seed"), database.PasswordPolicy{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(second.Inputs["password"]).ToNot(Equal(first.Inputs["password"]))
+	})
+	It("should generate different values in a single operation", func() {
+		generator := database.NewGenerator(seed, database.PasswordPolicy{})
+		first, err := generator.Password(20, "alnum")
+		Expect(err).ToNot(HaveOccurred())
+		second, err := generator.Password(20, "alnum")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(second).ToNot(Equal(first))
+	})
+	It("should not use the excluded characters", func() {
+		generator := database.NewGenerator(seed, database.PasswordPolicy{ExcludedCharacters: `'"\;`})
+		for i := 0; i < 20; i++ {
+			password, err := generator.Password(64, "alnum+symbols")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(strings.ContainsAny(password, `'"\;`)).To(BeFalse())
+		}
+	})
+	It("should return an error if the policy is violated", func() {
+		_, _, err := render(seed, database.PasswordPolicy{MaxLength: 24})
+		Expect(err).To(HaveOccurred())
+		_, _, err = render(seed, database.PasswordPolicy{AllowedCharsets: []string{"alnum"}})
+		Expect(err).To(HaveOccurred())
+		_, err = database.NewGenerator(seed, database.PasswordPolicy{}).Password(32, "unknown")
+		Expect(err).To(HaveOccurred())
+	})
+	It("should only generate values in operations", func() {
+		_, err := database.RenderGoTemplate("{{ password 32 \"alnum\" }}", values)
+		Expect(err).To(HaveOccurred())
+		_, err = database.SecretFormat{"password": "{{ randomHex 16 }}"}.RenderSecretFormat(context.Background(), database.SecretValues{})
+		Expect(err).To(HaveOccurred())
+	})
+	It("should hand over the generated values to the SecretFormat", func() {
+		output := database.OpOutput{Result: map[string]string{}, Generated: map[string]string{"password": "Generated&1"}}
+		rendered, err := database.SecretFormat{"password": "{{ .Generated.password }}"}.RenderSecretFormat(context.Background(), database.OpValues{}.WithOutput(output))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(rendered).To(HaveKeyWithValue("password", "Generated&1"))
+	})
+})
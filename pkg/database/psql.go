@@ -2,18 +2,56 @@ package database
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"github.com/jackc/pgconn"
 	"github.com/jackc/pgx/v4/pgxpool"
+	"strings"
 )
 
+// terminalPsqlErrorClasses are the SQLSTATE classes of errors which can't be solved by retrying, see
+// https://www.postgresql.org/docs/current/errcodes-appendix.html.
+var terminalPsqlErrorClasses = []string{
+	"22", // Data Exception
+	"23", // Integrity Constraint Violation
+	"42", // Syntax Error or Access Rule Violation, e.g. undefined function
+	"P0", // PL/pgSQL Error, e.g. raise_exception
+}
+
 // PsqlConn represents a connection to a SQL Server DBMS.
 type PsqlConn struct {
 	c *pgxpool.Pool
 }
 
-// NewPsqlConn opens a new PostgreSQL connection from a given dsn.
-func NewPsqlConn(dsn string) (*PsqlConn, error) {
-	dbConn, err := pgxpool.Connect(context.Background(), dsn)
+// NewPsqlConn opens a new PostgreSQL connection from a given dsn, configured by options. If options specify a TLS
+// mode, the sslmode parameter of the dsn is ignored.
+func NewPsqlConn(dsn string, options ConnectionOptions) (*PsqlConn, error) {
+	config, err := pgxpool.ParseConfig(dsn)
+	if err != nil {
+		return nil, err
+	}
+	if options.MaxOpenConnections > 0 {
+		config.MaxConns = int32(options.MaxOpenConnections)
+	}
+	if options.MaxConnectionLifetime > 0 {
+		config.MaxConnLifetime = options.MaxConnectionLifetime
+	}
+	if options.MaxConnectionIdleTime > 0 {
+		config.MaxConnIdleTime = options.MaxConnectionIdleTime
+	}
+	if options.ConnectTimeout > 0 {
+		config.ConnConfig.ConnectTimeout = options.ConnectTimeout
+	}
+	if options.TLSMode != "" {
+		config.ConnConfig.TLSConfig = options.TLS
+		if options.TLS != nil && options.TLS.ServerName == "" {
+			config.ConnConfig.TLSConfig = options.TLS.Clone()
+			config.ConnConfig.TLSConfig.ServerName = config.ConnConfig.Host
+		}
+		// Don't fall back to other TLS modes
+		config.ConnConfig.Fallbacks = nil
+	}
+	dbConn, err := pgxpool.ConnectConfig(context.Background(), config)
 	if err != nil {
 		return nil, err
 	}
@@ -24,63 +62,85 @@ func NewPsqlConn(dsn string) (*PsqlConn, error) {
 
 // CreateDb attempts to create a new database as specified in the operation parameter. It returns an OpOutput with the
 // result of the call.
-func (c *PsqlConn) CreateDb(operation Operation) OpOutput {
+func (c *PsqlConn) CreateDb(ctx context.Context, operation Operation) OpOutput {
 	val := getPsqlOpQuery(operation)
-	rows, err := c.c.Query(context.Background(), val)
+	rows, err := c.c.Query(ctx, val)
 	if err != nil {
 		return OpOutput{Result: nil, Err: err}
 	}
+	defer rows.Close()
 
-	var key string
-	var value string
-	result := make(map[string]string)
-	for rows.Next() {
-		err = rows.Scan(&key, &value)
-		if err != nil {
-			return OpOutput{nil, err}
-		}
-		result[key] = value
-	}
-
-	return OpOutput{result, nil}
+	return scanKeyValueRows(rows)
 }
 
 // DeleteDb attempts to delete a database instance as specified in the operation parameter. It returns an OpOutput with the
 // result of the call if present.
-func (c *PsqlConn) DeleteDb(operation Operation) OpOutput {
-	_, err := c.c.Exec(context.Background(), getPsqlVoidOpQuery(operation))
+func (c *PsqlConn) DeleteDb(ctx context.Context, operation Operation) OpOutput {
+	_, err := c.c.Exec(ctx, getPsqlVoidOpQuery(operation))
 	if err != nil {
-		return OpOutput{nil, err}
+		return OpOutput{Err: err}
 	}
 
 	return OpOutput{}
 }
 
 // Rotate attempts to rotate the credentials of a connection.
-func (c *PsqlConn) Rotate(operation Operation) OpOutput {
+func (c *PsqlConn) Rotate(ctx context.Context, operation Operation) OpOutput {
 	val := getPsqlOpQuery(operation)
-	rows, err := c.c.Query(context.Background(), val)
+	rows, err := c.c.Query(ctx, val)
 	if err != nil {
-		return OpOutput{nil, err}
+		return OpOutput{Err: err}
 	}
+	defer rows.Close()
 
-	var key string
-	var value string
-	result := make(map[string]string)
-	for rows.Next() {
-		err = rows.Scan(&key, &value)
-		if err != nil {
-			return OpOutput{nil, err}
-		}
-		result[key] = value
+	return scanKeyValueRows(rows)
+}
+
+// Exists checks whether the database instance specified in the operation parameter exists. It returns an OpOutput
+// with the result of the call. See OpOutput.IsExisting.
+func (c *PsqlConn) Exists(ctx context.Context, operation Operation) OpOutput {
+	rows, err := c.c.Query(ctx, getPsqlOpQuery(operation))
+	if err != nil {
+		return OpOutput{Err: err}
 	}
+	defer rows.Close()
 
-	return OpOutput{result, nil}
+	return scanKeyValueRows(rows)
+}
+
+// List returns the database instances present on the endpoint. It returns an OpOutput with the result of the call.
+// See OpOutput.Instances.
+func (c *PsqlConn) List(ctx context.Context, operation Operation) OpOutput {
+	rows, err := c.c.Query(ctx, getPsqlOpQuery(operation))
+	if err != nil {
+		return OpOutput{Err: err}
+	}
+	defer rows.Close()
+
+	return scanKeyValueRows(rows)
+}
+
+// Usage returns the usage metrics of the database instance specified in the operation parameter. It returns an
+// OpOutput with the result of the call. See OpOutput.UsageMetrics.
+func (c *PsqlConn) Usage(ctx context.Context, operation Operation) OpOutput {
+	rows, err := c.c.Query(ctx, getPsqlOpQuery(operation))
+	if err != nil {
+		return OpOutput{Err: err}
+	}
+	defer rows.Close()
+
+	return scanKeyValueRows(rows)
 }
 
 // Ping returns an error if a connection cannot be established with the DBMS, else it returns nil.
-func (c *PsqlConn) Ping() error {
-	return c.c.Ping(context.Background())
+func (c *PsqlConn) Ping(ctx context.Context) error {
+	return c.c.Ping(ctx)
+}
+
+// Close closes all the connections of the pool, waiting for the connections in use to be released.
+func (c *PsqlConn) Close() error {
+	c.c.Close()
+	return nil
 }
 
 func getPsqlOpQuery(operation Operation) string {
@@ -103,3 +163,17 @@ func getPsqlInputs(values map[string]string) string {
 	result = result[:len(result)-2]
 	return result
 }
+
+// isTerminalPsqlError returns true if err is a PostgreSQL error whose SQLSTATE belongs to terminalPsqlErrorClasses.
+func isTerminalPsqlError(err error) bool {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return false
+	}
+	for _, class := range terminalPsqlErrorClasses {
+		if strings.HasPrefix(pgErr.Code, class) {
+			return true
+		}
+	}
+	return false
+}
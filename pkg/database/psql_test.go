@@ -1,6 +1,7 @@
 package database_test
 
 import (
+	"context"
 	"github.com/bedag/kubernetes-dbaas/pkg/database"
 	. "github.com/bedag/kubernetes-dbaas/pkg/test"
 	. "github.com/onsi/ginkgo"
@@ -13,7 +14,7 @@ var _ = Describe(FormatTestDesc(Integration, "Postgres CreateDb"), func() {
 	dsn, err := database.Dsn(os.Getenv("POSTGRES_DSN")).GenPostgres()
 	Expect(err).ToNot(HaveOccurred())
 
-	conn, err := database.NewPsqlConn(dsn)
+	conn, err := database.NewPsqlConn(dsn, database.ConnectionOptions{})
 	Expect(err).ToNot(HaveOccurred())
 
 	// Prepare assertion data
@@ -40,7 +41,7 @@ var _ = Describe(FormatTestDesc(Integration, "Postgres CreateDb"), func() {
 
 		// Execute tested operation
 		var result database.OpOutput
-		result = conn.CreateDb(createOperation)
+		result = conn.CreateDb(context.Background(), createOperation)
 
 		It("should not return an error", func() {
 			Expect(result.Err).ToNot(HaveOccurred())
@@ -65,10 +66,47 @@ var _ = Describe(FormatTestDesc(Integration, "Postgres CreateDb"), func() {
 
 		// Execute tested operation
 		var result database.OpOutput
-		result = conn.CreateDb(createOperation)
+		result = conn.CreateDb(context.Background(), createOperation)
 
 		It("should return an error", func() {
 			Expect(result.Err).To(HaveOccurred())
 		})
 	})
 })
+
+var _ = Describe(FormatTestDesc(Integration, "Postgres failing operation"), func() {
+	// Setting up connection to DBMS
+	dsn, err := database.Dsn(os.Getenv("POSTGRES_DSN")).GenPostgres()
+	Expect(err).ToNot(HaveOccurred())
+
+	conn, err := database.NewPsqlConn(dsn, database.ConnectionOptions{})
+	Expect(err).ToNot(HaveOccurred())
+
+	// The procedure returns a row before raising an exception, which is only reported while reading the rows
+	failOperation := database.Operation{
+		Name: PostgresFailOpName,
+		Inputs: map[string]string{
+			"k8sName": "my-test-db",
+		},
+	}
+
+	It("should return the error raised by Exists", func() {
+		Expect(conn.Exists(context.Background(), failOperation).Err).To(MatchError(ContainSubstring("database my-test-db is locked")))
+	})
+
+	It("should return the error raised by List", func() {
+		Expect(conn.List(context.Background(), failOperation).Err).To(MatchError(ContainSubstring("database my-test-db is locked")))
+	})
+
+	It("should return the error raised by CreateDb as a terminal error", func() {
+		err := conn.CreateDb(context.Background(), failOperation).Err
+		Expect(err).To(MatchError(ContainSubstring("database my-test-db is locked")))
+		Expect(database.IsTerminalError(err)).To(BeTrue())
+	})
+
+	It("should return the error raised by Rotate as a terminal error", func() {
+		err := conn.Rotate(context.Background(), failOperation).Err
+		Expect(err).To(MatchError(ContainSubstring("database my-test-db is locked")))
+		Expect(database.IsTerminalError(err)).To(BeTrue())
+	})
+})
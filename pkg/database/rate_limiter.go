@@ -1,7 +1,9 @@
 package database
 
 import (
+	"context"
 	"fmt"
+	"github.com/bedag/kubernetes-dbaas/internal/tracing"
 	"go.uber.org/ratelimit"
 )
 
@@ -30,17 +32,39 @@ func NewRateLimitedDbmsConn(dbmsConn Driver, rps int) (*RateLimitedDbmsConn, err
 	}, nil
 }
 
-func (conn *RateLimitedDbmsConn) CreateDb(operation Operation) OpOutput {
-	conn.limiter.Take()
-	return conn.Driver.CreateDb(operation)
+func (conn *RateLimitedDbmsConn) CreateDb(ctx context.Context, operation Operation) OpOutput {
+	conn.take(ctx)
+	return conn.Driver.CreateDb(ctx, operation)
 }
 
-func (conn *RateLimitedDbmsConn) DeleteDb(operation Operation) OpOutput {
-	conn.limiter.Take()
-	return conn.Driver.DeleteDb(operation)
+func (conn *RateLimitedDbmsConn) DeleteDb(ctx context.Context, operation Operation) OpOutput {
+	conn.take(ctx)
+	return conn.Driver.DeleteDb(ctx, operation)
+}
+
+func (conn *RateLimitedDbmsConn) Exists(ctx context.Context, operation Operation) OpOutput {
+	conn.take(ctx)
+	return conn.Driver.Exists(ctx, operation)
+}
+
+func (conn *RateLimitedDbmsConn) List(ctx context.Context, operation Operation) OpOutput {
+	conn.take(ctx)
+	return conn.Driver.List(ctx, operation)
+}
+
+func (conn *RateLimitedDbmsConn) Usage(ctx context.Context, operation Operation) OpOutput {
+	conn.take(ctx)
+	return conn.Driver.Usage(ctx, operation)
+}
+
+func (conn *RateLimitedDbmsConn) Ping(ctx context.Context) error {
+	conn.take(ctx)
+	return conn.Driver.Ping(ctx)
 }
 
-func (conn *RateLimitedDbmsConn) Ping() error {
+// take blocks until the limiter allows the next call. The wait is recorded as a span of ctx.
+func (conn *RateLimitedDbmsConn) take(ctx context.Context) {
+	_, span := tracing.Tracer().Start(ctx, "RateLimitedDbmsConn.Wait")
+	defer span.End()
 	conn.limiter.Take()
-	return conn.Driver.Ping()
 }
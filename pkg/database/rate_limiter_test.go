@@ -1,6 +1,7 @@
 package database_test
 
 import (
+	"context"
 	"github.com/bedag/kubernetes-dbaas/pkg/database"
 	. "github.com/bedag/kubernetes-dbaas/pkg/test"
 	. "github.com/onsi/ginkgo"
@@ -15,7 +16,7 @@ var _ = Describe(FormatTestDesc(Integration, "NewRateLimitedDbmsConn", Slow), fu
 	dsn, err := database.Dsn(os.Getenv("SQLSERVER_DSN")).GenSqlserver()
 	Expect(err).ToNot(HaveOccurred())
 
-	conn, err := database.NewSqlserverConn(dsn)
+	conn, err := database.NewSqlserverConn(dsn, database.ConnectionOptions{})
 	Expect(err).ToNot(HaveOccurred())
 
 	rateLimitedConn, err := database.NewRateLimitedDbmsConn(conn, 1)
@@ -40,7 +41,7 @@ var _ = Describe(FormatTestDesc(Integration, "NewRateLimitedDbmsConn", Slow), fu
 					defer func() { callTimes = append(callTimes, time.Now()) }()
 					defer wg.Done()
 
-					rateLimitedConn.CreateDb(createOperation)
+					rateLimitedConn.CreateDb(context.Background(), createOperation)
 				}(callTimes)
 			}
 			wg.Wait()
@@ -0,0 +1,92 @@
+package database
+
+import (
+	"fmt"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"strings"
+)
+
+// DefaultSecretNameTemplate is the template of the name of the Secret of a Database resource if its SecretTemplate
+// doesn't specify any.
+const DefaultSecretNameTemplate = "{{ .Metadata.name }}-credentials"
+
+// +kubebuilder:object:generate=true
+// SecretTemplate configures the Secret written for a Database resource. Only the name applies to SecretSinks other
+// than Kubernetes.
+type SecretTemplate struct {
+	// Name is a Go template rendered with the metadata and the parameters of the Database resource (see OpValues), e.g.
+	// "{{ .Metadata.name }}-{{ .Parameters.env }}". Defaults to "{{ .Metadata.name }}-credentials".
+	Name string `json:"name,omitempty"`
+	// Type is the type of the Secret, e.g. "servicebinding.io/postgresql". Defaults to "Opaque".
+	Type string `json:"type,omitempty"`
+	// Labels are added to the Secret.
+	Labels map[string]string `json:"labels,omitempty"`
+	// Annotations are added to the Secret.
+	Annotations map[string]string `json:"annotations,omitempty"`
+	// Immutable makes the Operator write an immutable Secret named <name>-<version> on each rotation instead of
+	// updating the previous one, which is deleted once the new one is written.
+	Immutable *bool `json:"immutable,omitempty"`
+}
+
+// Merge returns a copy of t whose fields are replaced by the ones set in override. Labels and annotations are merged,
+// the ones of override taking precedence. If override is nil, t is returned.
+func (t SecretTemplate) Merge(override *SecretTemplate) SecretTemplate {
+	merged := *t.DeepCopy()
+	if override == nil {
+		return merged
+	}
+	if override.Name != "" {
+		merged.Name = override.Name
+	}
+	if override.Type != "" {
+		merged.Type = override.Type
+	}
+	merged.Labels = mergeMaps(merged.Labels, override.Labels)
+	merged.Annotations = mergeMaps(merged.Annotations, override.Annotations)
+	if override.Immutable != nil {
+		immutable := *override.Immutable
+		merged.Immutable = &immutable
+	}
+	return merged
+}
+
+// IsImmutable returns true if t configures immutable Secrets.
+func (t SecretTemplate) IsImmutable() bool {
+	return t.Immutable != nil && *t.Immutable
+}
+
+// RenderName renders the name of the Secret with values. If t is immutable, the name of the Secret is suffixed with
+// version. It returns an error if the result is not a valid name for a Secret.
+func (t SecretTemplate) RenderName(values OpValues, version int) (string, error) {
+	nameTemplate := t.Name
+	if nameTemplate == "" {
+		nameTemplate = DefaultSecretNameTemplate
+	}
+	name, err := RenderGoTemplate(nameTemplate, values, ErrorOnMissingKeyOption)
+	if err != nil {
+		return "", err
+	}
+	if t.IsImmutable() {
+		name = fmt.Sprintf("%s-%d", name, version)
+	}
+	if errs := validation.IsDNS1123Subdomain(name); len(errs) > 0 {
+		return "", fmt.Errorf("invalid secret name '%s': %s", name, strings.Join(errs, ", "))
+	}
+	return name, nil
+}
+
+// mergeMaps returns the union of base and override, the values of override taking precedence. It returns nil if both
+// are empty.
+func mergeMaps(base, override map[string]string) map[string]string {
+	if len(base) == 0 && len(override) == 0 {
+		return nil
+	}
+	merged := make(map[string]string, len(base)+len(override))
+	for k, v := range base {
+		merged[k] = v
+	}
+	for k, v := range override {
+		merged[k] = v
+	}
+	return merged
+}
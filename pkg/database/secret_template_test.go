@@ -0,0 +1,68 @@
+package database_test
+
+import (
+	"github.com/bedag/kubernetes-dbaas/pkg/database"
+	. "github.com/bedag/kubernetes-dbaas/pkg/test"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe(FormatTestDesc(Unit, "SecretTemplate"), func() {
+	values := database.OpValues{
+		Metadata:   map[string]interface{}{"name": "test"},
+		Parameters: map[string]string{"env": "dev"},
+	}
+	immutable := true
+
+	Context("when rendering the name", func() {
+		It("should default to the name of the resource", func() {
+			Expect(database.SecretTemplate{}.RenderName(values, 0)).To(Equal("test-credentials"))
+		})
+		It("should render the template", func() {
+			template := database.SecretTemplate{Name: "{{ .Metadata.name }}-{{ .Parameters.env }}"}
+			Expect(template.RenderName(values, 0)).To(Equal("test-dev"))
+		})
+		It("should append the version if immutable", func() {
+			template := database.SecretTemplate{Immutable: &immutable}
+			Expect(template.RenderName(values, 2)).To(Equal("test-credentials-2"))
+		})
+		It("should return an error if a key is missing", func() {
+			template := database.SecretTemplate{Name: "{{ .Parameters.missing }}"}
+			_, err := template.RenderName(values, 0)
+			Expect(err).To(HaveOccurred())
+		})
+		It("should return an error if the name is invalid", func() {
+			template := database.SecretTemplate{Name: "Test_{{ .Metadata.name }}"}
+			_, err := template.RenderName(values, 0)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+	Context("when merging", func() {
+		base := database.SecretTemplate{
+			Name:        "base",
+			Type:        "Opaque",
+			Labels:      map[string]string{"app": "base", "team": "dbaas"},
+			Annotations: map[string]string{"a": "base"},
+		}
+
+		It("should return a copy if there is no override", func() {
+			Expect(base.Merge(nil)).To(Equal(base))
+		})
+		It("should override the fields set and merge labels and annotations", func() {
+			merged := base.Merge(&database.SecretTemplate{
+				Type:      "servicebinding.io/postgresql",
+				Labels:    map[string]string{"app": "override"},
+				Immutable: &immutable,
+			})
+			Expect(merged.Name).To(Equal("base"))
+			Expect(merged.Type).To(Equal("servicebinding.io/postgresql"))
+			Expect(merged.Labels).To(Equal(map[string]string{"app": "override", "team": "dbaas"}))
+			Expect(merged.Annotations).To(Equal(map[string]string{"a": "base"}))
+			Expect(merged.IsImmutable()).To(BeTrue())
+		})
+		It("should not modify the template", func() {
+			base.Merge(&database.SecretTemplate{Labels: map[string]string{"app": "override"}})
+			Expect(base.Labels["app"]).To(Equal("base"))
+		})
+	})
+})
@@ -1,86 +1,204 @@
 package database
 
 import (
+	"context"
 	"database/sql"
-	_ "github.com/denisenkom/go-mssqldb"
+	"errors"
+	"fmt"
+	mssql "github.com/denisenkom/go-mssqldb"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
 )
 
+// terminalSqlserverErrors are the numbers of SQL Server errors which can't be solved by retrying, see
+// https://docs.microsoft.com/en-us/sql/relational-databases/errors-events/database-engine-events-and-errors.
+var terminalSqlserverErrors = map[int32]bool{
+	102:   true, // Incorrect syntax
+	201:   true, // Procedure expects parameter which was not supplied
+	229:   true, // Permission denied on object
+	2812:  true, // Could not find stored procedure
+	8144:  true, // Procedure has too many arguments specified
+	50000: true, // User-defined error raised by the stored procedure
+}
+
 // SqlserverConn represents a connection to a SQL Server DBMS.
 type SqlserverConn struct {
 	c *sql.DB
+	// caFile is the path of the file holding the CA certificates of options, since the driver only reads them from a
+	// file.
+	caFile string
 }
 
-// NewSqlserverConn opens a new SQL Server connection from a given dsn.
-func NewSqlserverConn(dsn string) (*SqlserverConn, error) {
-	dbConn, err := sql.Open("sqlserver", dsn)
+// NewSqlserverConn opens a new SQL Server connection from a given dsn, configured by options. If options specify a TLS
+// mode, the encryption parameters of the dsn are overridden. Client certificates are not supported.
+func NewSqlserverConn(dsn string, options ConnectionOptions) (*SqlserverConn, error) {
+	conn := SqlserverConn{}
+	dsn, err := conn.applyOptions(dsn, options)
+	if err != nil {
+		conn.removeCaFile()
+		return nil, err
+	}
+	conn.c, err = sql.Open("sqlserver", dsn)
 	if err != nil {
+		conn.removeCaFile()
 		return nil, err
 	}
+	applySqlOptions(conn.c, options)
 
-	conn := SqlserverConn{dbConn}
 	return &conn, nil
 }
 
+// applyOptions returns dsn with the connection parameters of options set. dsn must be in URL format.
+func (c *SqlserverConn) applyOptions(dsn string, options ConnectionOptions) (string, error) {
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return "", err
+	}
+	query := u.Query()
+	if options.ConnectTimeout > 0 {
+		setSqlserverParam(query, "dial timeout", strconv.Itoa(int(options.ConnectTimeout.Seconds())))
+	}
+	switch options.TLSMode {
+	case TLSModeDisable:
+		setSqlserverParam(query, "encrypt", "disable")
+	case TLSModeRequire, TLSModeVerifyFull:
+		if len(options.TLS.Certificates) > 0 {
+			return "", fmt.Errorf("client certificates are not supported by the sqlserver driver")
+		}
+		setSqlserverParam(query, "encrypt", "true")
+		setSqlserverParam(query, "trustServerCertificate", strconv.FormatBool(options.TLS.InsecureSkipVerify))
+		if options.TLS.ServerName != "" {
+			setSqlserverParam(query, "hostNameInCertificate", options.TLS.ServerName)
+		}
+		if options.CA != nil {
+			caFile, err := ioutil.TempFile("", "sqlserver-ca-*.pem")
+			if err != nil {
+				return "", err
+			}
+			c.caFile = caFile.Name()
+			_, err = caFile.Write(options.CA)
+			if closeErr := caFile.Close(); err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				return "", err
+			}
+			setSqlserverParam(query, "certificate", c.caFile)
+		}
+	}
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
+
+// removeCaFile removes the file written by applyOptions, if any.
+func (c *SqlserverConn) removeCaFile() {
+	if c.caFile != "" {
+		_ = os.Remove(c.caFile)
+	}
+}
+
+// setSqlserverParam sets key to value in query. Since the driver ignores the case of keys, keys differing from key only
+// by their case are removed.
+func setSqlserverParam(query url.Values, key, value string) {
+	for k := range query {
+		if strings.EqualFold(k, key) {
+			query.Del(k)
+		}
+	}
+	query.Set(key, value)
+}
+
 // CreateDb attempts to create a new database as specified in the operation parameter. It returns an OpOutput with the
 // result of the call.
-func (c *SqlserverConn) CreateDb(operation Operation) OpOutput {
+func (c *SqlserverConn) CreateDb(ctx context.Context, operation Operation) OpOutput {
 	inputParams := getQueryInputs(operation.Inputs)
 
-	rows, err := c.c.Query(operation.Name, inputParams...)
+	rows, err := c.c.QueryContext(ctx, operation.Name, inputParams...)
 	if err != nil {
 		return OpOutput{Result: nil, Err: err}
 	}
+	defer rows.Close()
 
-	var key string
-	var value string
-	result := make(map[string]string)
-	for rows.Next() {
-		err = rows.Scan(&key, &value)
-		if err != nil {
-			return OpOutput{nil, err}
-		}
-		result[key] = value
-	}
-
-	return OpOutput{result, nil}
+	return scanKeyValueRows(rows)
 }
 
 // DeleteDb attempts to delete a database instance as specified in the operation parameter. It returns an OpOutput with the
 // result of the call.
-func (c *SqlserverConn) DeleteDb(operation Operation) OpOutput {
+func (c *SqlserverConn) DeleteDb(ctx context.Context, operation Operation) OpOutput {
 	inputParams := getQueryInputs(operation.Inputs)
 
-	_, err := c.c.Exec(operation.Name, inputParams...)
+	_, err := c.c.ExecContext(ctx, operation.Name, inputParams...)
 	if err != nil {
-		return OpOutput{nil, err}
+		return OpOutput{Err: err}
 	}
 
 	return OpOutput{}
 }
 
 // Rotate attempts to rotate the credentials of a connection.
-func (c *SqlserverConn) Rotate(operation Operation) OpOutput {
+func (c *SqlserverConn) Rotate(ctx context.Context, operation Operation) OpOutput {
 	inputParams := getQueryInputs(operation.Inputs)
 
-	rows, err := c.c.Query(operation.Name, inputParams...)
+	rows, err := c.c.QueryContext(ctx, operation.Name, inputParams...)
 	if err != nil {
 		return OpOutput{Result: nil, Err: err}
 	}
+	defer rows.Close()
 
-	var key string
-	var value string
-	result := make(map[string]string)
-	for rows.Next() {
-		err = rows.Scan(&key, &value)
-		if err != nil {
-			return OpOutput{nil, err}
-		}
-		result[key] = value
+	return scanKeyValueRows(rows)
+}
+
+// Exists checks whether the database instance specified in the operation parameter exists. It returns an OpOutput
+// with the result of the call. See OpOutput.IsExisting.
+func (c *SqlserverConn) Exists(ctx context.Context, operation Operation) OpOutput {
+	rows, err := c.c.QueryContext(ctx, operation.Name, getQueryInputs(operation.Inputs)...)
+	if err != nil {
+		return OpOutput{Err: err}
 	}
+	defer rows.Close()
+
+	return scanKeyValueRows(rows)
+}
+
+// List returns the database instances present on the endpoint. It returns an OpOutput with the result of the call.
+// See OpOutput.Instances.
+func (c *SqlserverConn) List(ctx context.Context, operation Operation) OpOutput {
+	rows, err := c.c.QueryContext(ctx, operation.Name, getQueryInputs(operation.Inputs)...)
+	if err != nil {
+		return OpOutput{Err: err}
+	}
+	defer rows.Close()
+
+	return scanKeyValueRows(rows)
+}
+
+// Usage returns the usage metrics of the database instance specified in the operation parameter. It returns an
+// OpOutput with the result of the call. See OpOutput.UsageMetrics.
+func (c *SqlserverConn) Usage(ctx context.Context, operation Operation) OpOutput {
+	rows, err := c.c.QueryContext(ctx, operation.Name, getQueryInputs(operation.Inputs)...)
+	if err != nil {
+		return OpOutput{Err: err}
+	}
+	defer rows.Close()
+
+	return scanKeyValueRows(rows)
+}
+
+func (c *SqlserverConn) Ping(ctx context.Context) error {
+	return c.c.PingContext(ctx)
+}
 
-	return OpOutput{result, nil}
+// Close closes the connections to the DBMS.
+func (c *SqlserverConn) Close() error {
+	defer c.removeCaFile()
+	return c.c.Close()
 }
 
-func (c *SqlserverConn) Ping() error {
-	return c.c.Ping()
+// isTerminalSqlserverError returns true if err is a SQL Server error whose number belongs to terminalSqlserverErrors.
+func isTerminalSqlserverError(err error) bool {
+	var mssqlErr mssql.Error
+	return errors.As(err, &mssqlErr) && terminalSqlserverErrors[mssqlErr.Number]
 }
@@ -1,6 +1,7 @@
 package database_test
 
 import (
+	"context"
 	"github.com/bedag/kubernetes-dbaas/pkg/database"
 	. "github.com/bedag/kubernetes-dbaas/pkg/test"
 	. "github.com/onsi/ginkgo"
@@ -19,7 +20,7 @@ var _ = Describe(FormatTestDesc(Integration, "Sqlserver CreateDb"), func() {
 	dsn, err := database.Dsn(os.Getenv("SQLSERVER_DSN")).GenSqlserver()
 	Expect(err).ToNot(HaveOccurred())
 
-	conn, err := database.NewSqlserverConn(dsn)
+	conn, err := database.NewSqlserverConn(dsn, database.ConnectionOptions{})
 	Expect(err).ToNot(HaveOccurred())
 
 	BeforeEach(func() {
@@ -45,7 +46,7 @@ var _ = Describe(FormatTestDesc(Integration, "Sqlserver CreateDb"), func() {
 			Err: nil,
 		}
 
-		result = conn.CreateDb(createOperation)
+		result = conn.CreateDb(context.Background(), createOperation)
 	})
 	Context("when Operation is defined correctly", func() {
 		It("should not return an error", func() {
@@ -0,0 +1,105 @@
+package database_test
+
+import (
+	"context"
+	"github.com/bedag/kubernetes-dbaas/pkg/database"
+	. "github.com/bedag/kubernetes-dbaas/pkg/test"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"net/url"
+)
+
+var _ = Describe(FormatTestDesc(Unit, "TemplateFuncs"), func() {
+	output := database.OpOutput{Result: map[string]string{
+		"username": "user",
+		"password": "P@ss;w'rd\"}/&",
+		"port":     "5432",
+		"dbName":   "db",
+		"fqdn":     "db.example.com",
+	}}
+	render := func(text string) string {
+		rendered, err := database.RenderGoTemplate(text, output, database.ErrorOnMissingKeyOption)
+		Expect(err).NotTo(HaveOccurred())
+		return rendered
+	}
+
+	Context("when building URL DSNs", func() {
+		It("should escape the password", func() {
+			dsn := render(`{{ urlDsn "postgres" .Result }}`)
+			u, err := url.Parse(dsn)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(u.Scheme).To(Equal("postgres"))
+			Expect(u.User.Password()).To(Equal("P@ss;w'rd\"}/&"))
+			Expect(u.Host).To(Equal("db.example.com:5432"))
+			Expect(u.Path).To(Equal("/db"))
+		})
+		It("should pass the database of SQL Server as query parameter", func() {
+			u, err := url.Parse(render(`{{ urlDsn "sqlserver" .Result }}`))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(u.Query().Get("database")).To(Equal("db"))
+		})
+		It("should accept values built with dict", func() {
+			Expect(render(`{{ urlDsn "mysql" (dict "host" "localhost" "port" 3306 "database" "db") }}`)).
+				To(Equal("mysql://localhost:3306/db"))
+		})
+		It("should return an error if the host is missing", func() {
+			_, err := database.UrlDsn(database.Postgres, map[string]string{"username": "user"})
+			Expect(err).To(HaveOccurred())
+		})
+		It("should return an error if the driver is unknown", func() {
+			_, err := database.UrlDsn("oracle", output.Result)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+	Context("when building JDBC DSNs", func() {
+		It("should pass the credentials of PostgreSQL as escaped query parameters", func() {
+			Expect(render(`{{ jdbcDsn "postgres" .Result }}`)).To(Equal(
+				"jdbc:postgresql://db.example.com:5432/db?password=P%40ss%3Bw%27rd%22%7D%2F%26&user=user"))
+		})
+		It("should enclose special values of SQL Server in braces", func() {
+			Expect(render(`{{ jdbcDsn "sqlserver" .Result }}`)).To(Equal(
+				"jdbc:sqlserver://db.example.com:5432;databaseName=db;user=user;password={P@ss;w'rd\"}}/&}"))
+		})
+		It("should use the MariaDB prefix for MariaDB", func() {
+			Expect(render(`{{ jdbcDsn "mariadb" .Result }}`)).To(HavePrefix("jdbc:mariadb://db.example.com:5432/db?"))
+		})
+	})
+	Context("when building ADO.NET DSNs", func() {
+		It("should quote special values", func() {
+			Expect(render(`{{ adoDsn "postgres" .Result }}`)).To(Equal(
+				`Host=db.example.com;Port=5432;Database=db;Username=user;Password="P@ss;w'rd""}/&"`))
+		})
+		It("should pass the port of SQL Server with the server", func() {
+			Expect(render(`{{ adoDsn "sqlserver" .Result }}`)).To(HavePrefix("Server=db.example.com,5432;Database=db;"))
+		})
+	})
+	Context("when building libpq DSNs", func() {
+		It("should quote and escape special values", func() {
+			Expect(render(`{{ libpqDsn "postgres" .Result }}`)).To(Equal(
+				`host=db.example.com port=5432 dbname=db user=user password='P@ss;w\'rd"}/&'`))
+		})
+		It("should return an error for other drivers", func() {
+			_, err := database.LibpqDsn(database.Mysql, output.Result)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+	Context("when using string, encoding and hashing helpers", func() {
+		It("should render them", func() {
+			Expect(render(`{{ .Result.username | upper | b64enc }}`)).To(Equal("VVNFUg=="))
+			Expect(render(`{{ "VVNFUg==" | b64dec | lower }}`)).To(Equal("user"))
+			Expect(render(`{{ trunc 2 .Result.fqdn }}`)).To(Equal("db"))
+			Expect(render(`{{ "a,b" | split "," | join "-" }}`)).To(Equal("a-b"))
+			Expect(render(`{{ default "none" "" }}`)).To(Equal("none"))
+			Expect(render(`{{ sha256sum "a" }}`)).To(Equal("ca978112ca1bbdcafac231b39a23dc4da786eff8147c4e72b9807785afee48bb"))
+		})
+	})
+	Context("when rendering a SecretFormat", func() {
+		It("should support quotes in values", func() {
+			format := database.SecretFormat{"dsn": `{{ urlDsn "postgres" .Result }}`, "quoted": `{{ quote .Result.username }}`}
+			rendered, err := format.RenderSecretFormat(context.Background(), database.OpValues{}.WithOutput(output))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(rendered["dsn"]).To(HavePrefix("postgres://user:"))
+			Expect(rendered["quoted"]).To(Equal(`"user"`))
+		})
+	})
+})
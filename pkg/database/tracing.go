@@ -0,0 +1,69 @@
+package database
+
+import (
+	"context"
+	"github.com/bedag/kubernetes-dbaas/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TracedDbmsConn is a Driver recording each call to the wrapped Driver as a span, with the endpoint, the DatabaseClass
+// and the operation as attributes.
+type TracedDbmsConn struct {
+	Driver
+	attributes []attribute.KeyValue
+}
+
+// NewTracedDbmsConn returns a TracedDbmsConn wrapping dbmsConn, whose spans are labeled with endpoint and dbClassName.
+func NewTracedDbmsConn(dbmsConn Driver, endpoint, dbClassName string) *TracedDbmsConn {
+	return &TracedDbmsConn{
+		Driver: dbmsConn,
+		attributes: []attribute.KeyValue{
+			tracing.EndpointKey.String(endpoint),
+			tracing.DatabaseClassKey.String(dbClassName),
+		},
+	}
+}
+
+func (conn *TracedDbmsConn) CreateDb(ctx context.Context, operation Operation) OpOutput {
+	return conn.trace(ctx, CreateMapKey, operation, conn.Driver.CreateDb)
+}
+
+func (conn *TracedDbmsConn) DeleteDb(ctx context.Context, operation Operation) OpOutput {
+	return conn.trace(ctx, DeleteMapKey, operation, conn.Driver.DeleteDb)
+}
+
+func (conn *TracedDbmsConn) Rotate(ctx context.Context, operation Operation) OpOutput {
+	return conn.trace(ctx, RotateMapKey, operation, conn.Driver.Rotate)
+}
+
+func (conn *TracedDbmsConn) Exists(ctx context.Context, operation Operation) OpOutput {
+	return conn.trace(ctx, ExistsMapKey, operation, conn.Driver.Exists)
+}
+
+func (conn *TracedDbmsConn) List(ctx context.Context, operation Operation) OpOutput {
+	return conn.trace(ctx, ListMapKey, operation, conn.Driver.List)
+}
+
+func (conn *TracedDbmsConn) Usage(ctx context.Context, operation Operation) OpOutput {
+	return conn.trace(ctx, UsageMapKey, operation, conn.Driver.Usage)
+}
+
+func (conn *TracedDbmsConn) Ping(ctx context.Context) error {
+	ctx, span := tracing.Tracer().Start(ctx, "Driver.Ping", trace.WithAttributes(conn.attributes...),
+		trace.WithSpanKind(trace.SpanKindClient))
+	defer span.End()
+	err := conn.Driver.Ping(ctx)
+	tracing.RecordError(ctx, span, err)
+	return err
+}
+
+// trace calls execute with operation in a span named after opName.
+func (conn *TracedDbmsConn) trace(ctx context.Context, opName string, operation Operation, execute func(context.Context, Operation) OpOutput) OpOutput {
+	ctx, span := tracing.Tracer().Start(ctx, "Driver."+opName, trace.WithAttributes(conn.attributes...),
+		trace.WithAttributes(tracing.OperationKey.String(opName)), trace.WithSpanKind(trace.SpanKindClient))
+	defer span.End()
+	output := execute(ctx, operation)
+	tracing.RecordError(ctx, span, output.Err)
+	return output
+}
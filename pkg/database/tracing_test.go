@@ -0,0 +1,85 @@
+package database_test
+
+import (
+	"context"
+	"errors"
+	"github.com/bedag/kubernetes-dbaas/internal/tracing"
+	"github.com/bedag/kubernetes-dbaas/pkg/database"
+	. "github.com/bedag/kubernetes-dbaas/pkg/test"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracedFakeDriver is a database.Driver whose operations return err.
+type tracedFakeDriver struct {
+	database.Driver
+	err error
+}
+
+func (d tracedFakeDriver) CreateDb(context.Context, database.Operation) database.OpOutput {
+	return database.OpOutput{Err: d.err}
+}
+
+var _ = Describe(FormatTestDesc(Unit, "Tracing"), func() {
+	var exporter *tracetest.InMemoryExporter
+	var provider *sdktrace.TracerProvider
+	ctx := context.Background()
+
+	// spanByName flushes the recorded spans and returns the one named name.
+	spanByName := func(name string) tracetest.SpanStub {
+		Expect(provider.ForceFlush(ctx)).To(Succeed())
+		for _, span := range exporter.GetSpans() {
+			if span.Name == name {
+				return span
+			}
+		}
+		Fail("span not found: " + name)
+		return tracetest.SpanStub{}
+	}
+
+	BeforeEach(func() {
+		exporter = tracetest.NewInMemoryExporter()
+		provider = tracing.NewTracerProvider(exporter, sdktrace.AlwaysSample())
+		otel.SetTracerProvider(provider)
+	})
+	AfterEach(func() {
+		otel.SetTracerProvider(trace.NewNoopTracerProvider())
+	})
+	It("should record the rendering of an operation", func() {
+		_, err := database.Operation{Name: "sp_create", Inputs: map[string]string{"name": "{{ .Metadata.name }}"}}.
+			RenderOperation(ctx, database.OpValues{})
+		Expect(err).To(HaveOccurred())
+		span := spanByName("Operation.RenderOperation")
+		Expect(span.Attributes).To(ContainElement(tracing.OperationKey.String("sp_create")))
+		Expect(span.Status.Code).To(Equal(codes.Error))
+	})
+	It("should record the rendering of a secret format", func() {
+		_, err := database.SecretFormat{"username": "{{ .Result.username }}"}.RenderSecretFormat(ctx,
+			database.OpValues{}.WithOutput(database.OpOutput{Result: map[string]string{"username": "user"}}))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(spanByName("SecretFormat.RenderSecretFormat").Status.Code).ToNot(Equal(codes.Error))
+	})
+	It("should record driver calls with endpoint, class and operation as children of the caller", func() {
+		parentCtx, parent := otel.Tracer("test").Start(ctx, "parent")
+		conn := database.NewTracedDbmsConn(tracedFakeDriver{err: errors.New("down")}, "ep", "dbc")
+		rateLimitedConn, err := database.NewRateLimitedDbmsConn(conn, 0)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(rateLimitedConn.CreateDb(parentCtx, database.Operation{}).Err).To(HaveOccurred())
+		parent.End()
+
+		span := spanByName("Driver." + database.CreateMapKey)
+		Expect(span.Parent.SpanID()).To(Equal(parent.SpanContext().SpanID()))
+		Expect(span.Attributes).To(ContainElements(
+			tracing.EndpointKey.String("ep"),
+			tracing.DatabaseClassKey.String("dbc"),
+			tracing.OperationKey.String(database.CreateMapKey),
+		))
+		Expect(span.Status.Code).To(Equal(codes.Error))
+		Expect(spanByName("RateLimitedDbmsConn.Wait").Parent.SpanID()).To(Equal(parent.SpanContext().SpanID()))
+	})
+})
@@ -0,0 +1,83 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/jackc/pgx/v4"
+	"time"
+)
+
+const (
+	// verificationQuery is the trivial query executed by VerifyCredentials once a connection has been established.
+	verificationQuery = "SELECT 1"
+	// verificationTimeout is the maximum duration of a verification, so that an unreachable endpoint doesn't block
+	// the caller indefinitely.
+	verificationTimeout = 30 * time.Second
+)
+
+// VerifyCredentials opens a new connection using driver and dsn, executes a trivial query and closes the connection
+// again. It is used to check that the credentials returned by an operation actually work before handing them over to
+// end-users. It returns nil if the query was executed successfully, an error otherwise. The verification is aborted
+// when ctx is done or after verificationTimeout, whichever comes first.
+//
+// Note that errors returned by VerifyCredentials never contain the dsn, as it is likely to contain a password.
+func VerifyCredentials(ctx context.Context, driver string, dsn Dsn) error {
+	ctx, cancel := context.WithTimeout(ctx, verificationTimeout)
+	defer cancel()
+	return dsn.Redact(verifyCredentials(ctx, driver, dsn))
+}
+
+// verifyCredentials implements VerifyCredentials.
+func verifyCredentials(ctx context.Context, driver string, dsn Dsn) error {
+	switch driver {
+	case Sqlserver:
+		parsedDsn, err := dsn.GenSqlserver()
+		if err != nil {
+			return formatDsnParseError(err)
+		}
+		return verifySqlConn(ctx, "sqlserver", parsedDsn)
+	case Postgres:
+		parsedDsn, err := dsn.GenPostgres()
+		if err != nil {
+			return formatDsnParseError(err)
+		}
+		return verifyPsqlConn(ctx, parsedDsn)
+	case Mysql, Mariadb:
+		parsedDsn, err := dsn.GenMysql()
+		if err != nil {
+			return formatDsnParseError(err)
+		}
+		return verifySqlConn(ctx, "mysql", parsedDsn)
+	default:
+		return fmt.Errorf("invalid driver '%s': driver not found", driver)
+	}
+}
+
+// verifySqlConn verifies a connection opened through the database/sql package with the given driverName.
+func verifySqlConn(ctx context.Context, driverName, dsn string) error {
+	conn, err := sql.Open(driverName, dsn)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if err := conn.PingContext(ctx); err != nil {
+		return err
+	}
+	var result int
+	return conn.QueryRowContext(ctx, verificationQuery).Scan(&result)
+}
+
+// verifyPsqlConn verifies a single PostgreSQL connection. A connection pool is not needed as the connection is closed
+// right after the verification query.
+func verifyPsqlConn(ctx context.Context, dsn string) error {
+	conn, err := pgx.Connect(ctx, dsn)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(ctx)
+
+	var result int
+	return conn.QueryRow(ctx, verificationQuery).Scan(&result)
+}
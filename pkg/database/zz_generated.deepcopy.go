@@ -22,13 +22,31 @@ package database
 
 import ()
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ConnectionConfig) DeepCopyInto(out *ConnectionConfig) {
+	*out = *in
+	in.TLS.DeepCopyInto(&out.TLS)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ConnectionConfig.
+func (in *ConnectionConfig) DeepCopy() *ConnectionConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(ConnectionConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Dbms) DeepCopyInto(out *Dbms) {
 	*out = *in
 	if in.Endpoints != nil {
 		in, out := &in.Endpoints, &out.Endpoints
 		*out = make([]Endpoint, len(*in))
-		copy(*out, *in)
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
 	}
 }
 
@@ -67,6 +85,12 @@ func (in DbmsList) DeepCopy() DbmsList {
 func (in *Endpoint) DeepCopyInto(out *Endpoint) {
 	*out = *in
 	out.SecretKeyRef = in.SecretKeyRef
+	if in.VaultKeyRef != nil {
+		in, out := &in.VaultKeyRef, &out.VaultKeyRef
+		*out = new(VaultKeyRef)
+		(*in).DeepCopyInto(*out)
+	}
+	in.Connection.DeepCopyInto(&out.Connection)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Endpoint.
@@ -89,6 +113,13 @@ func (in *Operation) DeepCopyInto(out *Operation) {
 			(*out)[key] = val
 		}
 	}
+	if in.Outputs != nil {
+		in, out := &in.Outputs, &out.Outputs
+		*out = make([]OutputKey, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Operation.
@@ -101,6 +132,46 @@ func (in *Operation) DeepCopy() *Operation {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OutputKey) DeepCopyInto(out *OutputKey) {
+	*out = *in
+	if in.Sensitive != nil {
+		in, out := &in.Sensitive, &out.Sensitive
+		*out = new(bool)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OutputKey.
+func (in *OutputKey) DeepCopy() *OutputKey {
+	if in == nil {
+		return nil
+	}
+	out := new(OutputKey)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PasswordPolicy) DeepCopyInto(out *PasswordPolicy) {
+	*out = *in
+	if in.AllowedCharsets != nil {
+		in, out := &in.AllowedCharsets, &out.AllowedCharsets
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PasswordPolicy.
+func (in *PasswordPolicy) DeepCopy() *PasswordPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(PasswordPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in SecretFormat) DeepCopyInto(out *SecretFormat) {
 	{
@@ -121,3 +192,87 @@ func (in SecretFormat) DeepCopy() SecretFormat {
 	in.DeepCopyInto(out)
 	return *out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SecretTemplate) DeepCopyInto(out *SecretTemplate) {
+	*out = *in
+	if in.Labels != nil {
+		in, out := &in.Labels, &out.Labels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Annotations != nil {
+		in, out := &in.Annotations, &out.Annotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Immutable != nil {
+		in, out := &in.Immutable, &out.Immutable
+		*out = new(bool)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SecretTemplate.
+func (in *SecretTemplate) DeepCopy() *SecretTemplate {
+	if in == nil {
+		return nil
+	}
+	out := new(SecretTemplate)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TLSConfig) DeepCopyInto(out *TLSConfig) {
+	*out = *in
+	if in.CASecretKeyRef != nil {
+		in, out := &in.CASecretKeyRef, &out.CASecretKeyRef
+		*out = new(SecretKeyRef)
+		**out = **in
+	}
+	if in.CertSecretKeyRef != nil {
+		in, out := &in.CertSecretKeyRef, &out.CertSecretKeyRef
+		*out = new(SecretKeyRef)
+		**out = **in
+	}
+	if in.KeySecretKeyRef != nil {
+		in, out := &in.KeySecretKeyRef, &out.KeySecretKeyRef
+		*out = new(SecretKeyRef)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TLSConfig.
+func (in *TLSConfig) DeepCopy() *TLSConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(TLSConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VaultKeyRef) DeepCopyInto(out *VaultKeyRef) {
+	*out = *in
+	if in.TokenSecretKeyRef != nil {
+		in, out := &in.TokenSecretKeyRef, &out.TokenSecretKeyRef
+		*out = new(SecretKeyRef)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VaultKeyRef.
+func (in *VaultKeyRef) DeepCopy() *VaultKeyRef {
+	if in == nil {
+		return nil
+	}
+	out := new(VaultKeyRef)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -0,0 +1,146 @@
+// Package dsnprovider resolves the DSNs of DBMS endpoints from the sources they are configured with, see
+// database.Endpoint, and watches these sources for changes.
+//
+// Errors returned by providers never contain the DSN they resolve, so that they can be logged safely.
+package dsnprovider
+
+import (
+	"context"
+	"fmt"
+	"github.com/bedag/kubernetes-dbaas/pkg/database"
+	"github.com/bedag/kubernetes-dbaas/pkg/vault"
+	"io/ioutil"
+	corev1 "k8s.io/api/core/v1"
+	"os"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"strings"
+)
+
+// DsnProvider resolves the DSN of an endpoint.
+type DsnProvider interface {
+	// Dsn returns the current DSN.
+	Dsn(ctx context.Context) (database.Dsn, error)
+}
+
+// Static is a DsnProvider returning a DSN specified in plaintext.
+type Static database.Dsn
+
+func (p Static) Dsn(context.Context) (database.Dsn, error) {
+	return database.Dsn(p), nil
+}
+
+// Secret is a DsnProvider reading the DSN from a key of a Secret.
+type Secret struct {
+	Reader client.Reader
+	// Ref references the key of the Secret. If Ref.Namespace is empty, the Secret is read from DefaultNamespace.
+	Ref              database.SecretKeyRef
+	DefaultNamespace string
+}
+
+func (p Secret) Dsn(ctx context.Context) (database.Dsn, error) {
+	value, err := ReadSecret(ctx, p.Reader, p.Ref, p.DefaultNamespace)
+	return database.Dsn(value), err
+}
+
+// Env is a DsnProvider reading the DSN from the environment variable it is named after.
+type Env string
+
+func (p Env) Dsn(context.Context) (database.Dsn, error) {
+	value, set := os.LookupEnv(string(p))
+	if !set {
+		return "", fmt.Errorf("environment variable '%s' not set", string(p))
+	}
+	return database.Dsn(value), nil
+}
+
+// File is a DsnProvider reading the DSN from the file at the path it is named after, e.g. a key of a mounted Secret.
+// Leading and trailing white space is ignored.
+type File string
+
+func (p File) Dsn(context.Context) (database.Dsn, error) {
+	value, err := ioutil.ReadFile(string(p))
+	if err != nil {
+		// The error of ReadFile only contains the path
+		return "", err
+	}
+	return database.Dsn(strings.TrimSpace(string(value))), nil
+}
+
+// Vault is a DsnProvider reading the DSN from a secret of a Vault KV version 2 secrets engine.
+type Vault struct {
+	Reader           client.Reader
+	Ref              database.VaultKeyRef
+	DefaultNamespace string
+}
+
+func (p Vault) Dsn(ctx context.Context) (database.Dsn, error) {
+	// The token is read on each call, so that it can be renewed
+	var token string
+	if p.Ref.TokenSecretKeyRef != nil {
+		value, err := ReadSecret(ctx, p.Reader, *p.Ref.TokenSecretKeyRef, p.DefaultNamespace)
+		if err != nil {
+			return "", fmt.Errorf("unable to read vault token: %s", err)
+		}
+		token = strings.TrimSpace(string(value))
+	}
+	vaultClient, err := vault.NewClient(p.Ref.Address, token)
+	if err != nil {
+		return "", err
+	}
+	data, err := vaultClient.Read(ctx, p.Ref.Mount, p.Ref.Path)
+	if err != nil {
+		return "", fmt.Errorf("unable to read vault secret '%s': %s", p.Ref.Path, err)
+	}
+	value, exists := data[p.Ref.Key]
+	if !exists {
+		return "", fmt.Errorf("key '%s' not found in vault secret '%s'", p.Ref.Key, p.Ref.Path)
+	}
+	return database.Dsn(value), nil
+}
+
+// ForEndpoint returns the DsnProvider of endpoint. If endpoint specifies more than one source, the first of Dsn,
+// SecretKeyRef, Env, File and VaultKeyRef takes precedence. Secrets are read through reader, from defaultNamespace
+// unless specified otherwise. It returns an error if endpoint doesn't specify any source.
+func ForEndpoint(endpoint database.Endpoint, reader client.Reader, defaultNamespace string) (DsnProvider, error) {
+	var providers []DsnProvider
+	if endpoint.Dsn != "" {
+		providers = append(providers, Static(endpoint.Dsn))
+	}
+	if endpoint.SecretKeyRef.Name != "" || endpoint.SecretKeyRef.Key != "" {
+		if endpoint.SecretKeyRef.Name == "" || endpoint.SecretKeyRef.Key == "" {
+			return nil, fmt.Errorf("both name and key of secretKeyRef must be specified for endpoint '%s'", endpoint.Name)
+		}
+		providers = append(providers, Secret{Reader: reader, Ref: endpoint.SecretKeyRef, DefaultNamespace: defaultNamespace})
+	}
+	if endpoint.Env != "" {
+		providers = append(providers, Env(endpoint.Env))
+	}
+	if endpoint.File != "" {
+		providers = append(providers, File(endpoint.File))
+	}
+	if endpoint.VaultKeyRef != nil {
+		providers = append(providers, Vault{Reader: reader, Ref: *endpoint.VaultKeyRef, DefaultNamespace: defaultNamespace})
+	}
+	if len(providers) == 0 {
+		return nil, fmt.Errorf("no DSN specified for endpoint '%s'", endpoint.Name)
+	}
+	return providers[0], nil
+}
+
+// ReadSecret returns the value referenced by ref, read through reader. If ref.Namespace is empty, the Secret is read
+// from defaultNamespace.
+func ReadSecret(ctx context.Context, reader client.Reader, ref database.SecretKeyRef, defaultNamespace string) ([]byte, error) {
+	ns := ref.Namespace
+	if ns == "" {
+		ns = defaultNamespace
+	}
+	secret := corev1.Secret{}
+	if err := reader.Get(ctx, client.ObjectKey{Namespace: ns, Name: ref.Name}, &secret); err != nil {
+		return nil, fmt.Errorf("unable to read key '%s' from secret '%s/%s': %s", ref.Key, ns, ref.Name, err)
+	}
+	value, exists := secret.Data[ref.Key]
+	if !exists {
+		return nil, fmt.Errorf("key '%s' not found in secret '%s/%s'", ref.Key, ns, ref.Name)
+	}
+	return value, nil
+}
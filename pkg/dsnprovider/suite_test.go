@@ -0,0 +1,12 @@
+package dsnprovider_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"testing"
+)
+
+func TestDsnProvider(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "DsnProvider package suite")
+}
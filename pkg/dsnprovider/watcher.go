@@ -0,0 +1,78 @@
+package dsnprovider
+
+import (
+	"context"
+	"github.com/bedag/kubernetes-dbaas/pkg/database"
+	"github.com/go-logr/logr"
+	"time"
+)
+
+// ChangeFunc is called by a Watcher when the DSN of the endpoint identified by name changed.
+type ChangeFunc func(ctx context.Context, name string, dsn database.Dsn) error
+
+// Watcher resolves the DSNs of endpoints periodically and calls a ChangeFunc for each DSN which changed. It implements
+// manager.Runnable.
+type Watcher struct {
+	providers map[string]DsnProvider
+	dsns      map[string]database.Dsn
+	interval  time.Duration
+	onChange  ChangeFunc
+	log       logr.Logger
+}
+
+// NewWatcher returns a Watcher resolving every interval the DSNs of the endpoints whose providers are indexed by name
+// in providers. dsns are the DSNs currently in use, indexed by endpoint name.
+func NewWatcher(providers map[string]DsnProvider, dsns map[string]database.Dsn, interval time.Duration, onChange ChangeFunc, log logr.Logger) *Watcher {
+	current := make(map[string]database.Dsn, len(dsns))
+	for name, dsn := range dsns {
+		current[name] = dsn
+	}
+	return &Watcher{
+		providers: providers,
+		dsns:      current,
+		interval:  interval,
+		onChange:  onChange,
+		log:       log,
+	}
+}
+
+// Start resolves the DSNs every interval until ctx is done.
+func (w *Watcher) Start(ctx context.Context) error {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			w.Resolve(ctx)
+		}
+	}
+}
+
+// NeedLeaderElection returns false, since each replica of the Operator holds its own connections.
+func (w *Watcher) NeedLeaderElection() bool {
+	return false
+}
+
+// Resolve resolves the DSN of each endpoint and calls the ChangeFunc of w for each DSN which changed. If the ChangeFunc
+// fails, it is called again at the next resolution.
+func (w *Watcher) Resolve(ctx context.Context) {
+	for name, provider := range w.providers {
+		logger := w.log.WithValues("endpoint", name)
+		dsn, err := provider.Dsn(ctx)
+		if err != nil {
+			logger.Error(err, "unable to resolve DSN")
+			continue
+		}
+		if dsn == w.dsns[name] {
+			continue
+		}
+		logger.Info("DSN changed, reconnecting")
+		if err := w.onChange(ctx, name, dsn); err != nil {
+			logger.Error(dsn.Redact(err), "unable to reconnect with new DSN")
+			continue
+		}
+		w.dsns[name] = dsn
+	}
+}
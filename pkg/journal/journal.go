@@ -0,0 +1,279 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package journal records the operations executed on DBMS endpoints, so that their outputs survive a crash of the
+// Operator between the execution of an operation and the moment its outputs are handed over, e.g. written into the
+// credentials Secret.
+//
+// Each resource has at most one entry, stored in a temporary Secret owned by the resource. Outputs are encrypted with
+// AES-GCM using a key which must be stable across restarts of the Operator, see LoadOrCreateKey.
+package journal
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io"
+	corev1 "k8s.io/api/core/v1"
+	k8sError "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// KeySize is the size in bytes of the encryption key of a Journal.
+	KeySize = 32
+	// KeySecretKey is the key of the encryption key in the Secret managed by LoadOrCreateKey.
+	KeySecretKey = "key"
+
+	operationAnnotationKey = "dbaas.bedag.ch/journal-operation"
+	phaseAnnotationKey     = "dbaas.bedag.ch/journal-phase"
+	outputKey              = "output"
+	seedKey                = "seed"
+	// seedSize is the size in bytes of the seeds returned by SeedFor.
+	seedSize = 32
+)
+
+// Phase is the phase of an operation recorded in a Journal.
+type Phase string
+
+const (
+	// PhaseIntent means that the operation is about to be executed. Its outcome is unknown.
+	PhaseIntent Phase = "Intent"
+	// PhaseExecuted means that the operation was executed successfully and its output was recorded.
+	PhaseExecuted Phase = "Executed"
+	// PhaseFailed means that the output of the operation was rejected, e.g. because the returned credentials could not
+	// be verified. The operation must be executed again rather than resumed.
+	PhaseFailed Phase = "Failed"
+)
+
+// Entry is the record of an operation.
+type Entry struct {
+	// Operation is the name of the operation, e.g. database.CreateMapKey.
+	Operation string
+	// Phase is the phase of the operation.
+	Phase Phase
+	// Output is the output of the operation. It is only set if Phase is PhaseExecuted.
+	Output map[string]string
+	// Seed is the secret random value values generated for the operation are derived from, see database.Generator. It
+	// is kept across retries of the operation, so that they pass the same generated values.
+	Seed []byte
+}
+
+// SeedFor returns the seed of e if e records operation, a new random seed otherwise.
+func (e *Entry) SeedFor(operation string) ([]byte, error) {
+	if e != nil && e.Operation == operation && len(e.Seed) > 0 {
+		return e.Seed, nil
+	}
+	seed := make([]byte, seedSize)
+	if _, err := io.ReadFull(rand.Reader, seed); err != nil {
+		return nil, err
+	}
+	return seed, nil
+}
+
+// IsExecuted returns true if e records the successful execution of operation.
+func (e *Entry) IsExecuted(operation string) bool {
+	return e != nil && e.Operation == operation && e.Phase == PhaseExecuted
+}
+
+// Journal stores entries in Secrets. A nil Journal doesn't record anything.
+type Journal struct {
+	client client.Client
+	aead   cipher.AEAD
+}
+
+// New returns a Journal storing its entries through c and encrypting them with key, which must be KeySize bytes long.
+func New(c client.Client, key []byte) (*Journal, error) {
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("journal key must be %d bytes long, got %d", KeySize, len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &Journal{client: c, aead: aead}, nil
+}
+
+// LoadOrCreateKey returns the encryption key stored in the Secret identified by key. If the Secret doesn't exist, it
+// is created with a random key.
+func LoadOrCreateKey(ctx context.Context, c client.Client, key client.ObjectKey) ([]byte, error) {
+	secret := corev1.Secret{}
+	err := c.Get(ctx, key, &secret)
+	if err == nil {
+		return secret.Data[KeySecretKey], nil
+	}
+	if !k8sError.IsNotFound(err) {
+		return nil, err
+	}
+	encryptionKey := make([]byte, KeySize)
+	if _, err := io.ReadFull(rand.Reader, encryptionKey); err != nil {
+		return nil, err
+	}
+	secret = corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      key.Name,
+			Namespace: key.Namespace,
+		},
+		Data: map[string][]byte{KeySecretKey: encryptionKey},
+	}
+	if err := c.Create(ctx, &secret); err != nil {
+		if k8sError.IsAlreadyExists(err) {
+			// Another replica created it in the meantime
+			return LoadOrCreateKey(ctx, c, key)
+		}
+		return nil, err
+	}
+	return encryptionKey, nil
+}
+
+// SecretName returns the name of the Secret storing the entry of the resource named ownerName.
+func SecretName(ownerName string) string {
+	return ownerName + "-journal"
+}
+
+// Get returns the entry of owner, nil if there is none.
+func (j *Journal) Get(ctx context.Context, owner client.Object) (*Entry, error) {
+	if j == nil {
+		return nil, nil
+	}
+	secret := corev1.Secret{}
+	if err := j.client.Get(ctx, secretKey(owner), &secret); err != nil {
+		if k8sError.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	entry := &Entry{
+		Operation: secret.Annotations[operationAnnotationKey],
+		Phase:     Phase(secret.Annotations[phaseAnnotationKey]),
+	}
+	if ciphertext, exists := secret.Data[outputKey]; exists {
+		plaintext, err := j.decrypt(ciphertext)
+		if err != nil {
+			return nil, fmt.Errorf("unable to decrypt journal entry: %s", err)
+		}
+		output := map[string]string{}
+		if err := json.Unmarshal(plaintext, &output); err != nil {
+			return nil, fmt.Errorf("unable to parse journal entry: %s", err)
+		}
+		entry.Output = output
+	}
+	if ciphertext, exists := secret.Data[seedKey]; exists {
+		seed, err := j.decrypt(ciphertext)
+		if err != nil {
+			return nil, fmt.Errorf("unable to decrypt journal seed: %s", err)
+		}
+		entry.Seed = seed
+	}
+	return entry, nil
+}
+
+// Record stores entry as the entry of owner, replacing the previous one. The Secret storing it is owned by ownerRef,
+// so that it is garbage collected together with owner.
+func (j *Journal) Record(ctx context.Context, owner client.Object, ownerRef metav1.OwnerReference, entry Entry) error {
+	if j == nil {
+		return nil
+	}
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            SecretName(owner.GetName()),
+			Namespace:       owner.GetNamespace(),
+			OwnerReferences: []metav1.OwnerReference{ownerRef},
+			Annotations: map[string]string{
+				operationAnnotationKey: entry.Operation,
+				phaseAnnotationKey:     string(entry.Phase),
+			},
+		},
+		Data: map[string][]byte{},
+	}
+	if entry.Output != nil {
+		plaintext, err := json.Marshal(entry.Output)
+		if err != nil {
+			return err
+		}
+		ciphertext, err := j.encrypt(plaintext)
+		if err != nil {
+			return err
+		}
+		secret.Data[outputKey] = ciphertext
+	}
+	if entry.Seed != nil {
+		ciphertext, err := j.encrypt(entry.Seed)
+		if err != nil {
+			return err
+		}
+		secret.Data[seedKey] = ciphertext
+	}
+
+	oldSecret := corev1.Secret{}
+	if err := j.client.Get(ctx, secretKey(owner), &oldSecret); err != nil {
+		if k8sError.IsNotFound(err) {
+			return j.client.Create(ctx, secret)
+		}
+		return err
+	}
+	secret.ResourceVersion = oldSecret.ResourceVersion
+	return j.client.Update(ctx, secret)
+}
+
+// Complete removes the entry of owner, if any. It must be called once the output of the operation has been handed
+// over, otherwise the next execution of the same operation would resume from a stale entry.
+func (j *Journal) Complete(ctx context.Context, owner client.Object) error {
+	if j == nil {
+		return nil
+	}
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      SecretName(owner.GetName()),
+			Namespace: owner.GetNamespace(),
+		},
+	}
+	if err := j.client.Delete(ctx, secret); err != nil && !k8sError.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+// encrypt returns plaintext encrypted, prefixed with the nonce used.
+func (j *Journal) encrypt(plaintext []byte) ([]byte, error) {
+	nonce := make([]byte, j.aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return j.aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+// decrypt reverses encrypt.
+func (j *Journal) decrypt(ciphertext []byte) ([]byte, error) {
+	nonceSize := j.aead.NonceSize()
+	if len(ciphertext) < nonceSize {
+		return nil, fmt.Errorf("ciphertext too short")
+	}
+	return j.aead.Open(nil, ciphertext[:nonceSize], ciphertext[nonceSize:], nil)
+}
+
+// secretKey returns the key of the Secret storing the entry of owner.
+func secretKey(owner client.Object) client.ObjectKey {
+	return client.ObjectKey{Namespace: owner.GetNamespace(), Name: SecretName(owner.GetName())}
+}
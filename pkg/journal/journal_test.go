@@ -0,0 +1,100 @@
+package journal_test
+
+import (
+	"context"
+	"github.com/bedag/kubernetes-dbaas/pkg/journal"
+	. "github.com/bedag/kubernetes-dbaas/pkg/test"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe(FormatTestDesc(Unit, "Journal"), func() {
+	var c client.Client
+	var j *journal.Journal
+	var owner *corev1.ConfigMap
+	var ownerRef metav1.OwnerReference
+	ctx := context.Background()
+
+	BeforeEach(func() {
+		c = fake.NewClientBuilder().Build()
+		key, err := journal.LoadOrCreateKey(ctx, c, client.ObjectKey{Namespace: "default", Name: "journal-key"})
+		Expect(err).ToNot(HaveOccurred())
+		j, err = journal.New(c, key)
+		Expect(err).ToNot(HaveOccurred())
+		owner = &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "owner", Namespace: "default", UID: "1234"}}
+		ownerRef = metav1.OwnerReference{APIVersion: "v1", Kind: "ConfigMap", Name: owner.Name, UID: owner.UID}
+	})
+	It("should return no entry if nothing was recorded", func() {
+		entry, err := j.Get(ctx, owner)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(entry).To(BeNil())
+		Expect(entry.IsExecuted("create")).To(BeFalse())
+	})
+	It("should return the output of an executed operation", func() {
+		Expect(j.Record(ctx, owner, ownerRef, journal.Entry{Operation: "create", Phase: journal.PhaseIntent})).To(Succeed())
+		entry, err := j.Get(ctx, owner)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(entry.IsExecuted("create")).To(BeFalse())
+
+		output := map[string]string{"username": "user", "password": "secret"}
+		Expect(j.Record(ctx, owner, ownerRef, journal.Entry{Operation: "create", Phase: journal.PhaseExecuted, Output: output})).To(Succeed())
+		entry, err = j.Get(ctx, owner)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(entry.IsExecuted("create")).To(BeTrue())
+		Expect(entry.IsExecuted("rotate")).To(BeFalse())
+		Expect(entry.Output).To(Equal(output))
+	})
+	It("should encrypt the output", func() {
+		output := map[string]string{"password": "secret"}
+		Expect(j.Record(ctx, owner, ownerRef, journal.Entry{Operation: "create", Phase: journal.PhaseExecuted, Output: output})).To(Succeed())
+		secret := corev1.Secret{}
+		Expect(c.Get(ctx, client.ObjectKey{Namespace: owner.Namespace, Name: journal.SecretName(owner.Name)}, &secret)).To(Succeed())
+		Expect(secret.OwnerReferences).To(ConsistOf(ownerRef))
+		for _, value := range secret.Data {
+			Expect(string(value)).ToNot(ContainSubstring("secret"))
+		}
+	})
+	It("should keep the seed of an operation", func() {
+		var entry *journal.Entry
+		seed, err := entry.SeedFor("create")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(seed).ToNot(BeEmpty())
+		Expect(j.Record(ctx, owner, ownerRef, journal.Entry{Operation: "create", Phase: journal.PhaseIntent, Seed: seed})).To(Succeed())
+		entry, err = j.Get(ctx, owner)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(entry.SeedFor("create")).To(Equal(seed))
+		// Another operation gets a new seed
+		Expect(entry.SeedFor("rotate")).ToNot(Equal(seed))
+	})
+	It("should not resume failed operations but keep their seed", func() {
+		seed := []byte("seed")
+		Expect(j.Record(ctx, owner, ownerRef, journal.Entry{Operation: "create", Phase: journal.PhaseFailed, Seed: seed})).To(Succeed())
+		entry, err := j.Get(ctx, owner)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(entry.Phase).To(Equal(journal.PhaseFailed))
+		Expect(entry.IsExecuted("create")).To(BeFalse())
+		Expect(entry.SeedFor("create")).To(Equal(seed))
+	})
+	It("should remove the entry once completed", func() {
+		Expect(j.Record(ctx, owner, ownerRef, journal.Entry{Operation: "create", Phase: journal.PhaseExecuted, Output: map[string]string{}})).To(Succeed())
+		Expect(j.Complete(ctx, owner)).To(Succeed())
+		entry, err := j.Get(ctx, owner)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(entry).To(BeNil())
+		// Completing twice is not an error
+		Expect(j.Complete(ctx, owner)).To(Succeed())
+	})
+	It("should load the same key twice", func() {
+		key := client.ObjectKey{Namespace: "default", Name: "other-key"}
+		first, err := journal.LoadOrCreateKey(ctx, c, key)
+		Expect(err).ToNot(HaveOccurred())
+		second, err := journal.LoadOrCreateKey(ctx, c, key)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(second).To(Equal(first))
+		Expect(first).To(HaveLen(journal.KeySize))
+	})
+})
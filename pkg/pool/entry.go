@@ -0,0 +1,132 @@
+package pool
+
+import (
+	"context"
+	"errors"
+	"github.com/bedag/kubernetes-dbaas/pkg/database"
+	"sync"
+)
+
+// ErrEntryClosed is returned by the operations called on a DbmsEntry after it was shut down.
+var ErrEntryClosed = errors.New("the connection to the endpoint is closed")
+
+// DbmsEntry represents a standard Dbms connection. It keeps track of the operations in flight, so that the connection
+// is closed only once they are completed, see Shutdown.
+type DbmsEntry struct {
+	Entry
+	driver      string
+	dbClassName string
+	dsn         database.Dsn
+	options     database.ConnectionOptions
+
+	mu       sync.RWMutex
+	closed   bool
+	inFlight sync.WaitGroup
+}
+
+// newDbmsEntry returns a DbmsEntry wrapping conn.
+func newDbmsEntry(conn Entry, driver, dbClassName string, dsn database.Dsn, options database.ConnectionOptions) *DbmsEntry {
+	return &DbmsEntry{
+		Entry:       conn,
+		driver:      driver,
+		dbClassName: dbClassName,
+		dsn:         dsn,
+		options:     options,
+	}
+}
+
+func (e *DbmsEntry) CreateDb(ctx context.Context, operation database.Operation) database.OpOutput {
+	return e.track(ctx, operation, e.Entry.CreateDb)
+}
+
+func (e *DbmsEntry) DeleteDb(ctx context.Context, operation database.Operation) database.OpOutput {
+	return e.track(ctx, operation, e.Entry.DeleteDb)
+}
+
+func (e *DbmsEntry) Rotate(ctx context.Context, operation database.Operation) database.OpOutput {
+	return e.track(ctx, operation, e.Entry.Rotate)
+}
+
+func (e *DbmsEntry) Exists(ctx context.Context, operation database.Operation) database.OpOutput {
+	return e.track(ctx, operation, e.Entry.Exists)
+}
+
+func (e *DbmsEntry) List(ctx context.Context, operation database.Operation) database.OpOutput {
+	return e.track(ctx, operation, e.Entry.List)
+}
+
+func (e *DbmsEntry) Usage(ctx context.Context, operation database.Operation) database.OpOutput {
+	return e.track(ctx, operation, e.Entry.Usage)
+}
+
+func (e *DbmsEntry) Ping(ctx context.Context) error {
+	if !e.acquire() {
+		return ErrEntryClosed
+	}
+	defer e.inFlight.Done()
+	return e.Entry.Ping(ctx)
+}
+
+// Driver returns the driver of the connection of e, e.g. database.Postgres.
+func (e *DbmsEntry) Driver() string {
+	return e.driver
+}
+
+// Dsn returns the DSN the connection of e was opened with.
+func (e *DbmsEntry) Dsn() database.Dsn {
+	return e.dsn
+}
+
+// Close closes the connection once the operations in flight are completed, see Shutdown.
+func (e *DbmsEntry) Close() error {
+	return e.Shutdown(context.Background())
+}
+
+// Shutdown rejects new operations with ErrEntryClosed, waits until the operations in flight are completed or ctx is
+// done, then closes the connection. If ctx is done first, ctx.Err() is returned, the connection is closed anyway.
+func (e *DbmsEntry) Shutdown(ctx context.Context) error {
+	e.mu.Lock()
+	if e.closed {
+		e.mu.Unlock()
+		return nil
+	}
+	e.closed = true
+	e.mu.Unlock()
+
+	drained := make(chan struct{})
+	go func() {
+		e.inFlight.Wait()
+		close(drained)
+	}()
+	var drainErr error
+	select {
+	case <-drained:
+	case <-ctx.Done():
+		drainErr = ctx.Err()
+	}
+	if err := e.Entry.Close(); err != nil {
+		return err
+	}
+	return drainErr
+}
+
+// acquire registers an operation in flight. It returns false if the entry was shut down, in which case the operation
+// must not be executed.
+func (e *DbmsEntry) acquire() bool {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	if e.closed {
+		return false
+	}
+	e.inFlight.Add(1)
+	return true
+}
+
+// track executes operation through execute, unless the entry was shut down.
+func (e *DbmsEntry) track(ctx context.Context, operation database.Operation, execute func(context.Context, database.Operation) database.OpOutput) database.OpOutput {
+	if !e.acquire() {
+		return database.OpOutput{Err: ErrEntryClosed}
+	}
+	defer e.inFlight.Done()
+	return execute(ctx, operation)
+}
@@ -2,9 +2,16 @@
 package pool
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"github.com/bedag/kubernetes-dbaas/internal/metrics"
 	"github.com/bedag/kubernetes-dbaas/pkg/database"
 	"github.com/go-logr/logr"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"net/http"
+	"sort"
+	"sync"
 	"time"
 )
 
@@ -12,7 +19,10 @@ import (
 type Pool interface {
 	Get(name string) Entry
 	Register(name string, driver string, dsn database.Dsn) error
-	Keepalive(interval time.Duration, logger logr.Logger)
+	Update(ctx context.Context, name string, dsn database.Dsn) error
+	Remove(ctx context.Context, name string) error
+	Keepalive(ctx context.Context, interval time.Duration, logger logr.Logger)
+	Close(ctx context.Context) error
 }
 
 // Entry specifies the generic interface for an entry of DbmsPool.
@@ -20,37 +30,70 @@ type Entry interface {
 	database.Driver
 }
 
-// DbmsPool is a map of pool entries identified by a unique name.
+// DbmsPool is a map of pool entries identified by a unique name. It is safe for concurrent use.
 type DbmsPool struct {
-	entries map[string]Entry
-	rps     int
+	entries  map[string]Entry
+	statuses map[string]*EndpointStatus
+	mu       *sync.RWMutex
+	rps      int
+}
+
+// CircuitState tells whether an endpoint is considered reachable, based on its last pings.
+type CircuitState string
+
+const (
+	// CircuitClosed means that the endpoint is considered reachable.
+	CircuitClosed CircuitState = "Closed"
+	// CircuitOpen means that the last CircuitThreshold pings to the endpoint failed.
+	CircuitOpen CircuitState = "Open"
+
+	// CircuitThreshold is the number of consecutive failed pings after which the circuit of an endpoint is open.
+	CircuitThreshold = 3
+)
+
+// EndpointStatus is the connectivity status of a pool entry, as observed by its last ping.
+type EndpointStatus struct {
+	Name                string       `json:"name"`
+	Driver              string       `json:"driver"`
+	DatabaseClassName   string       `json:"databaseClassName,omitempty"`
+	LastPingTime        *time.Time   `json:"lastPingTime,omitempty"`
+	LastPingError       string       `json:"lastPingError,omitempty"`
+	LatencySeconds      float64      `json:"latencySeconds"`
+	ConsecutiveFailures int          `json:"consecutiveFailures"`
+	Circuit             CircuitState `json:"circuit"`
+}
+
+// Reachable returns true if the last ping to the endpoint succeeded.
+func (s EndpointStatus) Reachable() bool {
+	return s.LastPingTime != nil && s.LastPingError == ""
 }
 
 // Get retrieves an Entry from pool.
 func (pool DbmsPool) Get(name string) Entry {
+	pool.mu.RLock()
+	defer pool.mu.RUnlock()
 	return pool.entries[name]
 }
 
-// DbmsEntry represents a standard Dbms connection.
-type DbmsEntry struct {
-	Entry
-	driver string
-	dsn    database.Dsn
-}
-
 // NewDbmsPool initializes a DbmsPool struct with the given rps. See also database.RateLimitedDbmsConn.
 func NewDbmsPool(rps int) DbmsPool {
 	return DbmsPool{
-		entries: make(map[string]Entry),
-		rps:     rps,
+		entries:  make(map[string]Entry),
+		statuses: make(map[string]*EndpointStatus),
+		mu:       &sync.RWMutex{},
+		rps:      rps,
 	}
 }
 
 // RegisterDbms is a utility function around Register. It iterates over database.Dbms.Endpoints and registers a connection for
-// each endpoint.
-func (pool DbmsPool) RegisterDbms(dbms database.Dbms, driver string) error {
+// each endpoint, configured by database.Endpoint.Connection. Certificates and keys are read through readSecret.
+func (pool DbmsPool) RegisterDbms(dbms database.Dbms, driver string, readSecret database.SecretReader) error {
 	for _, endpoint := range dbms.Endpoints {
-		if err := pool.Register(endpoint.Name, driver, endpoint.Dsn); err != nil {
+		options, err := endpoint.Connection.Options(readSecret)
+		if err != nil {
+			return fmt.Errorf("invalid connection configuration for endpoint %s: %s", endpoint.Name, err)
+		}
+		if err := pool.register(endpoint.Name, dbms.DatabaseClassName, driver, endpoint.Dsn, options); err != nil {
 			return err
 		}
 	}
@@ -59,33 +102,255 @@ func (pool DbmsPool) RegisterDbms(dbms database.Dbms, driver string) error {
 
 // Register registers a new database.Dbms in the pool.
 func (pool DbmsPool) Register(name string, driver string, dsn database.Dsn) error {
-	conn, err := database.New(driver, dsn)
-	if err != nil {
-		return fmt.Errorf("problem opening connection to endpoint with driver: '%s': %s", driver, err)
+	return pool.register(name, "", driver, dsn, database.ConnectionOptions{})
+}
+
+// register registers a new database.Dbms in the pool.
+func (pool DbmsPool) register(name string, dbClassName string, driver string, dsn database.Dsn, options database.ConnectionOptions) error {
+	if pool.Get(name) != nil {
+		return fmt.Errorf("%s is already present in the pool. Endpoint names must be unique within the list "+
+			"of endpoints", name)
 	}
-	rateLimitedConn, err := database.NewRateLimitedDbmsConn(conn, pool.rps)
+	entry, err := pool.newEntry(name, dbClassName, driver, dsn, options)
 	if err != nil {
 		return err
 	}
+
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
 	if _, exists := pool.entries[name]; exists {
+		_ = entry.Close()
 		return fmt.Errorf("%s is already present in the pool. Endpoint names must be unique within the list "+
 			"of endpoints", name)
 	}
-	pool.entries[name] = DbmsEntry{rateLimitedConn, driver, dsn}
-	return err
+	pool.entries[name] = entry
+	pool.statuses[name] = &EndpointStatus{Name: name, Driver: driver, DatabaseClassName: dbClassName, Circuit: CircuitClosed}
+	return nil
+}
+
+// newEntry opens a connection to dsn. Operations executed on the connection are recorded in the metrics and the spans
+// of name and dbClassName, see metrics.InstrumentedDriver and database.TracedDbmsConn.
+func (pool DbmsPool) newEntry(name string, dbClassName string, driver string, dsn database.Dsn, options database.ConnectionOptions) (*DbmsEntry, error) {
+	conn, err := database.New(driver, dsn, options)
+	if err != nil {
+		return nil, fmt.Errorf("problem opening connection to endpoint with driver: '%s': %s", driver, err)
+	}
+	tracedConn := database.NewTracedDbmsConn(conn, name, dbClassName)
+	instrumentedConn := metrics.NewInstrumentedDriver(tracedConn, name, dbClassName)
+	rateLimitedConn, err := database.NewRateLimitedDbmsConn(instrumentedConn, pool.rps)
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return newDbmsEntry(rateLimitedConn, driver, dbClassName, dsn, options), nil
+}
+
+// Update replaces the connection of the entry identified by name with a connection to dsn, configured like the
+// previous one. The previous connection is closed once the operations in flight are completed or ctx is done.
+func (pool DbmsPool) Update(ctx context.Context, name string, dsn database.Dsn) error {
+	previous, exists := pool.Get(name).(*DbmsEntry)
+	if !exists {
+		return fmt.Errorf("endpoint %s is not present in the pool", name)
+	}
+	entry, err := pool.newEntry(name, previous.dbClassName, previous.driver, dsn, previous.options)
+	if err != nil {
+		return err
+	}
+	pool.mu.Lock()
+	if pool.entries[name] != Entry(previous) {
+		pool.mu.Unlock()
+		_ = entry.Close()
+		return fmt.Errorf("endpoint %s was modified concurrently", name)
+	}
+	pool.entries[name] = entry
+	pool.mu.Unlock()
+	return previous.Shutdown(ctx)
+}
+
+// Remove removes the entry identified by name from the pool, then closes its connections once the operations in
+// flight are completed or ctx is done. Operations started after the removal fail with ErrEntryClosed.
+func (pool DbmsPool) Remove(ctx context.Context, name string) error {
+	pool.mu.Lock()
+	entry, exists := pool.entries[name]
+	delete(pool.entries, name)
+	delete(pool.statuses, name)
+	pool.mu.Unlock()
+	if !exists {
+		return fmt.Errorf("endpoint %s is not present in the pool", name)
+	}
+	metrics.EndpointUp.DeleteLabelValues(name)
+	return entry.(*DbmsEntry).Shutdown(ctx)
 }
 
-// Keepalive starts a periodic ping to each endpoint, if an endpoint becomes unreachable, an error is logged.
-func (pool DbmsPool) Keepalive(interval time.Duration, logger logr.Logger) {
+// Close removes all the entries from the pool and closes their connections, see Remove.
+func (pool DbmsPool) Close(ctx context.Context) error {
+	var errs []error
+	for _, name := range pool.names() {
+		if err := pool.Remove(ctx, name); err != nil {
+			errs = append(errs, fmt.Errorf("problem closing endpoint %s: %s", name, err))
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+// Keepalive pings each endpoint every interval until ctx is done, if an endpoint becomes unreachable, an error is
+// logged. The result of each ping is exposed by the metrics.EndpointUp gauge and by Status.
+func (pool DbmsPool) Keepalive(ctx context.Context, interval time.Duration, logger logr.Logger) {
 	logger = logger.WithName("pool")
-	go func() {
-		for {
-			for k, v := range pool.entries {
-				if err := v.Ping(); err != nil {
-					logger.Error(err, "connection to the endpoint failed", "endpoint", k)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		for _, name := range pool.names() {
+			if err := pool.Ping(ctx, name); err != nil && ctx.Err() == nil {
+				logger.Error(err, "connection to the endpoint failed", "endpoint", name)
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Ping pings the endpoint identified by name and records the result in its status.
+func (pool DbmsPool) Ping(ctx context.Context, name string) error {
+	entry := pool.Get(name)
+	if entry == nil {
+		return fmt.Errorf("endpoint %s is not present in the pool", name)
+	}
+	start := time.Now()
+	err := entry.Ping(ctx)
+	latency := time.Since(start)
+	if dbmsEntry, ok := entry.(*DbmsEntry); ok {
+		err = dbmsEntry.dsn.Redact(err)
+	}
+
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+	status, exists := pool.statuses[name]
+	if !exists {
+		// Removed in the meantime
+		return err
+	}
+	metrics.SetEndpointUp(name, err)
+	status.LastPingTime = &start
+	status.LatencySeconds = latency.Seconds()
+	if err != nil {
+		status.LastPingError = err.Error()
+		status.ConsecutiveFailures++
+	} else {
+		status.LastPingError = ""
+		status.ConsecutiveFailures = 0
+	}
+	if status.ConsecutiveFailures >= CircuitThreshold {
+		status.Circuit = CircuitOpen
+	} else {
+		status.Circuit = CircuitClosed
+	}
+	return err
+}
+
+// Status returns the status of each entry of the pool, sorted by name.
+func (pool DbmsPool) Status() []EndpointStatus {
+	pool.mu.RLock()
+	defer pool.mu.RUnlock()
+	statuses := make([]EndpointStatus, 0, len(pool.statuses))
+	for _, status := range pool.statuses {
+		statuses = append(statuses, *status)
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Name < statuses[j].Name
+	})
+	return statuses
+}
+
+// ReadyChecker returns a readiness check, see healthz.Checker. If requiredEndpoints is empty, the check fails when
+// no endpoint of the pool is reachable, otherwise it fails when any of requiredEndpoints is unreachable. Endpoints
+// which weren't pinged yet, e.g. because Keepalive is disabled, are pinged by the check.
+func (pool DbmsPool) ReadyChecker(requiredEndpoints []string) func(req *http.Request) error {
+	return func(req *http.Request) error {
+		reachable := make(map[string]bool)
+		for _, status := range pool.Status() {
+			if status.LastPingTime == nil {
+				reachable[status.Name] = pool.Ping(req.Context(), status.Name) == nil
+				continue
+			}
+			reachable[status.Name] = status.Reachable()
+		}
+		if len(requiredEndpoints) > 0 {
+			for _, name := range requiredEndpoints {
+				isReachable, exists := reachable[name]
+				if !exists {
+					return fmt.Errorf("required endpoint %s is not present in the pool", name)
+				}
+				if !isReachable {
+					return fmt.Errorf("required endpoint %s is unreachable", name)
 				}
 			}
-			time.Sleep(interval)
+			return nil
+		}
+		if len(reachable) == 0 {
+			return nil
+		}
+		for _, isReachable := range reachable {
+			if isReachable {
+				return nil
+			}
 		}
-	}()
+		return fmt.Errorf("none of the %d endpoints is reachable", len(reachable))
+	}
+}
+
+// StatusHandler returns a http.Handler writing the status of each entry of the pool as JSON, see Status.
+func (pool DbmsPool) StatusHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(pool.Status()); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
+
+// names returns the names of the entries of the pool.
+func (pool DbmsPool) names() []string {
+	pool.mu.RLock()
+	defer pool.mu.RUnlock()
+	names := make([]string, 0, len(pool.entries))
+	for name := range pool.entries {
+		names = append(names, name)
+	}
+	return names
+}
+
+// Runnable ties the lifecycle of a DbmsPool to a manager, see manager.Runnable. While the manager runs, it pings the
+// endpoints every KeepaliveInterval, if set. Once the manager stops, it closes the pool, waiting up to DrainTimeout for
+// the operations in flight to complete, indefinitely if DrainTimeout is negative.
+type Runnable struct {
+	Pool              DbmsPool
+	Log               logr.Logger
+	KeepaliveInterval time.Duration
+	DrainTimeout      time.Duration
+}
+
+// Start blocks until ctx is done, then closes the pool.
+func (r *Runnable) Start(ctx context.Context) error {
+	if r.KeepaliveInterval > 0 {
+		r.Pool.Keepalive(ctx, r.KeepaliveInterval, r.Log)
+	} else {
+		<-ctx.Done()
+	}
+	drainCtx := context.Background()
+	if r.DrainTimeout >= 0 {
+		var cancel context.CancelFunc
+		drainCtx, cancel = context.WithTimeout(drainCtx, r.DrainTimeout)
+		defer cancel()
+	}
+	r.Log.Info("closing endpoint connections")
+	return r.Pool.Close(drainCtx)
+}
+
+// NeedLeaderElection returns false, since each replica of the Operator holds its own connections.
+func (r *Runnable) NeedLeaderElection() bool {
+	return false
 }
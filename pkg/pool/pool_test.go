@@ -1,12 +1,18 @@
 package pool_test
 
 import (
+	"context"
+	"encoding/json"
 	"github.com/bedag/kubernetes-dbaas/pkg/database"
 	"github.com/bedag/kubernetes-dbaas/pkg/pool"
 	. "github.com/bedag/kubernetes-dbaas/pkg/test"
+	"github.com/go-logr/logr"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"time"
 )
 
 var _ = Describe(FormatTestDesc(Integration, "RegisterDbms"), func() {
@@ -27,7 +33,7 @@ var _ = Describe(FormatTestDesc(Integration, "RegisterDbms"), func() {
 	JustBeforeEach(func() {
 		dbmsPool = pool.NewDbmsPool(0)
 		for _, dbms := range dbmsList {
-			err = dbmsPool.RegisterDbms(dbms, database.Postgres)
+			err = dbmsPool.RegisterDbms(dbms, database.Postgres, nil)
 		}
 	})
 	Context("when registering pool entries", func() {
@@ -37,7 +43,7 @@ var _ = Describe(FormatTestDesc(Integration, "RegisterDbms"), func() {
 		It("should register the postgres entry correctly", func() {
 			driver := dbmsPool.Get("postgres")
 			Expect(driver).ToNot(BeNil())
-			Expect(driver.Ping()).To(Succeed())
+			Expect(driver.Ping(context.Background())).To(Succeed())
 		})
 	})
 	Context("when trying to register a duplicate pool entry", func() {
@@ -94,3 +100,106 @@ var _ = Describe(FormatTestDesc(Integration, "RegisterDbms"), func() {
 		})
 	})
 })
+
+var _ = Describe(FormatTestDesc(Integration, "DbmsPool status"), func() {
+	var dbmsPool pool.DbmsPool
+	BeforeEach(func() {
+		dbmsPool = pool.NewDbmsPool(0)
+		Expect(dbmsPool.Register("postgres", database.Postgres, database.Dsn(os.Getenv("POSTGRES_DSN")))).To(Succeed())
+	})
+	Context("when the endpoint wasn't pinged yet", func() {
+		It("should not report a ping result", func() {
+			statuses := dbmsPool.Status()
+			Expect(statuses).To(HaveLen(1))
+			Expect(statuses[0].Name).To(Equal("postgres"))
+			Expect(statuses[0].Driver).To(Equal(database.Postgres))
+			Expect(statuses[0].LastPingTime).To(BeNil())
+			Expect(statuses[0].Circuit).To(Equal(pool.CircuitClosed))
+		})
+		It("should pass the readiness check by pinging the endpoint", func() {
+			req := httptest.NewRequest(http.MethodGet, "/readyz", nil)
+			Expect(dbmsPool.ReadyChecker(nil)(req)).To(Succeed())
+			Expect(dbmsPool.Status()[0].Reachable()).To(BeTrue())
+		})
+	})
+	Context("when the endpoint was pinged", func() {
+		BeforeEach(func() {
+			Expect(dbmsPool.Ping(context.Background(), "postgres")).To(Succeed())
+		})
+		It("should report the ping result", func() {
+			status := dbmsPool.Status()[0]
+			Expect(status.LastPingTime).ToNot(BeNil())
+			Expect(status.LastPingError).To(BeEmpty())
+			Expect(status.ConsecutiveFailures).To(BeZero())
+			Expect(status.Reachable()).To(BeTrue())
+		})
+		It("should write the status as JSON", func() {
+			recorder := httptest.NewRecorder()
+			dbmsPool.StatusHandler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/endpoints", nil))
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			var statuses []pool.EndpointStatus
+			Expect(json.Unmarshal(recorder.Body.Bytes(), &statuses)).To(Succeed())
+			Expect(statuses).To(HaveLen(1))
+			Expect(statuses[0].Name).To(Equal("postgres"))
+		})
+	})
+	Context("when a required endpoint is not present in the pool", func() {
+		It("should fail the readiness check", func() {
+			req := httptest.NewRequest(http.MethodGet, "/readyz", nil)
+			Expect(dbmsPool.ReadyChecker([]string{"postgres", "missing"})(req)).ToNot(Succeed())
+		})
+	})
+	Context("when pinging an endpoint not present in the pool", func() {
+		It("should return an error", func() {
+			Expect(dbmsPool.Ping(context.Background(), "missing")).ToNot(Succeed())
+		})
+	})
+})
+
+var _ = Describe(FormatTestDesc(Integration, "DbmsPool lifecycle"), func() {
+	var dbmsPool pool.DbmsPool
+	BeforeEach(func() {
+		dbmsPool = pool.NewDbmsPool(0)
+		Expect(dbmsPool.Register("postgres", database.Postgres, database.Dsn(os.Getenv("POSTGRES_DSN")))).To(Succeed())
+	})
+	Context("when removing an entry", func() {
+		It("should close its connection and reject new operations", func() {
+			entry := dbmsPool.Get("postgres")
+			Expect(dbmsPool.Remove(context.Background(), "postgres")).To(Succeed())
+			Expect(dbmsPool.Get("postgres")).To(BeNil())
+			Expect(dbmsPool.Status()).To(BeEmpty())
+			Expect(entry.Ping(context.Background())).To(MatchError(pool.ErrEntryClosed))
+			Expect(entry.CreateDb(context.Background(), database.Operation{}).Err).To(MatchError(pool.ErrEntryClosed))
+		})
+		It("should return an error if the entry is not present", func() {
+			Expect(dbmsPool.Remove(context.Background(), "missing")).ToNot(Succeed())
+		})
+	})
+	Context("when closing the pool", func() {
+		It("should remove all entries", func() {
+			Expect(dbmsPool.Close(context.Background())).To(Succeed())
+			Expect(dbmsPool.Get("postgres")).To(BeNil())
+		})
+	})
+	Context("when the manager stops", func() {
+		It("should stop the keepalive and close the pool", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			runnable := &pool.Runnable{
+				Pool:              dbmsPool,
+				Log:               logr.Discard(),
+				KeepaliveInterval: time.Second,
+				DrainTimeout:      time.Second,
+			}
+			done := make(chan error)
+			go func() {
+				done <- runnable.Start(ctx)
+			}()
+			Eventually(func() bool {
+				return dbmsPool.Status()[0].Reachable()
+			}).Should(BeTrue())
+			cancel()
+			Eventually(done).Should(Receive(BeNil()))
+			Expect(dbmsPool.Get("postgres")).To(BeNil())
+		})
+	})
+})
@@ -0,0 +1,389 @@
+// Package secretsink stores the credentials of Database resources, e.g. in Kubernetes Secrets or in the KV version 2
+// secrets engine of Vault.
+//
+// Credentials are identified by the resource owning them and by a name, e.g. the name of the Secret.
+package secretsink
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/bedag/kubernetes-dbaas/pkg/database"
+	"github.com/bedag/kubernetes-dbaas/pkg/dsnprovider"
+	"github.com/bedag/kubernetes-dbaas/pkg/vault"
+	"io/ioutil"
+	corev1 "k8s.io/api/core/v1"
+	k8sError "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"os"
+	"path"
+	"path/filepath"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"strings"
+)
+
+const (
+	// HashAnnotationKey is the annotation of the Secrets written by Kubernetes, and the custom metadata of the secrets
+	// written by Vault, holding the hash of their content.
+	HashAnnotationKey = "dbaas.bedag.ch/secret-hash"
+	// OwnerMetadataKey is the custom metadata of the secrets written by Vault holding the UID of their owner.
+	OwnerMetadataKey = "dbaas.bedag.ch/owner"
+)
+
+var (
+	// ErrNotFound is returned when no credentials are stored under a name.
+	ErrNotFound = errors.New("credentials not found")
+	// ErrNotOwned is returned when the credentials stored under a name were not written for the resource requesting
+	// them.
+	ErrNotOwned = errors.New("credentials exist already and are not owned by the resource")
+)
+
+// Credentials are the credentials of a resource as stored by a SecretSink.
+type Credentials struct {
+	// Data is the rendered SecretFormat of the resource.
+	Data database.SecretFormat
+	// Hash is the hash of Data as recorded when it was written. It is empty if the SecretSink doesn't record it.
+	Hash string
+	// Type, Labels, Annotations and Immutable configure the Secret written by Kubernetes. Other SecretSinks ignore
+	// them, and Read never returns them.
+	Type        corev1.SecretType
+	Labels      map[string]string
+	Annotations map[string]string
+	Immutable   bool
+}
+
+// SecretSink stores the credentials of resources.
+type SecretSink interface {
+	// Read returns the credentials of owner stored under name. It returns ErrNotFound if there are none, ErrNotOwned if
+	// they were not written for owner.
+	Read(ctx context.Context, owner client.Object, name string) (Credentials, error)
+	// Write stores credentials under name, replacing the previous credentials of owner. It returns ErrNotOwned if
+	// credentials not written for owner are stored under name. ownerRef is used by sinks whose content is garbage
+	// collected together with owner.
+	Write(ctx context.Context, owner client.Object, ownerRef metav1.OwnerReference, name string, credentials Credentials) error
+	// Delete deletes the credentials of owner stored under name. Missing credentials and credentials not written for
+	// owner are ignored.
+	Delete(ctx context.Context, owner client.Object, name string) error
+	// Location returns where the credentials of owner stored under name are, e.g. the namespace and name of a Secret.
+	Location(owner client.Object, name string) string
+}
+
+// Kubernetes is a SecretSink storing credentials in Secrets in the namespace of their owner. Secrets are owned by
+// their owner, so that they are garbage collected together with it.
+type Kubernetes struct {
+	Client client.Client
+}
+
+func (s Kubernetes) Read(ctx context.Context, owner client.Object, name string) (Credentials, error) {
+	secret := corev1.Secret{}
+	if err := s.Client.Get(ctx, client.ObjectKey{Namespace: owner.GetNamespace(), Name: name}, &secret); err != nil {
+		if k8sError.IsNotFound(err) {
+			return Credentials{}, ErrNotFound
+		}
+		return Credentials{}, err
+	}
+	if !isControlledBy(&secret, owner) {
+		return Credentials{}, ErrNotOwned
+	}
+	credentials := Credentials{
+		Data: make(database.SecretFormat, len(secret.Data)),
+		Hash: secret.Annotations[HashAnnotationKey],
+	}
+	for k, v := range secret.Data {
+		credentials.Data[k] = string(v)
+	}
+	return credentials, nil
+}
+
+func (s Kubernetes) Write(ctx context.Context, owner client.Object, ownerRef metav1.OwnerReference, name string, credentials Credentials) error {
+	annotations := make(map[string]string, len(credentials.Annotations)+1)
+	for k, v := range credentials.Annotations {
+		annotations[k] = v
+	}
+	annotations[HashAnnotationKey] = credentials.Hash
+	secretType := credentials.Type
+	if secretType == "" {
+		secretType = corev1.SecretTypeOpaque
+	}
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            name,
+			Namespace:       owner.GetNamespace(),
+			OwnerReferences: []metav1.OwnerReference{ownerRef},
+			Labels:          credentials.Labels,
+			Annotations:     annotations,
+		},
+		Type:       secretType,
+		StringData: credentials.Data,
+	}
+	if credentials.Immutable {
+		immutable := true
+		secret.Immutable = &immutable
+	}
+	oldSecret := corev1.Secret{}
+	if err := s.Client.Get(ctx, client.ObjectKeyFromObject(secret), &oldSecret); err != nil {
+		if k8sError.IsNotFound(err) {
+			return s.Client.Create(ctx, secret)
+		}
+		return err
+	}
+	if !isControlledBy(&oldSecret, owner) {
+		return ErrNotOwned
+	}
+	// Neither the type nor the content of an immutable Secret can be updated, the Secret must be recreated
+	if oldSecret.Type != secretType || (oldSecret.Immutable != nil && *oldSecret.Immutable) {
+		if err := s.Client.Delete(ctx, &oldSecret); err != nil && !k8sError.IsNotFound(err) {
+			return err
+		}
+		return s.Client.Create(ctx, secret)
+	}
+	secret.ResourceVersion = oldSecret.ResourceVersion
+	return s.Client.Update(ctx, secret)
+}
+
+func (s Kubernetes) Delete(ctx context.Context, owner client.Object, name string) error {
+	secret := corev1.Secret{}
+	if err := s.Client.Get(ctx, client.ObjectKey{Namespace: owner.GetNamespace(), Name: name}, &secret); err != nil {
+		if k8sError.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	if !isControlledBy(&secret, owner) {
+		return nil
+	}
+	if err := s.Client.Delete(ctx, &secret); err != nil && !k8sError.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+func (s Kubernetes) Location(owner client.Object, name string) string {
+	return owner.GetNamespace() + "/" + name
+}
+
+// Vault is a SecretSink storing credentials in secrets of a Vault KV version 2 secrets engine, at
+// <Path>/<namespace of the owner>/<name>. The UID of the owner of the credentials and their hash are recorded in the
+// custom metadata of the secret, see OwnerMetadataKey and HashAnnotationKey, which requires Vault 1.9 or later.
+type Vault struct {
+	// Reader reads the Secret referenced by TokenSecretKeyRef.
+	Reader client.Reader
+	// Address is the address of the Vault server. Defaults to the vault.AddressEnvVar environment variable.
+	Address string
+	// Mount is the path the secrets engine is mounted at. Defaults to vault.DefaultMount.
+	Mount string
+	// Path is the path under which credentials are stored.
+	Path string
+	// TokenSecretKeyRef references the token used to authenticate to Vault. If nil, the token defaults to the
+	// vault.TokenEnvVar environment variable.
+	TokenSecretKeyRef *database.SecretKeyRef
+	// DefaultNamespace is the namespace TokenSecretKeyRef is read from if it doesn't specify any.
+	DefaultNamespace string
+}
+
+func (s Vault) Read(ctx context.Context, owner client.Object, name string) (Credentials, error) {
+	vaultClient, err := s.client(ctx)
+	if err != nil {
+		return Credentials{}, err
+	}
+	metadata, err := s.readMetadata(ctx, vaultClient, owner, name)
+	if err != nil {
+		return Credentials{}, err
+	}
+	if metadata[OwnerMetadataKey] != string(owner.GetUID()) {
+		return Credentials{}, ErrNotOwned
+	}
+	data, err := vaultClient.Read(ctx, s.Mount, s.path(owner, name))
+	if err != nil {
+		if errors.Is(err, vault.ErrNotFound) {
+			return Credentials{}, ErrNotFound
+		}
+		return Credentials{}, err
+	}
+	return Credentials{Data: data, Hash: metadata[HashAnnotationKey]}, nil
+}
+
+func (s Vault) Write(ctx context.Context, owner client.Object, _ metav1.OwnerReference, name string, credentials Credentials) error {
+	vaultClient, err := s.client(ctx)
+	if err != nil {
+		return err
+	}
+	metadata, err := s.readMetadata(ctx, vaultClient, owner, name)
+	if err == nil && metadata[OwnerMetadataKey] != string(owner.GetUID()) {
+		return ErrNotOwned
+	}
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return err
+	}
+	// Record the owner before writing the credentials, so that the secret is never left without an owner
+	if err := vaultClient.WriteMetadata(ctx, s.Mount, s.path(owner, name), map[string]string{
+		OwnerMetadataKey:  string(owner.GetUID()),
+		HashAnnotationKey: credentials.Hash,
+	}); err != nil {
+		return err
+	}
+	return vaultClient.Write(ctx, s.Mount, s.path(owner, name), credentials.Data)
+}
+
+func (s Vault) Delete(ctx context.Context, owner client.Object, name string) error {
+	vaultClient, err := s.client(ctx)
+	if err != nil {
+		return err
+	}
+	metadata, err := s.readMetadata(ctx, vaultClient, owner, name)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return nil
+		}
+		return err
+	}
+	if metadata[OwnerMetadataKey] != string(owner.GetUID()) {
+		return nil
+	}
+	if err := vaultClient.Delete(ctx, s.Mount, s.path(owner, name)); err != nil && !errors.Is(err, vault.ErrNotFound) {
+		return err
+	}
+	return nil
+}
+
+func (s Vault) Location(owner client.Object, name string) string {
+	mount := s.Mount
+	if mount == "" {
+		mount = vault.DefaultMount
+	}
+	return path.Join(mount, s.path(owner, name))
+}
+
+// client returns a vault.Client authenticating with the token referenced by s. The token is read on each call, so that
+// it can be renewed.
+func (s Vault) client(ctx context.Context) (*vault.Client, error) {
+	var token string
+	if s.TokenSecretKeyRef != nil {
+		value, err := dsnprovider.ReadSecret(ctx, s.Reader, *s.TokenSecretKeyRef, s.DefaultNamespace)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read vault token: %s", err)
+		}
+		token = strings.TrimSpace(string(value))
+	}
+	return vault.NewClient(s.Address, token)
+}
+
+// readMetadata returns the custom metadata of the secret storing the credentials stored under name. It returns
+// ErrNotFound if the secret doesn't exist.
+func (s Vault) readMetadata(ctx context.Context, vaultClient *vault.Client, owner client.Object, name string) (map[string]string, error) {
+	metadata, err := vaultClient.ReadMetadata(ctx, s.Mount, s.path(owner, name))
+	if err != nil {
+		if errors.Is(err, vault.ErrNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return metadata, nil
+}
+
+// path returns the path of the secret storing the credentials of owner stored under name.
+func (s Vault) path(owner client.Object, name string) string {
+	return path.Join(s.Path, owner.GetNamespace(), name)
+}
+
+// File is a SecretSink storing credentials in JSON files at <Directory>/<namespace of the owner>/<name>.json. It is
+// meant for testing and for environments where credentials are collected from a shared volume.
+type File struct {
+	Directory string
+}
+
+// fileContent is the content of the files written by File.
+type fileContent struct {
+	Owner string                `json:"owner"`
+	Hash  string                `json:"hash"`
+	Data  database.SecretFormat `json:"data"`
+}
+
+func (s File) Read(_ context.Context, owner client.Object, name string) (Credentials, error) {
+	content, err := s.read(owner, name)
+	if err != nil {
+		return Credentials{}, err
+	}
+	if content.Owner != string(owner.GetUID()) {
+		return Credentials{}, ErrNotOwned
+	}
+	return Credentials{Data: content.Data, Hash: content.Hash}, nil
+}
+
+func (s File) Write(_ context.Context, owner client.Object, _ metav1.OwnerReference, name string, credentials Credentials) error {
+	content, err := s.read(owner, name)
+	if err == nil && content.Owner != string(owner.GetUID()) {
+		return ErrNotOwned
+	}
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return err
+	}
+	value, err := json.Marshal(fileContent{Owner: string(owner.GetUID()), Hash: credentials.Hash, Data: credentials.Data})
+	if err != nil {
+		return err
+	}
+	filename := s.Location(owner, name)
+	if err := os.MkdirAll(filepath.Dir(filename), 0700); err != nil {
+		return err
+	}
+	// Write to a temporary file first, so that readers never see a partially written file
+	tmp, err := ioutil.TempFile(filepath.Dir(filename), "."+filepath.Base(filename))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(value); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filename)
+}
+
+func (s File) Delete(_ context.Context, owner client.Object, name string) error {
+	content, err := s.read(owner, name)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return nil
+		}
+		return err
+	}
+	if content.Owner != string(owner.GetUID()) {
+		return nil
+	}
+	if err := os.Remove(s.Location(owner, name)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (s File) Location(owner client.Object, name string) string {
+	return filepath.Join(s.Directory, owner.GetNamespace(), name+".json")
+}
+
+// read returns the content of the file storing the credentials stored under name. It returns ErrNotFound if the file
+// doesn't exist.
+func (s File) read(owner client.Object, name string) (fileContent, error) {
+	value, err := ioutil.ReadFile(s.Location(owner, name))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fileContent{}, ErrNotFound
+		}
+		return fileContent{}, err
+	}
+	content := fileContent{}
+	if err := json.Unmarshal(value, &content); err != nil {
+		// The error of Unmarshal never contains the content
+		return fileContent{}, fmt.Errorf("unable to parse credentials file '%s': %s", s.Location(owner, name), err)
+	}
+	return content, nil
+}
+
+// isControlledBy returns true if obj is controlled by owner.
+func isControlledBy(obj metav1.Object, owner client.Object) bool {
+	ref := metav1.GetControllerOf(obj)
+	return ref != nil && ref.UID == owner.GetUID()
+}
@@ -0,0 +1,297 @@
+package secretsink_test
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/bedag/kubernetes-dbaas/pkg/database"
+	"github.com/bedag/kubernetes-dbaas/pkg/secretsink"
+	. "github.com/bedag/kubernetes-dbaas/pkg/test"
+	"github.com/bedag/kubernetes-dbaas/pkg/vault"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"io/ioutil"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"strings"
+	"sync"
+)
+
+const secretName = "test-credentials"
+
+// newOwner returns a resource owning credentials, identified by uid.
+func newOwner(uid string) (client.Object, metav1.OwnerReference) {
+	owner := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "test", UID: types.UID(uid)}}
+	return owner, metav1.OwnerReference{
+		APIVersion: "v1",
+		Kind:       "ConfigMap",
+		Name:       owner.Name,
+		UID:        owner.UID,
+		Controller: &[]bool{true}[0],
+	}
+}
+
+// testSink runs the tests common to all the SecretSinks returned by newSink.
+func testSink(newSink func() secretsink.SecretSink) {
+	var sink secretsink.SecretSink
+	credentials := secretsink.Credentials{Data: database.SecretFormat{"username": "test", "password": "Password&1"}}
+	credentials.Hash = credentials.Data.Hash()
+
+	BeforeEach(func() {
+		sink = newSink()
+	})
+
+	It("should return ErrNotFound if no credentials were written", func() {
+		owner, _ := newOwner("a")
+		_, err := sink.Read(context.Background(), owner, secretName)
+		Expect(err).To(Equal(secretsink.ErrNotFound))
+	})
+	It("should read the credentials written", func() {
+		owner, ownerRef := newOwner("a")
+		Expect(sink.Write(context.Background(), owner, ownerRef, secretName, credentials)).To(Succeed())
+		read, err := sink.Read(context.Background(), owner, secretName)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(read.Data).To(Equal(credentials.Data))
+	})
+	It("should replace the credentials written", func() {
+		owner, ownerRef := newOwner("a")
+		Expect(sink.Write(context.Background(), owner, ownerRef, secretName, credentials)).To(Succeed())
+		rotated := secretsink.Credentials{Data: database.SecretFormat{"username": "test", "password": "Password&2"}}
+		Expect(sink.Write(context.Background(), owner, ownerRef, secretName, rotated)).To(Succeed())
+		read, err := sink.Read(context.Background(), owner, secretName)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(read.Data).To(Equal(rotated.Data))
+	})
+	It("should delete the credentials written", func() {
+		owner, ownerRef := newOwner("a")
+		Expect(sink.Write(context.Background(), owner, ownerRef, secretName, credentials)).To(Succeed())
+		Expect(sink.Delete(context.Background(), owner, secretName)).To(Succeed())
+		_, err := sink.Read(context.Background(), owner, secretName)
+		Expect(err).To(Equal(secretsink.ErrNotFound))
+	})
+	It("should ignore missing credentials on delete", func() {
+		owner, _ := newOwner("a")
+		Expect(sink.Delete(context.Background(), owner, secretName)).To(Succeed())
+	})
+	It("should not touch credentials written for another owner", func() {
+		owner, ownerRef := newOwner("a")
+		other, otherRef := newOwner("b")
+		Expect(sink.Write(context.Background(), owner, ownerRef, secretName, credentials)).To(Succeed())
+		_, err := sink.Read(context.Background(), other, secretName)
+		Expect(err).To(Equal(secretsink.ErrNotOwned))
+		Expect(sink.Write(context.Background(), other, otherRef, secretName, credentials)).To(Equal(secretsink.ErrNotOwned))
+		Expect(sink.Delete(context.Background(), other, secretName)).To(Succeed())
+		_, err = sink.Read(context.Background(), owner, secretName)
+		Expect(err).ToNot(HaveOccurred())
+	})
+	It("should record the hash of the credentials", func() {
+		owner, ownerRef := newOwner("a")
+		Expect(sink.Write(context.Background(), owner, ownerRef, secretName, credentials)).To(Succeed())
+		read, err := sink.Read(context.Background(), owner, secretName)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(read.Hash).To(Equal(credentials.Hash))
+	})
+}
+
+var _ = Describe(FormatTestDesc(Unit, "Kubernetes"), func() {
+	testSink(func() secretsink.SecretSink {
+		return secretsink.Kubernetes{Client: fake.NewClientBuilder().Build()}
+	})
+
+	It("should return the namespace and name of the Secret as location", func() {
+		owner, _ := newOwner("a")
+		Expect(secretsink.Kubernetes{}.Location(owner, secretName)).To(Equal("default/" + secretName))
+	})
+	Context("when the credentials configure the Secret", func() {
+		var k8sClient client.Client
+		var sink secretsink.SecretSink
+		owner, ownerRef := newOwner("a")
+		credentials := secretsink.Credentials{
+			Data:        database.SecretFormat{"username": "test"},
+			Hash:        "hash",
+			Type:        "servicebinding.io/postgresql",
+			Labels:      map[string]string{"app": "test"},
+			Annotations: map[string]string{"team": "dbaas"},
+			Immutable:   true,
+		}
+
+		BeforeEach(func() {
+			k8sClient = fake.NewClientBuilder().Build()
+			sink = secretsink.Kubernetes{Client: k8sClient}
+		})
+
+		It("should write the type, labels, annotations and immutability of the Secret", func() {
+			Expect(sink.Write(context.Background(), owner, ownerRef, secretName, credentials)).To(Succeed())
+			secret := corev1.Secret{}
+			Expect(k8sClient.Get(context.Background(), client.ObjectKey{Namespace: "default", Name: secretName}, &secret)).To(Succeed())
+			Expect(secret.Type).To(Equal(corev1.SecretType("servicebinding.io/postgresql")))
+			Expect(secret.Labels).To(Equal(map[string]string{"app": "test"}))
+			Expect(secret.Annotations).To(Equal(map[string]string{"team": "dbaas", secretsink.HashAnnotationKey: "hash"}))
+			Expect(secret.Immutable).ToNot(BeNil())
+			Expect(*secret.Immutable).To(BeTrue())
+		})
+		It("should recreate the Secret if it is immutable", func() {
+			Expect(sink.Write(context.Background(), owner, ownerRef, secretName, credentials)).To(Succeed())
+			updated := credentials
+			updated.Hash = "updated"
+			updated.Immutable = false
+			Expect(sink.Write(context.Background(), owner, ownerRef, secretName, updated)).To(Succeed())
+			secret := corev1.Secret{}
+			Expect(k8sClient.Get(context.Background(), client.ObjectKey{Namespace: "default", Name: secretName}, &secret)).To(Succeed())
+			Expect(secret.Annotations).To(HaveKeyWithValue(secretsink.HashAnnotationKey, "updated"))
+			Expect(secret.Immutable).To(BeNil())
+		})
+		It("should default the type of the Secret to Opaque", func() {
+			Expect(sink.Write(context.Background(), owner, ownerRef, secretName, secretsink.Credentials{})).To(Succeed())
+			secret := corev1.Secret{}
+			Expect(k8sClient.Get(context.Background(), client.ObjectKey{Namespace: "default", Name: secretName}, &secret)).To(Succeed())
+			Expect(secret.Type).To(Equal(corev1.SecretTypeOpaque))
+		})
+	})
+})
+
+var _ = Describe(FormatTestDesc(Unit, "File"), func() {
+	var dir string
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "secretsink")
+		Expect(err).ToNot(HaveOccurred())
+	})
+	AfterEach(func() {
+		Expect(os.RemoveAll(dir)).To(Succeed())
+	})
+
+	testSink(func() secretsink.SecretSink {
+		return secretsink.File{Directory: dir}
+	})
+
+	It("should write the credentials as JSON", func() {
+		owner, ownerRef := newOwner("a")
+		sink := secretsink.File{Directory: dir}
+		data := database.SecretFormat{"username": "test"}
+		Expect(sink.Write(context.Background(), owner, ownerRef, secretName, secretsink.Credentials{Data: data})).To(Succeed())
+		value, err := ioutil.ReadFile(sink.Location(owner, secretName))
+		Expect(err).ToNot(HaveOccurred())
+		content := map[string]interface{}{}
+		Expect(json.Unmarshal(value, &content)).To(Succeed())
+		Expect(content).To(HaveKeyWithValue("data", HaveKeyWithValue("username", "test")))
+	})
+})
+
+var _ = Describe(FormatTestDesc(Unit, "Vault"), func() {
+	var server *httptest.Server
+
+	BeforeEach(func() {
+		// Minimal in-memory KV version 2 secrets engine mounted at secret
+		var mu sync.Mutex
+		secrets := map[string]map[string]string{}
+		metadata := map[string]map[string]string{}
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			defer mu.Unlock()
+			if r.Header.Get("X-Vault-Token") != "s.test" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			switch {
+			case r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, "/v1/secret/data/"):
+				data, exists := secrets[strings.TrimPrefix(r.URL.Path, "/v1/secret/data/")]
+				if !exists {
+					w.WriteHeader(http.StatusNotFound)
+					return
+				}
+				_ = json.NewEncoder(w).Encode(map[string]interface{}{"data": map[string]interface{}{"data": data}})
+			case r.Method == http.MethodPost && strings.HasPrefix(r.URL.Path, "/v1/secret/data/"):
+				body := struct {
+					Data map[string]string `json:"data"`
+				}{}
+				if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+					w.WriteHeader(http.StatusBadRequest)
+					return
+				}
+				secrets[strings.TrimPrefix(r.URL.Path, "/v1/secret/data/")] = body.Data
+				// Writing a secret creates its metadata
+				if _, exists := metadata[strings.TrimPrefix(r.URL.Path, "/v1/secret/data/")]; !exists {
+					metadata[strings.TrimPrefix(r.URL.Path, "/v1/secret/data/")] = map[string]string{}
+				}
+				w.WriteHeader(http.StatusOK)
+			case r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, "/v1/secret/metadata/"):
+				customMetadata, exists := metadata[strings.TrimPrefix(r.URL.Path, "/v1/secret/metadata/")]
+				if !exists {
+					w.WriteHeader(http.StatusNotFound)
+					return
+				}
+				_ = json.NewEncoder(w).Encode(map[string]interface{}{"data": map[string]interface{}{"custom_metadata": customMetadata}})
+			case r.Method == http.MethodPost && strings.HasPrefix(r.URL.Path, "/v1/secret/metadata/"):
+				body := struct {
+					CustomMetadata map[string]string `json:"custom_metadata"`
+				}{}
+				if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+					w.WriteHeader(http.StatusBadRequest)
+					return
+				}
+				metadata[strings.TrimPrefix(r.URL.Path, "/v1/secret/metadata/")] = body.CustomMetadata
+				w.WriteHeader(http.StatusNoContent)
+			case r.Method == http.MethodDelete && strings.HasPrefix(r.URL.Path, "/v1/secret/metadata/"):
+				delete(secrets, strings.TrimPrefix(r.URL.Path, "/v1/secret/metadata/"))
+				delete(metadata, strings.TrimPrefix(r.URL.Path, "/v1/secret/metadata/"))
+				w.WriteHeader(http.StatusNoContent)
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+	})
+	AfterEach(func() {
+		server.Close()
+	})
+
+	newSink := func() secretsink.SecretSink {
+		reader := fake.NewClientBuilder().WithObjects(&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "operator", Name: "vault"},
+			Data:       map[string][]byte{"token": []byte("s.test\n")},
+		}).Build()
+		return secretsink.Vault{
+			Reader:            reader,
+			Address:           server.URL,
+			Path:              "dbaas",
+			TokenSecretKeyRef: &database.SecretKeyRef{Name: "vault", Key: "token"},
+			DefaultNamespace:  "operator",
+		}
+	}
+	testSink(newSink)
+
+	It("should return the path of the secret as location", func() {
+		owner, _ := newOwner("a")
+		Expect(newSink().Location(owner, secretName)).To(Equal("secret/dbaas/default/" + secretName))
+	})
+	It("should not touch secrets written outside of the Operator", func() {
+		owner, ownerRef := newOwner("a")
+		vaultClient, err := vault.NewClient(server.URL, "s.test")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(vaultClient.Write(context.Background(), "", "dbaas/default/"+secretName, map[string]string{"password": "manual"})).To(Succeed())
+		sink := newSink()
+		Expect(sink.Write(context.Background(), owner, ownerRef, secretName, secretsink.Credentials{})).To(Equal(secretsink.ErrNotOwned))
+		Expect(sink.Delete(context.Background(), owner, secretName)).To(Succeed())
+		data, err := vaultClient.Read(context.Background(), "", "dbaas/default/"+secretName)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(data).To(HaveKeyWithValue("password", "manual"))
+	})
+	It("should return an error if the token cannot be read", func() {
+		owner, _ := newOwner("a")
+		sink := secretsink.Vault{
+			Reader:            fake.NewClientBuilder().Build(),
+			Address:           server.URL,
+			TokenSecretKeyRef: &database.SecretKeyRef{Name: "vault", Key: "token"},
+			DefaultNamespace:  "operator",
+		}
+		_, err := sink.Read(context.Background(), owner, secretName)
+		Expect(err).To(HaveOccurred())
+		Expect(err).ToNot(Equal(secretsink.ErrNotFound))
+	})
+})
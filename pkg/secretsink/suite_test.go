@@ -0,0 +1,12 @@
+package secretsink_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"testing"
+)
+
+func TestSecretSink(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "SecretSink package suite")
+}
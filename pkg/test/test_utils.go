@@ -19,6 +19,8 @@ const (
 	PostgresCreateOpName  = "sp_create_db_rowset_eav"
 	MysqlCreateOpName     = "sp_create_db_rowset_eav"
 	SqlserverCreateOpName = "sp_create_rowset_EAV"
+	// PostgresFailOpName returns a row and then raises an exception (SQLSTATE P0001)
+	PostgresFailOpName = "sp_fail"
 )
 
 // FormatTestDesc should be used throughout the project to format test descriptions for the Ginkgo testing
@@ -4,15 +4,22 @@ package typeutil
 
 const (
 	// Condition types
-	TypeReady = "Ready"
+	TypeReady   = "Ready"
+	TypeStalled = "Stalled"
 
 	// UpperCamelCase reasons enumerable, generic format is <Subject>[Verb]<Outcome (e.g. "Ready", "InProgress", "Failed"...)>
 	RsnCreate               = "DatabaseReady"
+	RsnCredVerifyFail       = "CredentialVerificationFailed"
+	RsnCredVerifyRenderFail = "CredentialVerificationRenderFailed"
+	RsnCredVerifySucc       = "CredentialVerificationSuccess"
 	RsnDbCreateFail         = "DatabaseCreateFailed"
 	RsnDbCreateInProg       = "DatabaseCreateInProgress"
 	RsnDbCreateSucc         = "DatabaseCreateSuccess"
 	RsnDbDeleteFail         = "DatabaseDeleteFailed"
 	RsnDbDeleteInProg       = "DatabaseDeleteInProg"
+	RsnDbDriftDetected      = "DatabaseDriftDetected"
+	RsnDbDriftResolved      = "DatabaseDriftResolved"
+	RsnDbExistsFail         = "DatabaseExistsCheckFailed"
 	RsnDbGetFail            = "DatabaseGetFailed"
 	RsnDbMetaParseFail      = "DatabaseMetaParseFailed"
 	RsnDbOpQueueSucc        = "DatabaseQueueSuccess"
@@ -21,29 +28,46 @@ const (
 	RsnDbRotateSucc         = "DatabaseRotateSuccess"
 	RsnDbSpecParseFail      = "DatabaseSpecParseFailed"
 	RsnDbUpdateFail         = "DatabaseUpdateFailed"
+	RsnDbUsageFail          = "DatabaseUsageFailed"
 	RsnDbcConfigGetFail     = "DatabaseClassConfigGetFailed"
 	RsnDbcGetFail           = "DatabaseClassGetFailed"
 	RsnDbmsConfigGetFail    = "DbmsConfigGetFailed"
 	RsnDbmsConnFail         = "DbmsConnectionFailed"
 	RsnDbmsEndpointNotFound = "DbmsEndpointConnectFailed"
+	RsnJournalFail          = "OperationJournalFailed"
+	RsnJournalResume        = "OperationJournalResumed"
+	RsnNamespaceGetFail     = "NamespaceGetFailed"
 	RsnOpNotSupported       = "OperationNotSupported"
+	RsnOpOutputInvalid      = "OperationOutputInvalid"
 	RsnOpRenderFail         = "OperationRenderFailed"
+	RsnParamsResolveFail    = "ParamsResolveFailed"
 	RsnReadyCondUpdateFail  = "ReadyConditionUpdateFailed"
 	RsnSecretCreateFail     = "SecretCreateFailed"
 	RsnSecretCreateSucc     = "SecretCreateSuccess"
+	RsnSecretDeleteFail     = "SecretDeleteFailed"
 	RsnSecretExists         = "RsnSecretExists"
 	RsnSecretGetFail        = "SecretGetFailed"
+	RsnSecretNameInvalid    = "SecretNameInvalid"
 	RsnSecretRenderFail     = "SecretRenderFailed"
+	RsnSecretRestoreSucc    = "SecretRestoreSuccess"
+	RsnSecretSinkInvalid    = "SecretSinkInvalid"
+	RsnSecretTampered       = "SecretTampered"
 	RsnSecretUpdateFail     = "SecretUpdateFailed"
 	RsnSecretUpdateSucc     = "SecretUpdateSuccess"
 
 	// Human-readable messages
+	MsgCredVerifyFail       = "could not connect to dbms endpoint using the credentials returned by the operation"
+	MsgCredVerifyRenderFail = "could not render dsn for credential verification"
+	MsgCredVerifySucc       = "credentials returned by the operation verified successfully"
 	MsgDbCreateFail         = "could not create database instance on dbms endpoint"
 	MsgDbCreateInProg       = "database instance is being provisioned on dbms endpoint"
 	MsgDbCreateSucc         = "database instance provisioned successfully on dbms endpoint"
 	MsgDbDeleteFail         = "could not delete database instance from dbms endpoint"
 	MsgDbDeleteInProg       = "database instance is being deleted from dbms endpoint"
 	MsgDbDeleted            = "database resource not found. Ignoring since object must be deleted"
+	MsgDbDriftDetected      = "database instance not found on dbms endpoint"
+	MsgDbDriftResolved      = "database instance found again on dbms endpoint"
+	MsgDbExistsFail         = "could not check whether database instance exists on dbms endpoint"
 	MsgDbGetFail            = "database resource get failed"
 	MsgDbMetaParseFail      = "could not parse metadata field of database resource during operation values creation"
 	MsgDbOpQueueSucc        = "database operation queued successfully"
@@ -52,19 +76,30 @@ const (
 	MsgDbRotateSucc         = "database credentials rotation completed"
 	MsgDbSpecParseFail      = "could not parse spec field of database resource during operation values creation"
 	MsgDbUpdateFail         = "could not update database resource, retrying"
+	MsgDbUsageFail          = "could not retrieve usage metrics of database instance"
 	MsgDbcConfigGetFail     = "could not retrieve databaseclass name from dbms config"
 	MsgDbcGetFail           = "databaseclass resource get failed"
 	MsgDbmsConfigGetFail    = "could not retrieve dbms list from operator config"
 	MsgDbmsConnFail         = "could not establish connection to dbms endpoint"
 	MsgDbmsEndpointNotFound = "dbms connection not found in pool of connections"
+	MsgJournalFail          = "could not read or write operation journal"
+	MsgJournalResume        = "operation already executed, resuming from operation journal"
+	MsgNamespaceGetFail     = "could not get namespace of database resource"
 	MsgOpNotSupported       = "operation is not supported for databaseclass"
+	MsgOpOutputInvalid      = "operation did not return the outputs declared by databaseclass, missing keys"
 	MsgOpRenderFail         = "could not render operation values"
+	MsgParamsResolveFail    = "could not resolve parameters referenced by database resource"
 	MsgReadyCondUpdateFail  = "could not update ready condition of resource"
 	MsgSecretCreateFail     = "could not create secret resource for database resource"
 	MsgSecretCreateSucc     = "secret created successfully"
+	MsgSecretDeleteFail     = "could not delete secret of database resource"
 	MsgSecretExists         = "secret exists already, please manually remove it from the cluster"
 	MsgSecretGetFail        = "secret get failed"
+	MsgSecretNameInvalid    = "could not render secret name"
 	MsgSecretRenderFail     = "could not render secret data"
+	MsgSecretRestoreSucc    = "secret restored from non-sensitive outputs successfully"
+	MsgSecretSinkInvalid    = "invalid secret sink configuration in databaseclass"
+	MsgSecretTampered       = "secret was modified by someone other than the operator"
 	MsgSecretUpdateFail     = "secret update failed"
 	MsgSecretUpdateSucc     = "secret updated successfully"
 	// Event types
@@ -1,5 +1,7 @@
 package typeutil
 
+import "sort"
+
 // StringsToInterfaceSlice is a utility function which converts a slice of strings to []interface
 func StringsToInterfaceSlice(values ...string) []interface{} {
 	y := make([]interface{}, len(values))
@@ -8,3 +10,13 @@ func StringsToInterfaceSlice(values ...string) []interface{} {
 	}
 	return y
 }
+
+// SortedKeys returns the keys of m sorted, e.g. to log which values are present without logging the values themselves
+func SortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,150 @@
+// Package vault implements a minimal client for the KV version 2 secrets engine of the HTTP API of Vault, see
+// https://www.vaultproject.io/api-docs/secret/kv/kv-v2.
+package vault
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+)
+
+const (
+	// AddressEnvVar is the environment variable holding the default address of the Vault server.
+	AddressEnvVar = "VAULT_ADDR"
+	// TokenEnvVar is the environment variable holding the default token used to authenticate to Vault.
+	TokenEnvVar = "VAULT_TOKEN"
+	// DefaultMount is the default path the KV secrets engine is mounted at.
+	DefaultMount = "secret"
+
+	tokenHeader = "X-Vault-Token"
+)
+
+// ErrNotFound is returned when a secret doesn't exist.
+var ErrNotFound = fmt.Errorf("secret not found")
+
+// Client reads and writes secrets of the KV version 2 secrets engine of a Vault server.
+type Client struct {
+	address    string
+	token      string
+	httpClient *http.Client
+}
+
+// NewClient returns a Client for the Vault server at address authenticating with token. If address or token are
+// empty, they default to the environment variables AddressEnvVar and TokenEnvVar respectively.
+func NewClient(address, token string) (*Client, error) {
+	if address == "" {
+		address = os.Getenv(AddressEnvVar)
+	}
+	if token == "" {
+		token = os.Getenv(TokenEnvVar)
+	}
+	if address == "" {
+		return nil, fmt.Errorf("vault address not specified and %s not set", AddressEnvVar)
+	}
+	if token == "" {
+		return nil, fmt.Errorf("vault token not specified and %s not set", TokenEnvVar)
+	}
+	return &Client{
+		address:    strings.TrimSuffix(address, "/"),
+		token:      token,
+		httpClient: http.DefaultClient,
+	}, nil
+}
+
+// Read returns the data of the latest version of the secret at path in the secrets engine mounted at mount. If mount is
+// empty, DefaultMount is used. If the secret doesn't exist, ErrNotFound is returned.
+func (c *Client) Read(ctx context.Context, mount, path string) (map[string]string, error) {
+	var response struct {
+		Data struct {
+			Data map[string]string `json:"data"`
+		} `json:"data"`
+	}
+	if err := c.do(ctx, http.MethodGet, c.url(mount, "data", path), nil, &response); err != nil {
+		return nil, err
+	}
+	return response.Data.Data, nil
+}
+
+// Write stores data as the latest version of the secret at path in the secrets engine mounted at mount. If mount is
+// empty, DefaultMount is used.
+func (c *Client) Write(ctx context.Context, mount, path string, data map[string]string) error {
+	body, err := json.Marshal(map[string]interface{}{"data": data})
+	if err != nil {
+		return err
+	}
+	return c.do(ctx, http.MethodPost, c.url(mount, "data", path), bytes.NewReader(body), nil)
+}
+
+// ReadMetadata returns the custom metadata of the secret at path in the secrets engine mounted at mount. If mount is
+// empty, DefaultMount is used. If the secret doesn't exist, ErrNotFound is returned.
+func (c *Client) ReadMetadata(ctx context.Context, mount, path string) (map[string]string, error) {
+	var response struct {
+		Data struct {
+			CustomMetadata map[string]string `json:"custom_metadata"`
+		} `json:"data"`
+	}
+	if err := c.do(ctx, http.MethodGet, c.url(mount, "metadata", path), nil, &response); err != nil {
+		return nil, err
+	}
+	return response.Data.CustomMetadata, nil
+}
+
+// WriteMetadata replaces the custom metadata of the secret at path in the secrets engine mounted at mount with
+// customMetadata, creating the secret if it doesn't exist. If mount is empty, DefaultMount is used. Custom metadata is
+// supported by Vault 1.9 and later.
+func (c *Client) WriteMetadata(ctx context.Context, mount, path string, customMetadata map[string]string) error {
+	body, err := json.Marshal(map[string]interface{}{"custom_metadata": customMetadata})
+	if err != nil {
+		return err
+	}
+	return c.do(ctx, http.MethodPost, c.url(mount, "metadata", path), bytes.NewReader(body), nil)
+}
+
+// Delete permanently deletes all the versions of the secret at path in the secrets engine mounted at mount. If mount is
+// empty, DefaultMount is used. Deleting a secret which doesn't exist is not an error.
+func (c *Client) Delete(ctx context.Context, mount, path string) error {
+	return c.do(ctx, http.MethodDelete, c.url(mount, "metadata", path), nil, nil)
+}
+
+// url returns the URL of the endpoint of the API for path, e.g. data or metadata.
+func (c *Client) url(mount, endpoint, path string) string {
+	if mount == "" {
+		mount = DefaultMount
+	}
+	return fmt.Sprintf("%s/v1/%s/%s/%s", c.address, strings.Trim(mount, "/"), endpoint, strings.TrimPrefix(path, "/"))
+}
+
+// do sends a request with body, if not nil, and decodes the response into result, if not nil.
+func (c *Client) do(ctx context.Context, method, url string, body io.Reader, result interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set(tokenHeader, c.token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return ErrNotFound
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		// The body contains the errors reported by Vault, never the data of the secret
+		message, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("vault returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(message)))
+	}
+	if result == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
@@ -0,0 +1,101 @@
+package vault_test
+
+import (
+	"context"
+	"encoding/json"
+	. "github.com/bedag/kubernetes-dbaas/pkg/test"
+	"github.com/bedag/kubernetes-dbaas/pkg/vault"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"net/http"
+	"net/http/httptest"
+)
+
+const testToken = "s.test"
+
+var _ = Describe(FormatTestDesc(Unit, "Client"), func() {
+	var server *httptest.Server
+	var client *vault.Client
+
+	BeforeEach(func() {
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("X-Vault-Token") != testToken {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			if r.URL.Path != "/v1/secret/data/dbaas/test" {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{
+				"data": map[string]interface{}{
+					"data": map[string]string{"dsn": "postgres://localhost"},
+				},
+			})
+		}))
+		var err error
+		client, err = vault.NewClient(server.URL, testToken)
+		Expect(err).ToNot(HaveOccurred())
+	})
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("should read a secret", func() {
+		data, err := client.Read(context.Background(), "", "dbaas/test")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(data).To(HaveKeyWithValue("dsn", "postgres://localhost"))
+	})
+	It("should return ErrNotFound if the secret doesn't exist", func() {
+		_, err := client.Read(context.Background(), "secret", "dbaas/missing")
+		Expect(err).To(Equal(vault.ErrNotFound))
+	})
+	It("should return an error if the token is invalid", func() {
+		client, err := vault.NewClient(server.URL, "invalid")
+		Expect(err).ToNot(HaveOccurred())
+		_, err = client.Read(context.Background(), "", "dbaas/test")
+		Expect(err).To(HaveOccurred())
+	})
+})
+
+var _ = Describe(FormatTestDesc(Unit, "Client.Write and Client.Delete"), func() {
+	var server *httptest.Server
+	var client *vault.Client
+	var method, path string
+	var body map[string]map[string]string
+
+	BeforeEach(func() {
+		method, path, body = "", "", nil
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			method, path = r.Method, r.URL.Path
+			if r.Method == http.MethodPost {
+				_ = json.NewDecoder(r.Body).Decode(&body)
+			}
+			w.WriteHeader(http.StatusNoContent)
+		}))
+		var err error
+		client, err = vault.NewClient(server.URL, testToken)
+		Expect(err).ToNot(HaveOccurred())
+	})
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("should write a new version of a secret", func() {
+		Expect(client.Write(context.Background(), "kv", "dbaas/test", map[string]string{"dsn": "postgres://localhost"})).To(Succeed())
+		Expect(method).To(Equal(http.MethodPost))
+		Expect(path).To(Equal("/v1/kv/data/dbaas/test"))
+		Expect(body).To(HaveKeyWithValue("data", HaveKeyWithValue("dsn", "postgres://localhost")))
+	})
+	It("should replace the custom metadata of a secret", func() {
+		Expect(client.WriteMetadata(context.Background(), "kv", "dbaas/test", map[string]string{"owner": "1234"})).To(Succeed())
+		Expect(method).To(Equal(http.MethodPost))
+		Expect(path).To(Equal("/v1/kv/metadata/dbaas/test"))
+		Expect(body).To(HaveKeyWithValue("custom_metadata", HaveKeyWithValue("owner", "1234")))
+	})
+	It("should delete all the versions of a secret", func() {
+		Expect(client.Delete(context.Background(), "", "dbaas/test")).To(Succeed())
+		Expect(method).To(Equal(http.MethodDelete))
+		Expect(path).To(Equal("/v1/secret/metadata/dbaas/test"))
+	})
+})
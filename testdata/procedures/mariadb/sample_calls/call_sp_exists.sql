@@ -0,0 +1 @@
+CALL sp_exists("manualtest");
\ No newline at end of file
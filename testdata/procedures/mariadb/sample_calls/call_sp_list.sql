@@ -0,0 +1 @@
+CALL sp_list();
\ No newline at end of file
@@ -0,0 +1 @@
+CALL sp_usage("manualtest");
\ No newline at end of file
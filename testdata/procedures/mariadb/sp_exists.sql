@@ -0,0 +1,6 @@
+DELIMITER $
+CREATE OR REPLACE PROCEDURE sp_exists(k8sName TEXT)
+BEGIN
+	SELECT 'exists' AS `key`, IF(EXISTS(SELECT 1 FROM information_schema.SCHEMATA WHERE SCHEMA_NAME = k8sName), 'true', 'false') AS value;
+END $
+DELIMITER ;
@@ -0,0 +1,6 @@
+DELIMITER $
+CREATE OR REPLACE PROCEDURE sp_list()
+BEGIN
+	SELECT dbName AS `key`, fqdn AS value FROM _databases;
+END $
+DELIMITER ;
@@ -0,0 +1,11 @@
+DELIMITER $
+CREATE OR REPLACE PROCEDURE sp_usage(k8sName TEXT)
+BEGIN
+	SELECT 'size_bytes' AS `key`, CAST(COALESCE(SUM(DATA_LENGTH + INDEX_LENGTH), 0) AS CHAR) AS value
+		FROM information_schema.TABLES WHERE TABLE_SCHEMA = k8sName
+	UNION ALL
+	SELECT 'table_count', CAST(COUNT(*) AS CHAR) FROM information_schema.TABLES WHERE TABLE_SCHEMA = k8sName
+	UNION ALL
+	SELECT 'active_connections', CAST(COUNT(*) AS CHAR) FROM information_schema.PROCESSLIST WHERE DB = k8sName;
+END $
+DELIMITER ;
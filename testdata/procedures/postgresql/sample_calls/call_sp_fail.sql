@@ -0,0 +1 @@
+select * from sp_fail(k8sName := 'my-test-db');
\ No newline at end of file
@@ -0,0 +1 @@
+select * from sp_list();
\ No newline at end of file
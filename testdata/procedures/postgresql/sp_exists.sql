@@ -0,0 +1,9 @@
+CREATE OR REPLACE FUNCTION sp_exists(k8sName text)
+ RETURNS TABLE(key text, value text)
+ LANGUAGE plpgsql
+AS $function$
+	BEGIN
+		RETURN QUERY SELECT 'exists'::text, (EXISTS (SELECT 1 FROM pg_database WHERE datname = k8sName))::text;
+	END;
+$function$
+;
@@ -0,0 +1,10 @@
+CREATE OR REPLACE FUNCTION sp_fail(k8sName text)
+ RETURNS TABLE(key text, value text)
+ LANGUAGE plpgsql
+AS $function$
+	BEGIN
+		RETURN QUERY SELECT 'exists'::text, 'true'::text;
+		RAISE EXCEPTION 'database % is locked', k8sName;
+	END;
+$function$
+;
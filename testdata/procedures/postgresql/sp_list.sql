@@ -0,0 +1,9 @@
+CREATE OR REPLACE FUNCTION sp_list()
+ RETURNS TABLE(key text, value text)
+ LANGUAGE plpgsql
+AS $function$
+	BEGIN
+		RETURN QUERY SELECT dbName::text, fqdn::text FROM databases;
+	END;
+$function$
+;
@@ -0,0 +1,11 @@
+CREATE OR REPLACE FUNCTION sp_usage(k8sName text)
+ RETURNS TABLE(key text, value text)
+ LANGUAGE plpgsql
+AS $function$
+	BEGIN
+		RETURN QUERY SELECT 'size_bytes'::text, pg_database_size(k8sName)::text
+		UNION ALL
+		SELECT 'active_connections'::text, count(*)::text FROM pg_stat_activity WHERE datname = k8sName;
+	END;
+$function$
+;
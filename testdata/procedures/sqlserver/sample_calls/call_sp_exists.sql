@@ -0,0 +1 @@
+EXEC sp_exists @k8sName = 'database-sample-123';
\ No newline at end of file
@@ -0,0 +1 @@
+EXEC sp_list;
\ No newline at end of file
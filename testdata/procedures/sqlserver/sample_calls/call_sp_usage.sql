@@ -0,0 +1 @@
+EXEC sp_usage @k8sName = 'database-sample-123';
\ No newline at end of file
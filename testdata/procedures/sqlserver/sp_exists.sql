@@ -0,0 +1,9 @@
+CREATE OR ALTER PROCEDURE sp_exists (@k8sName varchar(max))
+AS
+DECLARE @t TABLE([key] varchar(max), value varchar(max))
+IF DB_ID(@k8sName) IS NOT NULL
+	INSERT @t VALUES('exists', 'true')
+ELSE
+	INSERT @t VALUES('exists', 'false')
+
+SELECT * FROM @t
@@ -0,0 +1,3 @@
+CREATE OR ALTER PROCEDURE sp_list
+AS
+SELECT dbName AS [key], fqdn AS value FROM databases
@@ -0,0 +1,6 @@
+CREATE OR ALTER PROCEDURE sp_usage (@k8sName varchar(max))
+AS
+SELECT 'size_bytes' AS [key], CAST(COALESCE(SUM(CAST(size AS bigint)) * 8192, 0) AS varchar(max)) AS value
+	FROM sys.master_files WHERE database_id = DB_ID(@k8sName)
+UNION ALL
+SELECT 'active_connections', CAST(COUNT(*) AS varchar(max)) FROM sys.dm_exec_sessions WHERE database_id = DB_ID(@k8sName)